@@ -0,0 +1,25 @@
+// Command fakeinvoice runs the fake e-invoice platform for local
+// development. Point the backend at it with
+// EINVOICE_API_URL=http://localhost:8091 EINVOICE_API_KEY=dev-key.
+package main
+
+import (
+	"food-platform-backend/einvoice"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	addr := os.Getenv("FAKEINVOICE_ADDR")
+	if addr == "" {
+		addr = ":8091"
+	}
+	apiKey := os.Getenv("EINVOICE_API_KEY")
+	if apiKey == "" {
+		apiKey = "dev-key"
+	}
+
+	log.Printf("Fake e-invoice platform on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, einvoice.NewFakePlatform(apiKey)))
+}
@@ -0,0 +1,31 @@
+// Command fakepay runs the fake payment provider for local development.
+// Point the backend at it with FAKEPAY_URL=http://localhost:8090 and pay an
+// intent with:
+//
+//	curl -X POST http://localhost:8090/_fake/payment_intents/<id>/pay
+package main
+
+import (
+	"food-platform-backend/payments"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	addr := os.Getenv("FAKEPAY_ADDR")
+	if addr == "" {
+		addr = ":8090"
+	}
+	webhookURL := os.Getenv("FAKEPAY_WEBHOOK_URL")
+	if webhookURL == "" {
+		webhookURL = "http://localhost:8080/payments/webhook/fake"
+	}
+	secret := os.Getenv("FAKEPAY_WEBHOOK_SECRET")
+	if secret == "" {
+		secret = "whsec_fake"
+	}
+
+	log.Printf("Fake payment provider on %s, webhooks to %s", addr, webhookURL)
+	log.Fatal(http.ListenAndServe(addr, payments.NewFakeServer(webhookURL, secret)))
+}
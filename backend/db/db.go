@@ -50,6 +50,105 @@ func InitDB() {
 	fmt.Println("Successfully connected to the database!")
 
 	createTables()
+	migrateDuplicateReviews()
+}
+
+// The rating aggregates are built from the published reviews only while
+// merchant_rating_stats is empty.
+const (
+	backfillRatingDays = `
+		INSERT INTO merchant_rating_days (merchant_id, day, review_count, rating_sum)
+		SELECT merchant_id, created_at::date, COUNT(*), SUM(rating)
+		FROM reviews
+		WHERE status = 'published' AND NOT EXISTS (SELECT 1 FROM merchant_rating_stats)
+		GROUP BY 1, 2
+		ON CONFLICT DO NOTHING;
+	`
+	backfillRatingStats = `
+		INSERT INTO merchant_rating_stats (merchant_id, review_count, rating_sum, stars)
+		SELECT merchant_id, COUNT(*), SUM(rating),
+		       ARRAY[COUNT(*) FILTER (WHERE rating = 1), COUNT(*) FILTER (WHERE rating = 2), COUNT(*) FILTER (WHERE rating = 3),
+		             COUNT(*) FILTER (WHERE rating = 4), COUNT(*) FILTER (WHERE rating = 5)]::INT[]
+		FROM reviews
+		WHERE status = 'published' AND NOT EXISTS (SELECT 1 FROM merchant_rating_stats)
+		GROUP BY merchant_id;
+	`
+)
+
+// migrateDuplicateReviews enforces one review per order. Databases created
+// before the constraint may hold several reviews for an order: the first one
+// is kept and the later ones are archived in reviews_removed_duplicates before
+// they are deleted. The rating aggregates, already backfilled by createTables,
+// counted the duplicates and are rebuilt in the same transaction. Once
+// uk_reviews_order_id exists this is a no-op.
+func migrateDuplicateReviews() {
+	// Earlier builds named the index idx_reviews_order_id
+	DB.Exec(`ALTER INDEX IF EXISTS idx_reviews_order_id RENAME TO uk_reviews_order_id;`)
+
+	var done bool
+	if err := DB.QueryRow(`SELECT to_regclass('uk_reviews_order_id') IS NOT NULL`).Scan(&done); err != nil {
+		log.Println("Review dedupe migration: cannot check index:", err)
+		return
+	}
+	if done {
+		return
+	}
+
+	DB.Exec(`
+	CREATE TABLE IF NOT EXISTS reviews_removed_duplicates (
+		review_id INT PRIMARY KEY,
+		order_id INT,
+		review JSONB NOT NULL,
+		removed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Println("Review dedupe migration:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO reviews_removed_duplicates (review_id, order_id, review)
+		SELECT r.id, r.order_id, to_jsonb(r)
+		FROM reviews r
+		WHERE EXISTS (SELECT 1 FROM reviews d WHERE d.order_id = r.order_id AND d.id < r.id)
+		ON CONFLICT (review_id) DO NOTHING
+	`); err != nil {
+		log.Println("Review dedupe migration: archive failed:", err)
+		return
+	}
+	res, err := tx.Exec(`DELETE FROM reviews r USING reviews d WHERE r.order_id = d.order_id AND r.id > d.id`)
+	if err != nil {
+		log.Println("Review dedupe migration: delete failed:", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		for _, q := range []string{
+			`DELETE FROM merchant_rating_days`,
+			`DELETE FROM merchant_rating_stats`,
+			backfillRatingDays,
+			backfillRatingStats,
+		} {
+			if _, err := tx.Exec(q); err != nil {
+				log.Println("Review dedupe migration: rating rebuild failed:", err)
+				return
+			}
+		}
+	}
+	if _, err := tx.Exec(`CREATE UNIQUE INDEX uk_reviews_order_id ON reviews(order_id)`); err != nil {
+		log.Println("Review dedupe migration: unique index failed:", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("Review dedupe migration:", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Review dedupe migration: archived %d duplicate reviews in reviews_removed_duplicates", n)
+	}
 }
 
 func createTables() {
@@ -152,6 +251,10 @@ func createTables() {
 	);
 	`
 	DB.Exec(queryReviews)
+	// The merchant's single public reply
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS reply TEXT;`)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS replied_at TIMESTAMP;`)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS reply_updated_at TIMESTAMP;`)
 
 	// Favorites Table - Users' favorite merchants
 	queryFavorites := `
@@ -234,4 +337,506 @@ func createTables() {
 
 	// Add status column to orders
 	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS status TEXT DEFAULT 'pending';`)
+
+	// =========================================================================
+	// RECURRING LISTINGS
+	// =========================================================================
+
+	// Merchant timezone (IANA name) used for schedules and pickup times
+	DB.Exec(`ALTER TABLE merchants ADD COLUMN IF NOT EXISTS timezone TEXT DEFAULT 'Asia/Taipei';`)
+
+	// Listing Schedules Table - Listings a merchant publishes on set weekdays
+	queryListingSchedules := `
+	CREATE TABLE IF NOT EXISTS listing_schedules (
+		id SERIAL PRIMARY KEY,
+		merchant_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		original_price NUMERIC(10, 2) NOT NULL,
+		current_price NUMERIC(10, 2) NOT NULL,
+		quantity INT NOT NULL DEFAULT 1,
+		weekdays INT[] NOT NULL,
+		publish_time TEXT NOT NULL,
+		expiry_minutes INT NOT NULL,
+		latitude DOUBLE PRECISION NOT NULL,
+		longitude DOUBLE PRECISION NOT NULL,
+		is_paused BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryListingSchedules)
+
+	// Listing Schedule Skips Table - Holiday dates (merchant local) to skip
+	queryListingScheduleSkips := `
+	CREATE TABLE IF NOT EXISTS listing_schedule_skips (
+		schedule_id INT REFERENCES listing_schedules(id) ON DELETE CASCADE,
+		skip_date DATE NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (schedule_id, skip_date)
+	);
+	`
+	DB.Exec(queryListingScheduleSkips)
+
+	// Listing Schedule Runs Table - One row per published occurrence, so a
+	// scheduler restart or a second instance never publishes a day twice
+	queryListingScheduleRuns := `
+	CREATE TABLE IF NOT EXISTS listing_schedule_runs (
+		schedule_id INT REFERENCES listing_schedules(id) ON DELETE CASCADE,
+		run_date DATE NOT NULL,
+		published_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (schedule_id, run_date)
+	);
+	`
+	DB.Exec(queryListingScheduleRuns)
+
+	DB.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS schedule_id INT;`)
+
+	// Pickup window, separate from how long the listing stays visible
+	DB.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS pickup_start TIMESTAMP;`)
+	DB.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS pickup_end TIMESTAMP;`)
+	DB.Exec(`UPDATE products SET pickup_start = created_at, pickup_end = expiry_date WHERE pickup_end IS NULL;`)
+
+	// =========================================================================
+	// LISTING VALIDATION RULES
+	// =========================================================================
+
+	// Listing Rules Table - '*' is the platform default, other rows are
+	// per-category overrides where NULL means "inherit the default"
+	queryListingRules := `
+	CREATE TABLE IF NOT EXISTS listing_rules (
+		category TEXT PRIMARY KEY,
+		min_discount_percent NUMERIC(5, 2),
+		max_lifetime_minutes INT,
+		price_floor NUMERIC(10, 2),
+		max_distance_meters DOUBLE PRECISION,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryListingRules)
+	DB.Exec(`
+		INSERT INTO listing_rules (category, min_discount_percent, max_lifetime_minutes, price_floor, max_distance_meters)
+		VALUES ('*', 10, 1440, 1, 500)
+		ON CONFLICT (category) DO NOTHING
+	`)
+
+	// =========================================================================
+	// RESERVATIONS
+	// =========================================================================
+
+	// Reservations Table - Timed holds on a product before checkout
+	queryReservations := `
+	CREATE TABLE IF NOT EXISTS reservations (
+		id SERIAL PRIMARY KEY,
+		product_id INT REFERENCES products(id),
+		consumer_id TEXT NOT NULL,
+		status TEXT DEFAULT 'active',
+		expires_at TIMESTAMP NOT NULL,
+		order_id INT REFERENCES orders(id),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryReservations)
+	DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS uk_reservations_product_active ON reservations(product_id) WHERE status = 'active';`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_reservations_status_expires_at ON reservations(status, expires_at);`)
+
+	// =========================================================================
+	// ORDER LIFECYCLE
+	// =========================================================================
+
+	// Timestamp for every order status transition
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS ready_at TIMESTAMP;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS picked_up_at TIMESTAMP;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS no_show_at TIMESTAMP;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;`)
+
+	// =========================================================================
+	// ORDER SNAPSHOTS
+	// =========================================================================
+
+	// Orders keep what was bought and paid, since products can change later
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS merchant_id TEXT;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS product_name TEXT;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS price_paid NUMERIC(10, 2);`)
+	DB.Exec(`
+		UPDATE orders o SET merchant_id = p.merchant_id, product_name = p.name, price_paid = p.current_price
+		FROM products p WHERE p.id = o.product_id AND o.product_name IS NULL
+	`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_consumer_id_created_at ON orders(consumer_id, created_at);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_merchant_id_created_at ON orders(merchant_id, created_at);`)
+
+	// =========================================================================
+	// PICKUP SLOTS
+	// =========================================================================
+
+	// Merchant-defined pickup time slots with a booking capacity
+	queryPickupSlots := `
+	CREATE TABLE IF NOT EXISTS pickup_slots (
+		id SERIAL PRIMARY KEY,
+		merchant_id TEXT REFERENCES merchants(user_id),
+		starts_at TIMESTAMP NOT NULL,
+		ends_at TIMESTAMP NOT NULL,
+		capacity INT NOT NULL,
+		booked INT DEFAULT 0,
+		reminded_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(merchant_id, starts_at)
+	);
+	`
+	DB.Exec(queryPickupSlots)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_pickup_slots_starts_at ON pickup_slots(starts_at);`)
+
+	// Each order books at most one slot
+	DB.Exec(`ALTER TABLE pickup_schedules ADD COLUMN IF NOT EXISTS slot_id INT REFERENCES pickup_slots(id);`)
+	DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS uk_pickup_schedules_order_id ON pickup_schedules(order_id);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_pickup_schedules_slot_id ON pickup_schedules(slot_id);`)
+
+	// =========================================================================
+	// PICKUP VERIFICATION
+	// =========================================================================
+
+	// One-time code shown at the counter, unique among a shop's open orders
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS pickup_code TEXT;`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_merchant_id_pickup_code ON orders(merchant_id, pickup_code);`)
+	if _, err := DB.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS uk_orders_merchant_id_pickup_code ON orders(merchant_id, pickup_code)
+		WHERE pickup_code IS NOT NULL AND COALESCE(status, 'pending') IN ('pending', 'confirmed', 'ready_for_pickup');
+	`); err != nil {
+		log.Println("Pickup codes: unique index not created, resolve duplicate open codes:", err)
+	}
+
+	// =========================================================================
+	// CANCELLATIONS & REFUNDS
+	// =========================================================================
+
+	// Per-merchant refund policy; merchants without a row use the defaults
+	queryCancellationPolicies := `
+	CREATE TABLE IF NOT EXISTS cancellation_policies (
+		merchant_id TEXT PRIMARY KEY REFERENCES merchants(user_id),
+		cutoff_minutes INT NOT NULL DEFAULT 60,
+		early_refund_percent NUMERIC(5, 2) NOT NULL DEFAULT 100,
+		late_refund_percent NUMERIC(5, 2) NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryCancellationPolicies)
+
+	queryOrderCancellations := `
+	CREATE TABLE IF NOT EXISTS order_cancellations (
+		id SERIAL PRIMARY KEY,
+		order_id INT UNIQUE REFERENCES orders(id),
+		consumer_id TEXT NOT NULL,
+		merchant_id TEXT,
+		cancelled_by TEXT NOT NULL,
+		reason_code TEXT NOT NULL,
+		reason TEXT,
+		is_late BOOLEAN DEFAULT FALSE,
+		refund_percent NUMERIC(5, 2) NOT NULL,
+		refund_amount NUMERIC(10, 2) NOT NULL,
+		refund_status TEXT DEFAULT 'pending',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryOrderCancellations)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_order_cancellations_consumer_id ON order_cancellations(consumer_id, created_at);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_order_cancellations_merchant_id ON order_cancellations(merchant_id, created_at);`)
+
+	// =========================================================================
+	// CART CHECKOUT
+	// =========================================================================
+
+	// A checkout groups the orders bought together from one merchant
+	queryCheckouts := `
+	CREATE TABLE IF NOT EXISTS checkouts (
+		id SERIAL PRIMARY KEY,
+		consumer_id TEXT NOT NULL,
+		merchant_id TEXT,
+		total NUMERIC(10, 2) NOT NULL,
+		item_count INT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryCheckouts)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS checkout_id INT REFERENCES checkouts(id);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_checkout_id ON orders(checkout_id);`)
+
+	// =========================================================================
+	// IDEMPOTENCY KEYS
+	// =========================================================================
+
+	// Stored responses replayed when a client retries with the same key
+	queryIdempotencyKeys := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idempotency_key TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		state TEXT NOT NULL DEFAULT 'in_progress',
+		status_code INT,
+		content_type TEXT,
+		response_body BYTEA,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (idempotency_key, scope)
+	);
+	`
+	DB.Exec(queryIdempotencyKeys)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);`)
+
+	// =========================================================================
+	// PAYMENTS
+	// =========================================================================
+
+	// Orders placed before payments existed are settled at pickup
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_method TEXT;`)
+	DB.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS payment_status TEXT DEFAULT 'not_required';`)
+
+	queryPayments := `
+	CREATE TABLE IF NOT EXISTS payments (
+		id SERIAL PRIMARY KEY,
+		order_id INT REFERENCES orders(id),
+		provider TEXT NOT NULL,
+		intent_id TEXT NOT NULL,
+		amount NUMERIC(10, 2) NOT NULL,
+		currency TEXT NOT NULL,
+		status TEXT DEFAULT 'pending',
+		refunded_amount NUMERIC(10, 2) DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(provider, intent_id)
+	);
+	`
+	DB.Exec(queryPayments)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_payments_status_created_at ON payments(status, created_at);`)
+
+	// The public transfer that settled an on-chain payment; one transfer
+	// settles one payment
+	DB.Exec(`ALTER TABLE payments ADD COLUMN IF NOT EXISTS transaction_id TEXT;`)
+	DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS uk_payments_provider_transaction_id ON payments(provider, transaction_id);`)
+
+	// =========================================================================
+	// RECEIPTS & E-INVOICES
+	// =========================================================================
+
+	// Mobile barcode carrier (手機條碼) e-invoices are stored under
+	DB.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_carrier TEXT;`)
+	// Uniform business number (統一編號); merchants without one issue receipts only
+	DB.Exec(`ALTER TABLE merchants ADD COLUMN IF NOT EXISTS tax_id TEXT;`)
+
+	queryReceipts := `
+	CREATE TABLE IF NOT EXISTS receipts (
+		id SERIAL PRIMARY KEY,
+		order_id INT UNIQUE REFERENCES orders(id),
+		receipt_number TEXT UNIQUE NOT NULL,
+		consumer_id TEXT NOT NULL,
+		merchant_id TEXT NOT NULL,
+		shop_name TEXT,
+		merchant_address TEXT,
+		merchant_tax_id TEXT,
+		item_name TEXT NOT NULL,
+		original_price NUMERIC(10, 2) NOT NULL,
+		total NUMERIC(10, 2) NOT NULL,
+		tax_rate NUMERIC(4, 3) NOT NULL,
+		tax_amount NUMERIC(10, 2) NOT NULL,
+		currency TEXT NOT NULL,
+		payment_method TEXT,
+		invoice_status TEXT DEFAULT 'not_required',
+		invoice_carrier TEXT,
+		invoice_number TEXT,
+		invoice_random_code TEXT,
+		invoice_issued_at TIMESTAMP,
+		invoice_attempts INT DEFAULT 0,
+		invoice_error TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryReceipts)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_receipts_invoice_status ON receipts(invoice_status);`)
+
+	// =========================================================================
+	// NO-SHOWS & RELIABILITY
+	// =========================================================================
+
+	queryNoShowDisputes := `
+	CREATE TABLE IF NOT EXISTS no_show_disputes (
+		id SERIAL PRIMARY KEY,
+		order_id INT UNIQUE REFERENCES orders(id),
+		consumer_id TEXT NOT NULL,
+		merchant_id TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		status TEXT DEFAULT 'open',
+		merchant_response TEXT,
+		resolution_note TEXT,
+		resolved_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryNoShowDisputes)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_no_show_disputes_merchant_status ON no_show_disputes(merchant_id, status);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_orders_consumer_status ON orders(consumer_id, status);`)
+
+	// =========================================================================
+	// PURCHASE LIMITS
+	// =========================================================================
+
+	// Per-merchant limits, only ever stricter than the platform's; 0 keeps
+	// the platform limit
+	queryPurchaseLimits := `
+	CREATE TABLE IF NOT EXISTS purchase_limits (
+		merchant_id TEXT PRIMARY KEY REFERENCES merchants(user_id),
+		per_shop_daily INT NOT NULL DEFAULT 0,
+		per_listing INT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryPurchaseLimits)
+	// Purchase limit counts use idx_orders_consumer_id_created_at; drop the
+	// duplicate earlier builds created
+	DB.Exec(`DROP INDEX IF EXISTS idx_orders_consumer_created_at;`)
+
+	// =========================================================================
+	// REVIEW MODERATION
+	// =========================================================================
+
+	// Only published reviews are public; flags are the content filter's findings
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS status TEXT DEFAULT 'published';`)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderation_flags JSONB DEFAULT '[]';`)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderation_reason TEXT;`)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP;`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_reviews_merchant_status ON reviews(merchant_id, status);`)
+
+	queryReviewReports := `
+	CREATE TABLE IF NOT EXISTS review_reports (
+		id SERIAL PRIMARY KEY,
+		review_id INT REFERENCES reviews(id),
+		reporter_id TEXT NOT NULL,
+		reason_code TEXT NOT NULL,
+		details TEXT,
+		status TEXT DEFAULT 'open',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(review_id, reporter_id)
+	);
+	`
+	DB.Exec(queryReviewReports)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_review_reports_status ON review_reports(status, review_id);`)
+
+	// =========================================================================
+	// RATING AGGREGATES
+	// =========================================================================
+
+	// Running totals of published reviews; stars[n] counts n-star reviews
+	queryMerchantRatingStats := `
+	CREATE TABLE IF NOT EXISTS merchant_rating_stats (
+		merchant_id TEXT PRIMARY KEY,
+		review_count INT NOT NULL DEFAULT 0,
+		rating_sum INT NOT NULL DEFAULT 0,
+		stars INT[] NOT NULL DEFAULT '{0,0,0,0,0}',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryMerchantRatingStats)
+
+	// Per-day totals (by review date, UTC) for the recent average
+	queryMerchantRatingDays := `
+	CREATE TABLE IF NOT EXISTS merchant_rating_days (
+		merchant_id TEXT NOT NULL,
+		day DATE NOT NULL,
+		review_count INT NOT NULL DEFAULT 0,
+		rating_sum INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (merchant_id, day)
+	);
+	`
+	DB.Exec(queryMerchantRatingDays)
+
+	// Backfill once, when the aggregates are first created
+	DB.Exec(backfillRatingDays)
+	DB.Exec(backfillRatingStats)
+
+	// =========================================================================
+	// REVIEW PHOTOS
+	// =========================================================================
+
+	// Keys locate the full-size and thumbnail files in the image store
+	queryReviewPhotos := `
+	CREATE TABLE IF NOT EXISTS review_photos (
+		id SERIAL PRIMARY KEY,
+		review_id INT NOT NULL REFERENCES reviews(id),
+		user_id TEXT NOT NULL,
+		image_key TEXT NOT NULL,
+		thumbnail_key TEXT NOT NULL,
+		url TEXT NOT NULL,
+		thumbnail_url TEXT NOT NULL,
+		width INT NOT NULL,
+		height INT NOT NULL,
+		status TEXT DEFAULT 'published',
+		moderation_flags JSONB DEFAULT '[]',
+		moderation_reason TEXT,
+		moderated_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	DB.Exec(queryReviewPhotos)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_review_photos_review_status ON review_photos(review_id, status);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_review_photos_pending ON review_photos(status) WHERE status = 'pending';`)
+
+	// =========================================================================
+	// REVIEW HELPFULNESS VOTES
+	// =========================================================================
+
+	// One vote per user per review; the counts and the score "most helpful"
+	// sorts by are kept on the review
+	queryReviewVotes := `
+	CREATE TABLE IF NOT EXISTS review_votes (
+		review_id INT NOT NULL REFERENCES reviews(id),
+		user_id TEXT NOT NULL,
+		helpful BOOLEAN NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (review_id, user_id)
+	);
+	`
+	DB.Exec(queryReviewVotes)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS helpful_count INT DEFAULT 0;`)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS not_helpful_count INT DEFAULT 0;`)
+	DB.Exec(`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS helpful_score DOUBLE PRECISION DEFAULT 0;`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_reviews_merchant_created ON reviews(merchant_id, created_at DESC) WHERE status = 'published';`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_reviews_merchant_helpful ON reviews(merchant_id, helpful_score DESC) WHERE status = 'published';`)
+
+	// =========================================================================
+	// REVIEW FRAUD DETECTION
+	// =========================================================================
+
+	// Phone sign-in stores the number on the user
+	DB.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT;`)
+	DB.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified BOOLEAN DEFAULT FALSE;`)
+
+	// Install IDs the app sends in X-Device-ID, seen at sign-in and when reviewing
+	queryUserDevices := `
+	CREATE TABLE IF NOT EXISTS user_devices (
+		user_id TEXT NOT NULL,
+		device_id TEXT NOT NULL,
+		first_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, device_id)
+	);
+	`
+	DB.Exec(queryUserDevices)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_user_devices_device ON user_devices(device_id);`)
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_reviews_created_at ON reviews(created_at);`)
+
+	// =========================================================================
+	// REVIEW INSIGHTS
+	// =========================================================================
+
+	// Latest keyword and sentiment analysis of each merchant's review comments
+	queryMerchantReviewInsights := `
+	CREATE TABLE IF NOT EXISTS merchant_review_insights (
+		merchant_id TEXT PRIMARY KEY,
+		report JSONB NOT NULL,
+		computed_at TIMESTAMP NOT NULL
+	);
+	`
+	DB.Exec(queryMerchantReviewInsights)
 }
@@ -0,0 +1,181 @@
+// Package einvoice issues Taiwan e-invoices (電子發票). Issuing goes through
+// the Issuer interface so the government platform, or the value-added
+// center in front of it, can be swapped for the in-memory fake platform in
+// this package during tests and local development.
+package einvoice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSellerBAN = errors.New("invalid seller business number")
+	ErrInvalidCarrier   = errors.New("invalid mobile barcode carrier")
+	ErrAmountMismatch   = errors.New("sales and tax amounts do not add up to the total")
+)
+
+// CarrierTypeMobileBarcode is the carrier type code of mobile barcodes
+// (手機條碼).
+const CarrierTypeMobileBarcode = "3J0002"
+
+// TaxTypeTaxable is the regular 5% business tax.
+const TaxTypeTaxable = "1"
+
+var mobileBarcodePattern = regexp.MustCompile(`^/[0-9A-Z.+\-]{7}$`)
+
+// ValidMobileBarcode reports whether s is a well-formed mobile barcode: a
+// slash followed by seven of 0-9, A-Z, '.', '+' and '-'.
+func ValidMobileBarcode(s string) bool {
+	return mobileBarcodePattern.MatchString(s)
+}
+
+// ValidBAN checks a uniform business number (統一編號): eight digits whose
+// weighted digit sum is divisible by 5, with the special case for a 7 in
+// the seventh position.
+func ValidBAN(ban string) bool {
+	if len(ban) != 8 {
+		return false
+	}
+	weights := [8]int{1, 2, 1, 2, 1, 2, 4, 1}
+	sum := 0
+	for i, r := range ban {
+		if r < '0' || r > '9' {
+			return false
+		}
+		p := int(r-'0') * weights[i]
+		sum += p/10 + p%10
+	}
+	if sum%5 == 0 {
+		return true
+	}
+	return ban[6] == '7' && (sum+1)%5 == 0
+}
+
+// Period returns the two-month reporting period an invoice issued at t
+// belongs to, as ROC year and even month, e.g. "11502" for Jan-Feb 2026.
+func Period(t time.Time) string {
+	month := int(t.Month())
+	if month%2 == 1 {
+		month++
+	}
+	return fmt.Sprintf("%03d%02d", t.Year()-1911, month)
+}
+
+// Item is one invoice line. Amounts are whole dollars, tax included.
+type Item struct {
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   int64  `json:"unit_price"`
+	Amount      int64  `json:"amount"`
+}
+
+// Invoice is a B2C invoice to issue.
+type Invoice struct {
+	RelateNumber string    `json:"relate_number"` // our reference; reissuing it returns the same invoice
+	SellerBAN    string    `json:"seller_ban"`
+	SellerName   string    `json:"seller_name"`
+	CarrierType  string    `json:"carrier_type,omitempty"`
+	CarrierID    string    `json:"carrier_id,omitempty"`
+	Print        bool      `json:"print"` // no carrier: a paper proof is printed
+	Items        []Item    `json:"items"`
+	SalesAmount  int64     `json:"sales_amount"`
+	TaxType      string    `json:"tax_type"`
+	TaxRate      float64   `json:"tax_rate"`
+	TaxAmount    int64     `json:"tax_amount"`
+	TotalAmount  int64     `json:"total_amount"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
+
+// Validate checks what the platform would reject.
+func (inv Invoice) Validate() error {
+	if !ValidBAN(inv.SellerBAN) {
+		return ErrInvalidSellerBAN
+	}
+	if inv.CarrierType == CarrierTypeMobileBarcode && !ValidMobileBarcode(inv.CarrierID) {
+		return ErrInvalidCarrier
+	}
+	if inv.SalesAmount+inv.TaxAmount != inv.TotalAmount {
+		return ErrAmountMismatch
+	}
+	return nil
+}
+
+// Issued is an invoice the platform accepted.
+type Issued struct {
+	InvoiceNumber string    `json:"invoice_number"`
+	RandomNumber  string    `json:"random_number"`
+	InvoiceDate   time.Time `json:"invoice_date"`
+}
+
+// Issuer submits invoices to the e-invoice platform.
+type Issuer interface {
+	Issue(ctx context.Context, inv Invoice) (Issued, error)
+}
+
+// Client issues invoices through a value-added center's JSON API:
+// POST {baseURL}/v1/invoices with a bearer API key.
+type Client struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewClient returns an Issuer talking to baseURL.
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+func (c *Client) Issue(ctx context.Context, inv Invoice) (Issued, error) {
+	if err := inv.Validate(); err != nil {
+		return Issued{}, err
+	}
+	body, err := json.Marshal(inv)
+	if err != nil {
+		return Issued{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/invoices", bytes.NewReader(body))
+	if err != nil {
+		return Issued{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Issued{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return Issued{}, fmt.Errorf("einvoice issue: %d %s", resp.StatusCode, e.Error)
+	}
+	var issued Issued
+	err = json.NewDecoder(resp.Body).Decode(&issued)
+	return issued, err
+}
+
+// FromEnv returns the configured issuer, or nil when e-invoicing is off.
+//
+//	EINVOICE_API_URL, EINVOICE_API_KEY
+func FromEnv() Issuer {
+	if base := os.Getenv("EINVOICE_API_URL"); base != "" {
+		return NewClient(base, os.Getenv("EINVOICE_API_KEY"))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package einvoice
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidBAN(t *testing.T) {
+	for _, ban := range []string{"22099131", "04595257", "10000073"} {
+		assert.True(t, ValidBAN(ban), ban)
+	}
+	for _, ban := range []string{"22099132", "2209913", "220991310", "2209913a", ""} {
+		assert.False(t, ValidBAN(ban), ban)
+	}
+}
+
+func TestValidMobileBarcode(t *testing.T) {
+	for _, code := range []string{"/ABC+123", "/0000000", "/A.B-C+1"} {
+		assert.True(t, ValidMobileBarcode(code), code)
+	}
+	for _, code := range []string{"ABC+1234", "/abc+123", "/ABC123", "/ABC12345", "/ABC_123"} {
+		assert.False(t, ValidMobileBarcode(code), code)
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	assert.Equal(t, "11502", Period(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "11502", Period(time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "11512", Period(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func testInvoice() Invoice {
+	return Invoice{
+		RelateNumber: "R20260302-000007",
+		SellerBAN:    "22099131",
+		SellerName:   "好食麵包店",
+		CarrierType:  CarrierTypeMobileBarcode,
+		CarrierID:    "/ABC+123",
+		Items:        []Item{{Description: "Bento", Quantity: 1, UnitPrice: 105, Amount: 105}},
+		SalesAmount:  100,
+		TaxType:      TaxTypeTaxable,
+		TaxRate:      0.05,
+		TaxAmount:    5,
+		TotalAmount:  105,
+		IssuedAt:     time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestClientIssuesThroughFakePlatform(t *testing.T) {
+	platform := NewFakePlatform("key")
+	srv := httptest.NewServer(platform)
+	defer srv.Close()
+	client := NewClient(srv.URL, "key")
+	ctx := context.Background()
+
+	issued, err := client.Issue(ctx, testInvoice())
+	require.NoError(t, err)
+	assert.Equal(t, "AB00000001", issued.InvoiceNumber)
+	assert.Len(t, issued.RandomNumber, 4)
+	inv, ok := platform.Invoice(issued.InvoiceNumber)
+	require.True(t, ok)
+	assert.Equal(t, "/ABC+123", inv.CarrierID)
+
+	// Resubmitting the same receipt returns the same invoice
+	again, err := client.Issue(ctx, testInvoice())
+	require.NoError(t, err)
+	assert.Equal(t, issued, again)
+
+	other := testInvoice()
+	other.RelateNumber = "R20260302-000008"
+	next, err := client.Issue(ctx, other)
+	require.NoError(t, err)
+	assert.Equal(t, "AB00000002", next.InvoiceNumber)
+}
+
+func TestClientRejectsInvalidInvoices(t *testing.T) {
+	platform := NewFakePlatform("key")
+	srv := httptest.NewServer(platform)
+	defer srv.Close()
+	ctx := context.Background()
+
+	inv := testInvoice()
+	inv.SellerBAN = "12345678"
+	_, err := NewClient(srv.URL, "key").Issue(ctx, inv)
+	assert.ErrorIs(t, err, ErrInvalidSellerBAN)
+
+	inv = testInvoice()
+	inv.CarrierID = "/abc"
+	_, err = NewClient(srv.URL, "key").Issue(ctx, inv)
+	assert.ErrorIs(t, err, ErrInvalidCarrier)
+
+	inv = testInvoice()
+	inv.TaxAmount = 6
+	_, err = NewClient(srv.URL, "key").Issue(ctx, inv)
+	assert.ErrorIs(t, err, ErrAmountMismatch)
+
+	_, err = NewClient(srv.URL, "wrong").Issue(ctx, testInvoice())
+	assert.ErrorContains(t, err, "401")
+}
@@ -0,0 +1,93 @@
+package einvoice
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// FakePlatform is an in-memory e-invoice platform speaking the API Client
+// uses. It allocates numbers from a single track per period, checks what
+// the real platform checks, and returns the same invoice when a relate
+// number is issued twice.
+type FakePlatform struct {
+	APIKey string
+	Track  string // two-letter track, e.g. "AB"
+
+	mu       sync.Mutex
+	seq      map[string]int
+	issued   map[string]Issued
+	invoices map[string]Invoice
+}
+
+// NewFakePlatform returns a fake platform accepting apiKey.
+func NewFakePlatform(apiKey string) *FakePlatform {
+	return &FakePlatform{
+		APIKey:   apiKey,
+		Track:    "AB",
+		seq:      map[string]int{},
+		issued:   map[string]Issued{},
+		invoices: map[string]Invoice{},
+	}
+}
+
+// Invoice returns an issued invoice by number, for assertions.
+func (f *FakePlatform) Invoice(number string) (Invoice, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	inv, ok := f.invoices[number]
+	return inv, ok
+}
+
+func fakeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func (f *FakePlatform) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/invoices" {
+		fakeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+f.APIKey {
+		fakeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+	var inv Invoice
+	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+		fakeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if err := inv.Validate(); err != nil {
+		fakeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if inv.RelateNumber == "" || len(inv.Items) == 0 {
+		fakeError(w, http.StatusUnprocessableEntity, "relate_number and items are required")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := inv.SellerBAN + "/" + inv.RelateNumber
+	issued, ok := f.issued[key]
+	if !ok {
+		period := Period(inv.IssuedAt)
+		f.seq[period]++
+		random, _ := rand.Int(rand.Reader, big.NewInt(10000))
+		issued = Issued{
+			InvoiceNumber: fmt.Sprintf("%s%08d", strings.ToUpper(f.Track), f.seq[period]),
+			RandomNumber:  fmt.Sprintf("%04d", random.Int64()),
+			InvoiceDate:   inv.IssuedAt,
+		}
+		f.issued[key] = issued
+		f.invoices[issued.InvoiceNumber] = inv
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(issued)
+}
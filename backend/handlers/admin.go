@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRequired guards platform administration routes with the shared
+// ADMIN_API_KEY, sent in the X-Admin-Key header. Admin routes are disabled
+// when the key is not configured.
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := os.Getenv("ADMIN_API_KEY")
+		given := c.GetHeader("X-Admin-Key")
+		if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(given)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -50,6 +50,8 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	recordDevice(c, userID)
+
 	// 2. Generate JWT
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id":     userID,
@@ -83,6 +85,7 @@ func UpdateMerchantProfile(c *gin.Context) {
 		BusinessHoursClose string  `json:"business_hours_close"`
 		Category           string  `json:"category"`
 		Description        string  `json:"description"`
+		Timezone           string  `json:"timezone"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -90,13 +93,23 @@ func UpdateMerchantProfile(c *gin.Context) {
 		return
 	}
 
+	// An omitted timezone keeps the merchant's current one, or the default
+	// for a new shop
+	if input.Timezone != "" {
+		if _, err := time.LoadLocation(input.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+			return
+		}
+	}
+
 	// Upsert merchant profile with new fields
 	_, err := db.DB.Exec(`
-		INSERT INTO merchants (user_id, shop_name, address, latitude, longitude, phone, email, business_hours_open, business_hours_close, category, description)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO merchants (user_id, shop_name, address, latitude, longitude, phone, email, business_hours_open, business_hours_close, category, description, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE(NULLIF($12, ''), $13))
 		ON CONFLICT (user_id) DO UPDATE 
-		SET shop_name=$2, address=$3, latitude=$4, longitude=$5, phone=$6, email=$7, business_hours_open=$8, business_hours_close=$9, category=$10, description=$11
-	`, input.UserID, input.ShopName, input.Address, input.Latitude, input.Longitude, input.Phone, input.Email, input.BusinessHoursOpen, input.BusinessHoursClose, input.Category, input.Description)
+		SET shop_name=$2, address=$3, latitude=$4, longitude=$5, phone=$6, email=$7, business_hours_open=$8, business_hours_close=$9, category=$10, description=$11,
+		    timezone=COALESCE(NULLIF($12, ''), merchants.timezone, $13)
+	`, input.UserID, input.ShopName, input.Address, input.Latitude, input.Longitude, input.Phone, input.Email, input.BusinessHoursOpen, input.BusinessHoursClose, input.Category, input.Description, input.Timezone, defaultMerchantTimezone)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update merchant profile"})
@@ -87,3 +87,24 @@ func TestUpdateMerchantProfileMissingFields(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestUpdateMerchantProfileInvalidTimezone(t *testing.T) {
+	router := gin.New()
+	router.POST("/merchant/setup", UpdateMerchantProfile)
+
+	body := map[string]string{
+		"user_id":   "test_user",
+		"shop_name": "Test Shop",
+		"address":   "Taipei",
+		"timezone":  "Mars/Olympus",
+	}
+	jsonBody, _ := json.Marshal(body)
+
+	req, _ := http.NewRequest("POST", "/merchant/setup", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
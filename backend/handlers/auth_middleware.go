@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"food-platform-backend/db"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AuthRequired validates the "Authorization: Bearer <token>" header issued
+// by Login/VerifySMSCode and stores the caller's user_id in the context.
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+			return jwtSecret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		userID, _ := claims["user_id"].(string)
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		isMerchant, _ := claims["is_merchant"].(bool)
+
+		c.Set("user_id", userID)
+		c.Set("is_merchant", isMerchant)
+		c.Next()
+	}
+}
+
+// requireMerchant writes 403 unless the authenticated caller has set up a
+// shop. It reads the database rather than the token's is_merchant claim,
+// which is stale for users who became merchants after signing in.
+func requireMerchant(c *gin.Context) bool {
+	var ok bool
+	err := db.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM users u JOIN merchants m ON m.user_id = u.id WHERE u.id = $1 AND u.is_merchant)
+	`, c.GetString("user_id")).Scan(&ok)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Merchant account required"})
+		return false
+	}
+	return true
+}
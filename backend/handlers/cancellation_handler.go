@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errCancelTooLate = errors.New("pickup window has ended, the order can no longer be cancelled")
+
+// Consumers with lateCancellationLimit late cancellations in the last
+// lateCancellationWindow get no refund on further late cancellations.
+const (
+	lateCancellationLimit  = 3
+	lateCancellationWindow = 30 * 24 * time.Hour
+)
+
+// defaultCancellationPolicy applies to merchants without their own policy.
+var defaultCancellationPolicy = models.CancellationPolicy{
+	CutoffMinutes:      60,
+	EarlyRefundPercent: 100,
+	LateRefundPercent:  0,
+}
+
+// cancelReasons lists the reason codes each side may give.
+var cancelReasons = map[models.OrderStatus]map[string]bool{
+	models.OrderStatusCancelledByConsumer: {
+		models.CancelReasonChangedMind:      true,
+		models.CancelReasonCantMakePickup:   true,
+		models.CancelReasonOrderedByMistake: true,
+		models.CancelReasonOther:            true,
+	},
+	models.OrderStatusCancelledByMerchant: {
+		models.CancelReasonDamaged:    true,
+		models.CancelReasonOutOfStock: true,
+		models.CancelReasonShopClosed: true,
+		models.CancelReasonOther:      true,
+	},
+}
+
+// loadCancellationPolicy returns the merchant's policy or the defaults.
+func loadCancellationPolicy(q queryer, merchantID string) (models.CancellationPolicy, error) {
+	p := models.CancellationPolicy{MerchantID: merchantID}
+	err := q.QueryRow(`
+		SELECT cutoff_minutes, early_refund_percent, late_refund_percent
+		FROM cancellation_policies WHERE merchant_id = $1
+	`, merchantID).Scan(&p.CutoffMinutes, &p.EarlyRefundPercent, &p.LateRefundPercent)
+	if err == sql.ErrNoRows {
+		p = defaultCancellationPolicy
+		p.MerchantID = merchantID
+		return p, nil
+	}
+	return p, err
+}
+
+// cancellationTerms decides whether a cancellation at now is late and what
+// share of the price is refunded. Merchant cancellations are always refunded
+// in full; consumers past the cutoff get the late rate, or nothing once they
+// have priorLate late cancellations at the limit.
+func cancellationTerms(p models.CancellationPolicy, next models.OrderStatus, pickupStart, pickupEnd, now time.Time, priorLate int) (late bool, percent float64, err error) {
+	if next == models.OrderStatusCancelledByMerchant {
+		return false, 100, nil
+	}
+	if !now.Before(pickupEnd) {
+		return false, 0, errCancelTooLate
+	}
+	cutoff := pickupStart.Add(-time.Duration(p.CutoffMinutes) * time.Minute)
+	if !now.After(cutoff) {
+		return false, p.EarlyRefundPercent, nil
+	}
+	if priorLate >= lateCancellationLimit {
+		return true, 0, nil
+	}
+	return true, p.LateRefundPercent, nil
+}
+
+// restockProduct puts a sold product back on sale while its listing is
+// still live.
+func restockProduct(tx *sql.Tx, productID int, now time.Time) error {
+	_, err := tx.Exec("UPDATE products SET status = 'AVAILABLE' WHERE id = $1 AND status = 'SOLD' AND expiry_date > $2", productID, now.UTC())
+	return err
+}
+
+// refundAmount is percent of price, rounded to cents.
+func refundAmount(price, percent float64) float64 {
+	return math.Round(price*percent) / 100
+}
+
+// =========================================================================
+// CANCELLATIONS
+// =========================================================================
+
+// CancelOrder - POST /orders/:id/cancel (consumer)
+func CancelOrder(c *gin.Context) {
+	var input cancelInput
+	if !bindCancelInput(c, &input) {
+		return
+	}
+
+	consumerID := c.GetString("user_id")
+	cancelOrder(c, models.OrderStatusCancelledByConsumer, input.ReasonCode, input.Reason, func(o orderRef) bool {
+		return o.ConsumerID == consumerID
+	})
+}
+
+// MerchantCancelOrder - POST /merchant/orders/:id/cancel
+// The listing goes back on sale, e.g. when the shop could not honour the order.
+func MerchantCancelOrder(c *gin.Context) {
+	var input cancelInput
+	if !bindCancelInput(c, &input) {
+		return
+	}
+
+	merchantID := c.GetString("user_id")
+	cancelOrder(c, models.OrderStatusCancelledByMerchant, input.ReasonCode, input.Reason, func(o orderRef) bool {
+		return o.MerchantID == merchantID
+	})
+}
+
+type cancelInput struct {
+	ReasonCode string `json:"reason_code"`
+	Reason     string `json:"reason"`
+}
+
+// bindCancelInput reads the optional cancellation body.
+func bindCancelInput(c *gin.Context, input *cancelInput) bool {
+	if c.Request.ContentLength == 0 {
+		return true
+	}
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return false
+	}
+	return true
+}
+
+// cancelOrder cancels the order in the :id param under the merchant's
+// policy, returns the product to AVAILABLE while it is still listed and
+// records the reason and refund.
+func cancelOrder(c *gin.Context, next models.OrderStatus, reasonCode, reason string, owns func(orderRef) bool) {
+	if reasonCode == "" {
+		reasonCode = models.CancelReasonOther
+	}
+	if !cancelReasons[next][reasonCode] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason_code " + reasonCode})
+		return
+	}
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := lockOrder(tx, orderID)
+	if err == nil && !owns(o) {
+		err = errOrderNotFound
+	}
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	var price float64
+	var pickupStart, pickupEnd time.Time
+	err = tx.QueryRow(`
+		SELECT COALESCE(o.price_paid, p.current_price), COALESCE(p.pickup_start, p.created_at), COALESCE(p.pickup_end, p.expiry_date)
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = $1
+	`, orderID).Scan(&price, &pickupStart, &pickupEnd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	policy, err := loadCancellationPolicy(tx, o.MerchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	now := time.Now()
+	priorLate := 0
+	if next == models.OrderStatusCancelledByConsumer {
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM order_cancellations
+			WHERE consumer_id = $1 AND cancelled_by = 'consumer' AND is_late AND created_at > $2
+		`, o.ConsumerID, now.Add(-lateCancellationWindow).UTC()).Scan(&priorLate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
+
+	late, percent, err := cancellationTerms(policy, next, pickupStart, pickupEnd, now, priorLate)
+	if err == nil {
+		err = transitionOrder(tx, &o, next)
+	}
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	if err := restockProduct(tx, o.ProductID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restock product"})
+		return
+	}
+
+	cancelledBy := "consumer"
+	if next == models.OrderStatusCancelledByMerchant {
+		cancelledBy = "merchant"
+	}
+	cancellation := models.OrderCancellation{
+		OrderID:       o.ID,
+		CancelledBy:   cancelledBy,
+		ReasonCode:    reasonCode,
+		Reason:        reason,
+		IsLate:        late,
+		RefundPercent: percent,
+		RefundAmount:  refundAmount(price, percent),
+		RefundStatus:  "not_required",
+	}
+	// Only money actually taken through a provider needs refunding
+	if o.PaymentStatus == models.PaymentStatusPaid && cancellation.RefundAmount > 0 {
+		cancellation.RefundStatus = "pending"
+	}
+	err = tx.QueryRow(`
+		INSERT INTO order_cancellations (order_id, consumer_id, merchant_id, cancelled_by, reason_code, reason, is_late, refund_percent, refund_amount, refund_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, o.ID, o.ConsumerID, o.MerchantID, cancelledBy, reasonCode, reason, late, percent, cancellation.RefundAmount, cancellation.RefundStatus).
+		Scan(&cancellation.ID, &cancellation.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cancellation"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	if cancellation.RefundStatus == "pending" {
+		// The reconciler retries refunds that fail here
+		if err := refundOrderPayment(o.ID, cancellation.RefundAmount); err == nil {
+			cancellation.RefundStatus = "succeeded"
+		}
+	}
+
+	notifyOrderTransition(o, reason)
+	resp := gin.H{"message": "Order cancelled", "order_id": o.ID, "status": o.Status, "cancellation": cancellation}
+	if late {
+		resp["late_cancellations"] = priorLate + 1
+		if priorLate+1 >= lateCancellationLimit {
+			resp["warning"] = fmt.Sprintf("%d late cancellations in %d days: further late cancellations are not refunded",
+				priorLate+1, int(lateCancellationWindow.Hours()/24))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// =========================================================================
+// CANCELLATION POLICY & REPORTING
+// =========================================================================
+
+// GetCancellationPolicy - GET /merchant/:merchant_id/cancellation-policy
+func GetCancellationPolicy(c *gin.Context) {
+	policy, err := loadCancellationPolicy(db.DB, c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load cancellation policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateCancellationPolicy - PUT /merchant/cancellation-policy
+func UpdateCancellationPolicy(c *gin.Context) {
+	var input struct {
+		CutoffMinutes      int     `json:"cutoff_minutes" binding:"min=0,max=1440"`
+		EarlyRefundPercent float64 `json:"early_refund_percent" binding:"min=0,max=100"`
+		LateRefundPercent  float64 `json:"late_refund_percent" binding:"min=0,max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": bindingFieldErrors(err, &input)})
+		return
+	}
+	if input.LateRefundPercent > input.EarlyRefundPercent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "late_refund_percent cannot exceed early_refund_percent"})
+		return
+	}
+	if !requireMerchant(c) {
+		return
+	}
+
+	_, err := db.DB.Exec(`
+		INSERT INTO cancellation_policies (merchant_id, cutoff_minutes, early_refund_percent, late_refund_percent)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (merchant_id) DO UPDATE
+		SET cutoff_minutes=$2, early_refund_percent=$3, late_refund_percent=$4, updated_at=CURRENT_TIMESTAMP
+	`, c.GetString("user_id"), input.CutoffMinutes, input.EarlyRefundPercent, input.LateRefundPercent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cancellation policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cancellation policy updated"})
+}
+
+// GetCancellationReport - GET /merchant/cancellations/report?from=&to=
+// Breaks the shop's cancellations down by side and reason, and lists
+// consumers who repeatedly cancelled late.
+func GetCancellationReport(c *gin.Context) {
+	merchantID := c.GetString("user_id")
+	loc := loadLocation("")
+
+	where := " WHERE merchant_id = $1"
+	args := []interface{}{merchantID}
+	for _, bound := range []struct {
+		param string
+		op    string
+		upper bool
+	}{{"from", ">=", false}, {"to", "<", true}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseDateBound(value, loc, bound.upper)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": bound.param + " must be YYYY-MM-DD or RFC3339"})
+			return
+		}
+		args = append(args, t.UTC())
+		where += " AND created_at " + bound.op + " $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT cancelled_by, reason_code, COUNT(*), COUNT(*) FILTER (WHERE is_late), COALESCE(SUM(refund_amount), 0)
+		FROM order_cancellations`+where+`
+		GROUP BY cancelled_by, reason_code
+		ORDER BY cancelled_by, COUNT(*) DESC
+	`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+	defer rows.Close()
+
+	type reasonRow struct {
+		CancelledBy string  `json:"cancelled_by"`
+		ReasonCode  string  `json:"reason_code"`
+		Count       int     `json:"count"`
+		Late        int     `json:"late"`
+		Refunded    float64 `json:"refunded"`
+	}
+	reasons := []reasonRow{}
+	total, totalLate, totalRefunded := 0, 0, 0.0
+	for rows.Next() {
+		var r reasonRow
+		if err := rows.Scan(&r.CancelledBy, &r.ReasonCode, &r.Count, &r.Late, &r.Refunded); err != nil {
+			continue
+		}
+		total += r.Count
+		totalLate += r.Late
+		totalRefunded += r.Refunded
+		reasons = append(reasons, r)
+	}
+
+	type repeatRow struct {
+		ConsumerID string `json:"consumer_id"`
+		Late       int    `json:"late"`
+	}
+	repeat := []repeatRow{}
+	repeatRows, err := db.DB.Query(`
+		SELECT consumer_id, COUNT(*) FROM order_cancellations`+where+` AND cancelled_by = 'consumer' AND is_late
+		GROUP BY consumer_id HAVING COUNT(*) >= 2
+		ORDER BY COUNT(*) DESC
+	`, args...)
+	if err == nil {
+		defer repeatRows.Close()
+		for repeatRows.Next() {
+			var r repeatRow
+			if err := repeatRows.Scan(&r.ConsumerID, &r.Late); err == nil {
+				repeat = append(repeat, r)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":                 total,
+		"late":                  totalLate,
+		"refunded":              math.Round(totalRefunded*100) / 100,
+		"by_reason":             reasons,
+		"repeat_late_consumers": repeat,
+	})
+}
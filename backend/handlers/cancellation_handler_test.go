@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// CANCELLATION TESTS
+// =========================================================================
+
+func TestCancellationTerms(t *testing.T) {
+	policy := models.CancellationPolicy{CutoffMinutes: 60, EarlyRefundPercent: 100, LateRefundPercent: 50}
+	pickupStart := time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)
+	pickupEnd := pickupStart.Add(2 * time.Hour)
+	consumer := models.OrderStatusCancelledByConsumer
+
+	late, percent, err := cancellationTerms(policy, consumer, pickupStart, pickupEnd, pickupStart.Add(-2*time.Hour), 0)
+	assert.NoError(t, err)
+	assert.False(t, late)
+	assert.Equal(t, 100.0, percent)
+
+	// Inside the cutoff
+	late, percent, err = cancellationTerms(policy, consumer, pickupStart, pickupEnd, pickupStart.Add(-30*time.Minute), 0)
+	assert.NoError(t, err)
+	assert.True(t, late)
+	assert.Equal(t, 50.0, percent)
+
+	// Repeat offenders lose the late refund
+	late, percent, _ = cancellationTerms(policy, consumer, pickupStart, pickupEnd, pickupStart.Add(-30*time.Minute), lateCancellationLimit)
+	assert.True(t, late)
+	assert.Equal(t, 0.0, percent)
+
+	// After the pickup window it is a no-show, not a cancellation
+	_, _, err = cancellationTerms(policy, consumer, pickupStart, pickupEnd, pickupEnd, 0)
+	assert.ErrorIs(t, err, errCancelTooLate)
+
+	// Merchants always refund in full
+	late, percent, err = cancellationTerms(policy, models.OrderStatusCancelledByMerchant, pickupStart, pickupEnd, pickupEnd.Add(time.Hour), 5)
+	assert.NoError(t, err)
+	assert.False(t, late)
+	assert.Equal(t, 100.0, percent)
+}
+
+func TestRefundAmount(t *testing.T) {
+	assert.Equal(t, 45.5, refundAmount(91, 50))
+	assert.Equal(t, 33.33, refundAmount(99.99, 33.33))
+	assert.Equal(t, 0.0, refundAmount(120, 0))
+}
+
+func TestCancelOrderInvalidReason(t *testing.T) {
+	router := gin.New()
+	router.POST("/orders/:id/cancel", AuthRequired(), CancelOrder)
+	router.POST("/merchant/orders/:id/cancel", AuthRequired(), MerchantCancelOrder)
+
+	// Consumers cannot claim merchant-side reasons
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/orders/1/cancel", bytes.NewBufferString(`{"reason_code": "damaged"}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "user1"))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "reason_code")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/merchant/orders/1/cancel", bytes.NewBufferString(`{"reason_code": "changed_mind"}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "m1"))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCancelOrderRequiresToken(t *testing.T) {
+	router := gin.New()
+	router.POST("/orders/:id/cancel", AuthRequired(), CancelOrder)
+	router.POST("/merchant/orders/:id/cancel", AuthRequired(), MerchantCancelOrder)
+
+	for path, body := range map[string]string{
+		"/orders/1/cancel":          `{"consumer_id": "user1"}`,
+		"/merchant/orders/1/cancel": `{"merchant_id": "m1"}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+}
+
+func TestUpdateCancellationPolicyValidation(t *testing.T) {
+	r := gin.New()
+	r.PUT("/merchant/cancellation-policy", AuthRequired(), UpdateCancellationPolicy)
+	token := "Bearer " + signedTestToken(t, "merchant-1")
+
+	for _, body := range []string{
+		`{"cutoff_minutes": 60, "early_refund_percent": 150, "late_refund_percent": 0}`,
+		`{"cutoff_minutes": 60, "early_refund_percent": 50, "late_refund_percent": 80}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/merchant/cancellation-policy", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", token)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"database/sql"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// cartProduct is the locked state of one product in a cart.
+type cartProduct struct {
+	merchantID string
+	status     string
+	price      float64
+	expiry     time.Time
+	pickupEnd  sql.NullTime
+}
+
+// normalizeCart sorts product IDs and drops duplicates. Locking in ID order
+// keeps concurrent checkouts from deadlocking on each other's rows.
+func normalizeCart(ids []int) []int {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	out := sorted[:0]
+	for i, id := range sorted {
+		if i == 0 || id != sorted[i-1] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// cartItemErrors checks every cart line against the locked products and
+// returns one error per line that cannot be bought. All lines must come from
+// the merchant of the first product found.
+func cartItemErrors(ids []int, products map[int]cartProduct, now time.Time) (merchantID string, errs []models.CheckoutItemError) {
+	errs = []models.CheckoutItemError{}
+	for _, id := range ids {
+		if p, ok := products[id]; ok && merchantID == "" {
+			merchantID = p.merchantID
+		}
+	}
+	for _, id := range ids {
+		p, ok := products[id]
+		switch {
+		case !ok:
+			errs = append(errs, models.CheckoutItemError{ProductID: id, Error: "Product not found"})
+		case p.merchantID != merchantID:
+			errs = append(errs, models.CheckoutItemError{ProductID: id, Error: "All items must be from the same shop"})
+		default:
+			if reason := purchaseBlockReason(p.status, p.expiry, p.pickupEnd, now); reason != "" {
+				errs = append(errs, models.CheckoutItemError{ProductID: id, Error: reason})
+			}
+		}
+	}
+	return merchantID, errs
+}
+
+// =========================================================================
+// CART CHECKOUT
+// =========================================================================
+
+// Checkout - POST /checkout
+// Buys several products from one merchant atomically: either every line
+// becomes an order under one checkout, or nothing is bought and each
+// unavailable line is reported.
+func Checkout(c *gin.Context) {
+	var input struct {
+		ProductIDs []int `json:"product_ids" binding:"required,min=1,max=20"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "1-20 product IDs required"})
+		return
+	}
+	consumerID := c.GetString("user_id")
+	ids := normalizeCart(input.ProductIDs)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(merchant_id, ''), status, current_price, expiry_date, pickup_end
+		FROM products WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(ids))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	products := map[int]cartProduct{}
+	for rows.Next() {
+		var id int
+		var p cartProduct
+		if err := rows.Scan(&id, &p.merchantID, &p.status, &p.price, &p.expiry, &p.pickupEnd); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		products[id] = p
+	}
+	rows.Close()
+
+	merchantID, itemErrors := cartItemErrors(ids, products, time.Now())
+	if len(itemErrors) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Some items are unavailable, nothing was bought", "items": itemErrors})
+		return
+	}
+	if err := checkConsumerMayBuy(tx, consumerID, ids, false, time.Now()); err != nil {
+		respondPurchaseBlocked(c, err)
+		return
+	}
+
+	checkout := models.Checkout{ConsumerID: consumerID, MerchantID: merchantID, OrderIDs: []int{}}
+	for _, id := range ids {
+		checkout.Total += products[id].price
+	}
+	err = tx.QueryRow(`
+		INSERT INTO checkouts (consumer_id, merchant_id, total, item_count)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, consumerID, merchantID, checkout.Total, len(ids)).Scan(&checkout.ID, &checkout.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checkout"})
+		return
+	}
+
+	for _, id := range ids {
+		if _, err := tx.Exec("UPDATE products SET status = 'SOLD' WHERE id = $1", id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+			return
+		}
+		orderID, err := createOrder(tx, id, consumerID)
+		if err == nil {
+			_, err = tx.Exec("UPDATE orders SET checkout_id = $1 WHERE id = $2", checkout.ID, orderID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			return
+		}
+		checkout.OrderIDs = append(checkout.OrderIDs, orderID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, checkout)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// CART CHECKOUT TESTS
+// =========================================================================
+
+func TestNormalizeCart(t *testing.T) {
+	assert.Equal(t, []int{1, 3, 7}, normalizeCart([]int{7, 3, 1, 3, 7}))
+	assert.Equal(t, []int{5}, normalizeCart([]int{5}))
+}
+
+func TestCartItemErrors(t *testing.T) {
+	now := time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)
+	later := now.Add(2 * time.Hour)
+	products := map[int]cartProduct{
+		1: {merchantID: "m1", status: "AVAILABLE", price: 50, expiry: later},
+		2: {merchantID: "m1", status: "SOLD", price: 45, expiry: later},
+		3: {merchantID: "m2", status: "AVAILABLE", price: 30, expiry: later},
+		4: {merchantID: "m1", status: "AVAILABLE", price: 20, expiry: now.Add(-time.Minute)},
+	}
+
+	merchantID, errs := cartItemErrors([]int{1, 2, 3, 4, 9}, products, now)
+	assert.Equal(t, "m1", merchantID)
+	assert.Equal(t, []models.CheckoutItemError{
+		{ProductID: 2, Error: "Product already sold"},
+		{ProductID: 3, Error: "All items must be from the same shop"},
+		{ProductID: 4, Error: "Product expired"},
+		{ProductID: 9, Error: "Product not found"},
+	}, errs)
+
+	_, errs = cartItemErrors([]int{1}, products, now)
+	assert.Empty(t, errs)
+}
+
+func TestCheckoutValidation(t *testing.T) {
+	r := gin.New()
+	r.POST("/checkout", AuthRequired(), Checkout)
+
+	for _, body := range []string{
+		`{}`,
+		`{"product_ids": []}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/checkout", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "c1"))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	// Limits are keyed on the caller, so the body cannot name another consumer
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/checkout", bytes.NewBufferString(`{"consumer_id": "c1", "product_ids": [1, 2]}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
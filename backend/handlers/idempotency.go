@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"food-platform-backend/db"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader          = "Idempotency-Key"
+	maxIdempotencyKey          = 255
+	defaultIdempotencyTTLHours = 24
+	// A request still marked in progress after this long is assumed to have
+	// died, and a retry may take the key over.
+	idempotencyStaleAfter = time.Minute
+)
+
+// idempotencyTTL is how long stored responses are replayed, overridable with
+// IDEMPOTENCY_TTL_HOURS.
+func idempotencyTTL() time.Duration {
+	hours := defaultIdempotencyTTLHours
+	if v, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_TTL_HOURS")); err == nil && v > 0 {
+		hours = v
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// idempotencyScope is the namespace a key is unique in: the route and, on
+// authenticated routes, the caller, so different users' keys never meet.
+func idempotencyScope(method, route, userID string) string {
+	scope := method + " " + route
+	if userID != "" {
+		scope += " " + userID
+	}
+	return scope
+}
+
+// requestFingerprint identifies a request by method, path, query and body,
+// so a key reused for a different request can be told apart from a retry.
+func requestFingerprint(method, path, rawQuery string, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, method+"\n"+path+"?"+rawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingWriter keeps a copy of the response so it can be stored.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent makes a mutating endpoint safe to retry. Requests carrying an
+// Idempotency-Key header run once per key, route and caller; later requests
+// with the same key and the same fingerprint get the stored response
+// replayed. Requests without the header are handled as usual. On
+// authenticated routes it goes after AuthRequired, which sets the caller.
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := idempotencyScope(c.Request.Method, c.FullPath(), c.GetString("user_id"))
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, body)
+		now := time.Now().UTC()
+
+		claimed, err := claimIdempotencyKey(key, scope, fingerprint, now)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Idempotency store unavailable"})
+			return
+		}
+		if !claimed {
+			replayIdempotentResponse(c, key, scope, fingerprint)
+			return
+		}
+
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// Server errors are not stored so that the client can retry them
+		if w.Status() >= http.StatusInternalServerError {
+			db.DB.Exec("DELETE FROM idempotency_keys WHERE idempotency_key = $1 AND scope = $2", key, scope)
+			return
+		}
+		_, err = db.DB.Exec(`
+			UPDATE idempotency_keys
+			SET state = 'completed', status_code = $3, content_type = $4, response_body = $5
+			WHERE idempotency_key = $1 AND scope = $2
+		`, key, scope, w.Status(), w.Header().Get("Content-Type"), w.body.Bytes())
+		if err != nil {
+			log.Println("Idempotency: failed to store response:", err)
+		}
+	}
+}
+
+// claimIdempotencyKey records the key as in progress, or takes over an
+// expired key or a stale in-progress claim. It reports false if another request owns the key.
+func claimIdempotencyKey(key, scope, fingerprint string, now time.Time) (bool, error) {
+	res, err := db.DB.Exec(`
+		INSERT INTO idempotency_keys (idempotency_key, scope, fingerprint, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (idempotency_key, scope) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, state = 'in_progress', status_code = NULL,
+		    content_type = NULL, response_body = NULL,
+		    created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < $5
+		   OR (idempotency_keys.state = 'in_progress'
+		       AND idempotency_keys.fingerprint = EXCLUDED.fingerprint
+		       AND idempotency_keys.created_at < $6)
+	`, key, scope, fingerprint, now.Add(idempotencyTTL()), now, now.Add(-idempotencyStaleAfter))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// replayIdempotentResponse answers a request whose key is already taken.
+func replayIdempotentResponse(c *gin.Context, key, scope, fingerprint string) {
+	var storedFingerprint, state string
+	var status sql.NullInt64
+	var contentType sql.NullString
+	var body []byte
+	err := db.DB.QueryRow(`
+		SELECT fingerprint, state, status_code, content_type, response_body
+		FROM idempotency_keys WHERE idempotency_key = $1 AND scope = $2
+	`, key, scope).Scan(&storedFingerprint, &state, &status, &contentType, &body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Idempotency store unavailable"})
+		return
+	}
+
+	switch {
+	case storedFingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+	case state != "completed":
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(int(status.Int64), contentType.String, body)
+		c.Abort()
+	}
+}
+
+// =========================================================================
+// IDEMPOTENCY SWEEP
+// =========================================================================
+
+// StartIdempotencySweeper deletes expired idempotency keys every interval.
+func StartIdempotencySweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			res, err := db.DB.Exec("DELETE FROM idempotency_keys WHERE expires_at < $1", time.Now().UTC())
+			if err != nil {
+				log.Println("Idempotency sweep:", err)
+			} else if n, _ := res.RowsAffected(); n > 0 {
+				log.Printf("Idempotency sweep: removed %d expired keys", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// IDEMPOTENCY TESTS
+// =========================================================================
+
+func TestIdempotentWithoutKeyPassesThrough(t *testing.T) {
+	calls := 0
+	r := gin.New()
+	r.POST("/purchase/:id", Idempotent(), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/purchase/1", bytes.NewBufferString(`{"consumer_id": "c1"}`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotentRejectsLongKey(t *testing.T) {
+	r := gin.New()
+	r.POST("/purchase/:id", Idempotent(), func(c *gin.Context) {
+		t.Fatal("handler must not run")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/purchase/1", bytes.NewBufferString(`{}`))
+	req.Header.Set(idempotencyHeader, strings.Repeat("k", maxIdempotencyKey+1))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	base := requestFingerprint("POST", "/purchase/1", "", []byte(`{"consumer_id": "c1"}`))
+	assert.Equal(t, base, requestFingerprint("POST", "/purchase/1", "", []byte(`{"consumer_id": "c1"}`)))
+	assert.NotEqual(t, base, requestFingerprint("POST", "/purchase/2", "", []byte(`{"consumer_id": "c1"}`)))
+	assert.NotEqual(t, base, requestFingerprint("POST", "/purchase/1", "", []byte(`{"consumer_id": "c2"}`)))
+	assert.NotEqual(t, base, requestFingerprint("POST", "/purchase/1", "dry_run=true", []byte(`{"consumer_id": "c1"}`)))
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	assert.Equal(t, "POST /products", idempotencyScope("POST", "/products", ""))
+	assert.NotEqual(t,
+		idempotencyScope("POST", "/purchase/:id", "c1"),
+		idempotencyScope("POST", "/purchase/:id", "c2"),
+		"two callers sending the same key do not share it")
+}
+
+func TestCapturingWriter(t *testing.T) {
+	r := gin.New()
+	var captured string
+	r.POST("/x", func(c *gin.Context) {
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		captured = w.body.String()
+	}, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"order_id": 7})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/x", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, w.Body.String(), captured)
+	assert.Contains(t, captured, `"order_id":7`)
+}
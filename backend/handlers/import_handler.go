@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportRows caps a single bulk import request.
+const maxImportRows = 500
+
+// importColumns are the CSV columns a bulk import must provide. The
+// pickup_start and pickup_end columns (RFC3339) are optional.
+var importColumns = []string{"name", "original_price", "current_price", "expiry_minutes", "latitude", "longitude"}
+
+// importRowError reports why a single import row was rejected. Row is the
+// CSV line number (header = 1) or the 1-based index in a JSON array.
+type importRowError struct {
+	Row    int                 `json:"row"`
+	Error  string              `json:"error"`
+	Fields []models.FieldError `json:"fields,omitempty"`
+}
+
+type importRow struct {
+	row   int
+	input productInput
+}
+
+// =========================================================================
+// BULK IMPORT / EXPORT
+// =========================================================================
+
+// ImportProducts - POST /products/import?dry_run=true
+// Accepts text/csv or a JSON array of listings for the authenticated
+// merchant. Rows are validated with the same rules as CreateProduct; if any
+// row fails nothing is inserted.
+func ImportProducts(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	var rows []importRow
+	var rowErrors []importRowError
+	var err error
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		rows, rowErrors, err = parseImportCSV(c.Request.Body)
+	} else {
+		rows, rowErrors, err = parseImportJSON(c.Request.Body)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows)+len(rowErrors) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d rows per import", maxImportRows)})
+		return
+	}
+	if !requireMerchant(c) {
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	v, err := newListingValidator(db.DB, merchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	now := time.Now()
+	var valid []importRow
+	for _, r := range rows {
+		r.input.MerchantID = merchantID
+		if fieldErrs := v.Validate(r.input, now); len(fieldErrs) > 0 {
+			rowErrors = append(rowErrors, importRowError{Row: r.row, Error: summarizeFieldErrors(fieldErrs), Fields: fieldErrs})
+			continue
+		}
+		valid = append(valid, r)
+	}
+	if rowErrors == nil {
+		rowErrors = []importRowError{}
+	}
+	sort.Slice(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
+	total := len(rows)
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run": true,
+			"total":   total,
+			"valid":   len(valid),
+			"errors":  rowErrors,
+		})
+		return
+	}
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Import rejected, no rows were inserted",
+			"total":  total,
+			"valid":  len(valid),
+			"errors": rowErrors,
+		})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	createdIDs := make([]int, 0, len(valid))
+	for _, r := range valid {
+		id, err := createListing(tx, r.input, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create product on row %d", r.row)})
+			return
+		}
+		createdIDs = append(createdIDs, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Products imported",
+		"total":       total,
+		"created_ids": createdIDs,
+	})
+}
+
+// summarizeFieldErrors joins field messages into a single line.
+func summarizeFieldErrors(errs []models.FieldError) string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// parseImportCSV reads a header row followed by listing rows. Rows whose
+// values cannot be parsed are reported instead of returned.
+func parseImportCSV(r io.Reader) ([]importRow, []importRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("CSV header row required")
+	}
+	index := map[string]int{}
+	for i, col := range header {
+		index[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	for _, col := range importColumns {
+		if _, ok := index[col]; !ok {
+			return nil, nil, fmt.Errorf("CSV column %q missing", col)
+		}
+	}
+
+	var rows []importRow
+	var rowErrors []importRowError
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: line, Error: err.Error()})
+			continue
+		}
+
+		field := func(col string) string {
+			if i, ok := index[col]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		input := productInput{Name: field("name")}
+		var parseErr error
+		parseFloat := func(col string) float64 {
+			v := field(col)
+			if v == "" || parseErr != nil {
+				return 0
+			}
+			// ParseFloat accepts NaN and Inf, which no listing rule can compare
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				parseErr = fmt.Errorf("%s: invalid number %q", col, v)
+			}
+			return f
+		}
+		parseInt := func(col string) int {
+			v := field(col)
+			if v == "" || parseErr != nil {
+				return 0
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				parseErr = fmt.Errorf("%s: invalid whole number %q", col, v)
+			}
+			return n
+		}
+		input.OriginalPrice = parseFloat("original_price")
+		input.CurrentPrice = parseFloat("current_price")
+		input.ExpiryMinutes = parseInt("expiry_minutes")
+		input.Latitude = parseFloat("latitude")
+		input.Longitude = parseFloat("longitude")
+		parseTime := func(col string) *time.Time {
+			v := field(col)
+			if v == "" || parseErr != nil {
+				return nil
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				parseErr = fmt.Errorf("%s: invalid RFC3339 time %q", col, v)
+				return nil
+			}
+			return &t
+		}
+		input.PickupStart = parseTime("pickup_start")
+		input.PickupEnd = parseTime("pickup_end")
+		if parseErr != nil {
+			rowErrors = append(rowErrors, importRowError{Row: line, Error: parseErr.Error()})
+			continue
+		}
+
+		rows = append(rows, importRow{row: line, input: input})
+	}
+
+	return rows, rowErrors, nil
+}
+
+// parseImportJSON reads a JSON array of listings. Elements that do not
+// decode as a listing are reported instead of returned.
+func parseImportJSON(r io.Reader) ([]importRow, []importRowError, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("Body must be a JSON array of listings")
+	}
+
+	var rows []importRow
+	var rowErrors []importRowError
+	for i, item := range raw {
+		var input productInput
+		if err := json.Unmarshal(item, &input); err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, importRow{row: i + 1, input: input})
+	}
+
+	return rows, rowErrors, nil
+}
+
+// listingOutcome summarises what happened to a listing for exports.
+func listingOutcome(status string, expiry, now time.Time) string {
+	switch {
+	case status == "SOLD":
+		return "sold"
+	case status == "RESERVED":
+		return "reserved"
+	case now.After(expiry):
+		return "expired"
+	default:
+		return "available"
+	}
+}
+
+// ExportProducts - GET /products/export?format=csv|json
+func ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	if !requireMerchant(c) {
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	rows, err := db.DB.Query(`
+		SELECT p.id, p.name, p.original_price, p.current_price, p.expiry_date, p.latitude, p.longitude,
+		       COALESCE(p.status, 'AVAILABLE'), COALESCE(p.pickup_start, p.created_at), COALESCE(p.pickup_end, p.expiry_date),
+		       p.created_at, o.created_at
+		FROM products p
+		LEFT JOIN LATERAL (SELECT created_at FROM orders WHERE product_id = p.id ORDER BY id DESC LIMIT 1) o ON TRUE
+		WHERE p.merchant_id = $1
+		ORDER BY p.created_at DESC
+	`, merchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export products"})
+		return
+	}
+	defer rows.Close()
+
+	type exportRow struct {
+		ID            int        `json:"id"`
+		Name          string     `json:"name"`
+		OriginalPrice float64    `json:"original_price"`
+		CurrentPrice  float64    `json:"current_price"`
+		ExpiryDate    time.Time  `json:"expiry_date"`
+		Latitude      float64    `json:"latitude"`
+		Longitude     float64    `json:"longitude"`
+		Status        string     `json:"status"`
+		PickupStart   time.Time  `json:"pickup_start"`
+		PickupEnd     time.Time  `json:"pickup_end"`
+		Outcome       string     `json:"outcome"`
+		ListedAt      time.Time  `json:"listed_at"`
+		SoldAt        *time.Time `json:"sold_at"`
+	}
+
+	now := time.Now()
+	listings := []exportRow{}
+	for rows.Next() {
+		var e exportRow
+		if err := rows.Scan(&e.ID, &e.Name, &e.OriginalPrice, &e.CurrentPrice, &e.ExpiryDate, &e.Latitude, &e.Longitude,
+			&e.Status, &e.PickupStart, &e.PickupEnd, &e.ListedAt, &e.SoldAt); err != nil {
+			continue
+		}
+		e.Outcome = listingOutcome(e.Status, e.ExpiryDate, now)
+		listings = append(listings, e)
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, listings)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="listings_%s.csv"`, now.Format("20060102")))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "name", "original_price", "current_price", "expiry_date", "latitude", "longitude", "status", "pickup_start", "pickup_end", "outcome", "listed_at", "sold_at"})
+	for _, e := range listings {
+		soldAt := ""
+		if e.SoldAt != nil {
+			soldAt = e.SoldAt.Format(time.RFC3339)
+		}
+		w.Write([]string{
+			strconv.Itoa(e.ID), e.Name,
+			strconv.FormatFloat(e.OriginalPrice, 'f', 2, 64), strconv.FormatFloat(e.CurrentPrice, 'f', 2, 64),
+			e.ExpiryDate.Format(time.RFC3339),
+			strconv.FormatFloat(e.Latitude, 'f', -1, 64), strconv.FormatFloat(e.Longitude, 'f', -1, 64),
+			e.Status, e.PickupStart.Format(time.RFC3339), e.PickupEnd.Format(time.RFC3339),
+			e.Outcome, e.ListedAt.Format(time.RFC3339), soldAt,
+		})
+	}
+	w.Flush()
+}
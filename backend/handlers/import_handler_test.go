@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// BULK IMPORT / EXPORT TESTS
+// =========================================================================
+
+func TestImportExportRequireToken(t *testing.T) {
+	router := gin.New()
+	router.POST("/products/import", AuthRequired(), ImportProducts)
+	router.GET("/products/export", AuthRequired(), ExportProducts)
+
+	req, _ := http.NewRequest("POST", "/products/import?merchant_id=m1", bytes.NewBuffer([]byte("[]")))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("GET", "/products/export?merchant_id=m1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestImportProductsCSVMissingColumn(t *testing.T) {
+	router := gin.New()
+	router.POST("/products/import", ImportProducts)
+
+	csvBody := "name,original_price,current_price\nBread,50,25\n"
+	req, _ := http.NewRequest("POST", "/products/import", bytes.NewBufferString(csvBody))
+	req.Header.Set("Content-Type", "text/csv")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestParseImportCSVReportsRows(t *testing.T) {
+	csvBody := "name,original_price,current_price,expiry_minutes,latitude,longitude\n" +
+		"Bread,50,25,120,25.03,121.56\n" +
+		"Milk,abc,45,120,25.03,121.56\n" +
+		"Bento,120,60,90,25.03,121.56\n"
+
+	rows, rowErrors, err := parseImportCSV(bytes.NewBufferString(csvBody))
+
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, 2, rows[0].row)
+		assert.Equal(t, "Bread", rows[0].input.Name)
+		assert.Equal(t, 4, rows[1].row)
+	}
+	if assert.Len(t, rowErrors, 1) {
+		assert.Equal(t, 3, rowErrors[0].Row)
+	}
+}
+
+func TestParseImportCSVRejectsNonFinite(t *testing.T) {
+	csvBody := "name,original_price,current_price,expiry_minutes,latitude,longitude\n" +
+		"Bread,50,NaN,120,25.03,121.56\n" +
+		"Milk,50,25,120,nan,121.56\n" +
+		"Cake,Inf,25,120,25.03,121.56\n" +
+		"Soup,50,25,120,25.03,1e400\n" +
+		"Rice,50,25,90.9,25.03,121.56\n" +
+		"Bento,120,60,90,25.03,121.56\n"
+
+	rows, rowErrors, err := parseImportCSV(bytes.NewBufferString(csvBody))
+
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, 90, rows[0].input.ExpiryMinutes)
+	}
+	assert.Len(t, rowErrors, 5)
+}
+
+func TestParseImportJSONNotArray(t *testing.T) {
+	_, _, err := parseImportJSON(bytes.NewBufferString(`{"name": "Bread"}`))
+
+	assert.Error(t, err)
+}
+
+func TestParseImportJSONReportsRows(t *testing.T) {
+	body := []interface{}{
+		map[string]interface{}{"name": "Sushi Box", "original_price": 200, "current_price": 100, "expiry_minutes": 60, "latitude": 25.03, "longitude": 121.56},
+		map[string]interface{}{"name": "Bento", "original_price": "cheap"}, // Wrong type
+	}
+	jsonBody, _ := json.Marshal(body)
+
+	rows, rowErrors, err := parseImportJSON(bytes.NewBuffer(jsonBody))
+
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	if assert.Len(t, rowErrors, 1) {
+		assert.Equal(t, 2, rowErrors[0].Row)
+	}
+}
+
+func TestListingOutcome(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, "sold", listingOutcome("SOLD", now.Add(-time.Hour), now))
+	assert.Equal(t, "expired", listingOutcome("AVAILABLE", now.Add(-time.Hour), now))
+	assert.Equal(t, "available", listingOutcome("AVAILABLE", now.Add(time.Hour), now))
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// defaultListingRules apply when the listing_rules table has no '*' row.
+var defaultListingRules = models.ListingRules{
+	Category:           "*",
+	MinDiscountPercent: 10,
+	MaxLifetimeMinutes: 24 * 60,
+	PriceFloor:         1,
+	MaxDistanceMeters:  500,
+}
+
+// listingValidator checks listings for one merchant against the effective
+// rules for that merchant's category.
+type listingValidator struct {
+	rules       models.ListingRules
+	merchantLat float64
+	merchantLng float64
+	hasLocation bool
+	hoursOpen   string
+	hoursClose  string
+	loc         *time.Location
+}
+
+// newListingValidator loads the merchant's registered location, business
+// hours and timezone, and the rules for its category.
+func newListingValidator(q queryer, merchantID string) (*listingValidator, error) {
+	v := &listingValidator{}
+
+	var category, tz string
+	var lat, lng sql.NullFloat64
+	err := q.QueryRow(`
+		SELECT COALESCE(category, ''), latitude, longitude,
+		       COALESCE(business_hours_open, ''), COALESCE(business_hours_close, ''), COALESCE(timezone, '')
+		FROM merchants WHERE user_id = $1
+	`, merchantID).Scan(&category, &lat, &lng, &v.hoursOpen, &v.hoursClose, &tz)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	v.loc = loadLocation(tz)
+	if lat.Valid && lng.Valid && (lat.Float64 != 0 || lng.Float64 != 0) {
+		v.merchantLat, v.merchantLng, v.hasLocation = lat.Float64, lng.Float64, true
+	}
+
+	v.rules, err = effectiveListingRules(q, category)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// effectiveListingRules merges the category override onto the platform
+// defaults.
+func effectiveListingRules(q queryer, category string) (models.ListingRules, error) {
+	rules := models.ListingRules{Category: category}
+	err := q.QueryRow(`
+		SELECT COALESCE(c.min_discount_percent, d.min_discount_percent, 0),
+		       COALESCE(c.max_lifetime_minutes, d.max_lifetime_minutes, 0),
+		       COALESCE(c.price_floor, d.price_floor, 0),
+		       COALESCE(c.max_distance_meters, d.max_distance_meters, 0)
+		FROM listing_rules d
+		LEFT JOIN listing_rules c ON c.category = $1 AND c.category <> '*'
+		WHERE d.category = '*'
+	`, category).Scan(&rules.MinDiscountPercent, &rules.MaxLifetimeMinutes, &rules.PriceFloor, &rules.MaxDistanceMeters)
+	if err == sql.ErrNoRows {
+		rules = defaultListingRules
+		rules.Category = category
+		return rules, nil
+	}
+	return rules, err
+}
+
+// Validate runs the CreateProduct binding rules, the platform listing rules
+// and the pickup window checks for a listing published at listedAt,
+// returning every failing field.
+func (v *listingValidator) Validate(input productInput, listedAt time.Time) []models.FieldError {
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		return bindingFieldErrors(err, input)
+	}
+	errs := checkListingRules(input, v.rules, v.merchantLat, v.merchantLng, v.hasLocation)
+	if input.ExpiryMinutes > 0 {
+		expiry, pickupStart, pickupEnd := listingTimes(input, listedAt)
+		errs = append(errs, checkPickupWindow(pickupStart, pickupEnd, expiry, listedAt, v.hoursOpen, v.hoursClose, v.loc)...)
+	}
+	return errs
+}
+
+// checkListingRules applies rules to an input that already passed binding.
+func checkListingRules(input productInput, rules models.ListingRules, merchantLat, merchantLng float64, hasLocation bool) []models.FieldError {
+	errs := []models.FieldError{}
+	add := func(field, code, message string) {
+		errs = append(errs, models.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	// NaN fails every comparison below, and neither NaN nor Inf can be
+	// stored and served as JSON
+	numbers := []struct {
+		field string
+		value float64
+	}{
+		{"original_price", input.OriginalPrice},
+		{"current_price", input.CurrentPrice},
+		{"latitude", input.Latitude},
+		{"longitude", input.Longitude},
+	}
+	for _, n := range numbers {
+		if math.IsNaN(n.value) || math.IsInf(n.value, 0) {
+			add(n.field, "not_a_number", fmt.Sprintf("%s must be a finite number", n.field))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if input.OriginalPrice <= 0 {
+		add("original_price", "must_be_positive", "Original price must be greater than 0")
+	}
+	if input.CurrentPrice <= 0 {
+		add("current_price", "must_be_positive", "Current price must be greater than 0")
+	} else if rules.PriceFloor > 0 && input.CurrentPrice < rules.PriceFloor {
+		add("current_price", "below_price_floor", fmt.Sprintf("Current price must be at least %.2f", rules.PriceFloor))
+	}
+	if input.OriginalPrice > 0 && input.CurrentPrice > input.OriginalPrice {
+		add("current_price", "exceeds_original_price", "Current price cannot be higher than the original price")
+	} else if input.OriginalPrice > 0 && rules.MinDiscountPercent > 0 {
+		discount := (input.OriginalPrice - input.CurrentPrice) / input.OriginalPrice * 100
+		if discount < rules.MinDiscountPercent {
+			add("current_price", "discount_too_small", fmt.Sprintf("Discount must be at least %.0f%%", rules.MinDiscountPercent))
+		}
+	}
+
+	if input.ExpiryMinutes <= 0 {
+		add("expiry_minutes", "must_be_positive", "Expiry must be at least 1 minute")
+	} else if rules.MaxLifetimeMinutes > 0 && input.ExpiryMinutes > rules.MaxLifetimeMinutes {
+		add("expiry_minutes", "lifetime_too_long", fmt.Sprintf("Listings can stay up for at most %d minutes", rules.MaxLifetimeMinutes))
+	}
+
+	coordsValid := true
+	if input.Latitude < -90 || input.Latitude > 90 {
+		add("latitude", "out_of_range", "Latitude must be between -90 and 90")
+		coordsValid = false
+	}
+	if input.Longitude < -180 || input.Longitude > 180 {
+		add("longitude", "out_of_range", "Longitude must be between -180 and 180")
+		coordsValid = false
+	}
+	if coordsValid && hasLocation && rules.MaxDistanceMeters > 0 {
+		if meters := distance(merchantLat, merchantLng, input.Latitude, input.Longitude) * 1000; meters > rules.MaxDistanceMeters {
+			msg := fmt.Sprintf("Pickup location must be within %.0f m of the shop", rules.MaxDistanceMeters)
+			add("latitude", "too_far_from_shop", msg)
+			add("longitude", "too_far_from_shop", msg)
+		}
+	}
+
+	return errs
+}
+
+// bindingFieldErrors converts validator errors on obj into field errors
+// keyed by JSON name.
+func bindingFieldErrors(err error, obj interface{}) []models.FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []models.FieldError{{Field: "", Code: "invalid", Message: err.Error()}}
+	}
+
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	errs := make([]models.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		field := fe.Field()
+		if sf, ok := t.FieldByName(fe.StructField()); ok {
+			if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" {
+				field = name
+			}
+		}
+		msg := fmt.Sprintf("%s is invalid", field)
+		if fe.Tag() == "required" {
+			msg = fmt.Sprintf("%s is required", field)
+		}
+		errs = append(errs, models.FieldError{Field: field, Code: fe.Tag(), Message: msg})
+	}
+	return errs
+}
+
+// =========================================================================
+// RULE CONFIGURATION
+// =========================================================================
+
+// GetListingRules - GET /listing-rules?category=xxx
+// Returns the effective rules so the frontend can show limits inline.
+func GetListingRules(c *gin.Context) {
+	rules, err := effectiveListingRules(db.DB, c.Query("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load listing rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// UpdateListingRules - PUT /admin/listing-rules/:category
+// Each PUT replaces the category's whole rule set. For a category, omitted
+// fields are stored as NULL and inherit the platform default. "*" is the
+// platform default itself, which has nothing to inherit from, so every field
+// is required (0 disables a rule).
+func UpdateListingRules(c *gin.Context) {
+	var input struct {
+		MinDiscountPercent *float64 `json:"min_discount_percent" binding:"omitempty,min=0,max=100"`
+		MaxLifetimeMinutes *int     `json:"max_lifetime_minutes" binding:"omitempty,min=0"`
+		PriceFloor         *float64 `json:"price_floor" binding:"omitempty,min=0"`
+		MaxDistanceMeters  *float64 `json:"max_distance_meters" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": bindingFieldErrors(err, &input)})
+		return
+	}
+	if c.Param("category") == "*" {
+		missing := []models.FieldError{}
+		for field, set := range map[string]bool{
+			"min_discount_percent": input.MinDiscountPercent != nil,
+			"max_lifetime_minutes": input.MaxLifetimeMinutes != nil,
+			"price_floor":          input.PriceFloor != nil,
+			"max_distance_meters":  input.MaxDistanceMeters != nil,
+		} {
+			if !set {
+				missing = append(missing, models.FieldError{Field: field, Code: "required", Message: field + " is required for the platform defaults"})
+			}
+		}
+		if len(missing) > 0 {
+			sort.Slice(missing, func(i, j int) bool { return missing[i].Field < missing[j].Field })
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": missing})
+			return
+		}
+	}
+
+	_, err := db.DB.Exec(`
+		INSERT INTO listing_rules (category, min_discount_percent, max_lifetime_minutes, price_floor, max_distance_meters)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (category) DO UPDATE
+		SET min_discount_percent=$2, max_lifetime_minutes=$3, price_floor=$4, max_distance_meters=$5, updated_at=CURRENT_TIMESTAMP
+	`, c.Param("category"), input.MinDiscountPercent, input.MaxLifetimeMinutes, input.PriceFloor, input.MaxDistanceMeters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update listing rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Listing rules updated"})
+}
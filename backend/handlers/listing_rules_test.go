@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"food-platform-backend/models"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// LISTING RULES TESTS
+// =========================================================================
+
+func validListing() productInput {
+	return productInput{
+		MerchantID:    "m1",
+		Name:          "Sushi Box",
+		OriginalPrice: 200,
+		CurrentPrice:  100,
+		ExpiryMinutes: 120,
+		Latitude:      25.0335,
+		Longitude:     121.5650,
+	}
+}
+
+func fieldCodes(errs []models.FieldError) map[string]string {
+	codes := map[string]string{}
+	for _, e := range errs {
+		codes[e.Field] = e.Code
+	}
+	return codes
+}
+
+func TestCheckListingRulesValid(t *testing.T) {
+	errs := checkListingRules(validListing(), defaultListingRules, 25.0336, 121.5651, true)
+
+	assert.Empty(t, errs)
+}
+
+func TestCheckListingRulesPrices(t *testing.T) {
+	input := validListing()
+	input.CurrentPrice = 250
+
+	codes := fieldCodes(checkListingRules(input, defaultListingRules, 0, 0, false))
+	assert.Equal(t, "exceeds_original_price", codes["current_price"])
+
+	input.CurrentPrice = 190 // 5% off
+	codes = fieldCodes(checkListingRules(input, defaultListingRules, 0, 0, false))
+	assert.Equal(t, "discount_too_small", codes["current_price"])
+
+	input.OriginalPrice = -10
+	input.CurrentPrice = -5
+	codes = fieldCodes(checkListingRules(input, defaultListingRules, 0, 0, false))
+	assert.Equal(t, "must_be_positive", codes["original_price"])
+	assert.Equal(t, "must_be_positive", codes["current_price"])
+}
+
+func TestCheckListingRulesNonFinite(t *testing.T) {
+	input := validListing()
+	input.CurrentPrice = math.NaN()
+	input.Latitude = math.NaN()
+	input.OriginalPrice = math.Inf(1)
+
+	codes := fieldCodes(checkListingRules(input, defaultListingRules, 25.0336, 121.5651, true))
+	assert.Equal(t, "not_a_number", codes["current_price"])
+	assert.Equal(t, "not_a_number", codes["original_price"])
+	assert.Equal(t, "not_a_number", codes["latitude"])
+	assert.NotContains(t, codes, "longitude")
+}
+
+func TestCheckListingRulesLifetime(t *testing.T) {
+	input := validListing()
+	input.ExpiryMinutes = 60 * 24 * 30
+
+	codes := fieldCodes(checkListingRules(input, defaultListingRules, 0, 0, false))
+	assert.Equal(t, "lifetime_too_long", codes["expiry_minutes"])
+}
+
+func TestCheckListingRulesDistance(t *testing.T) {
+	// Taipei Main Station is ~5 km from Taipei 101
+	codes := fieldCodes(checkListingRules(validListing(), defaultListingRules, 25.0478, 121.5170, true))
+	assert.Equal(t, "too_far_from_shop", codes["latitude"])
+
+	// A category override can disable the check
+	rules := defaultListingRules
+	rules.MaxDistanceMeters = 0
+	assert.Empty(t, checkListingRules(validListing(), rules, 25.0478, 121.5170, true))
+}
+
+func TestCreateProductReturnsFieldErrors(t *testing.T) {
+	router := gin.New()
+	router.POST("/products", CreateProduct)
+
+	req, _ := http.NewRequest("POST", "/products", bytes.NewBuffer([]byte(`{"merchant_id": "m1"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response struct {
+		Fields []models.FieldError `json:"fields"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	codes := fieldCodes(response.Fields)
+	assert.Equal(t, "required", codes["name"])
+	assert.Equal(t, "required", codes["current_price"])
+}
+
+func TestUpdateListingRulesRequiresAdmin(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "secret")
+
+	router := gin.New()
+	router.PUT("/admin/listing-rules/:category", AdminRequired(), UpdateListingRules)
+
+	req, _ := http.NewRequest("PUT", "/admin/listing-rules/bakery", bytes.NewBuffer([]byte(`{"min_discount_percent": 30}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Key", "wrong")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestUpdateListingRulesDefaultsNeedEveryField(t *testing.T) {
+	router := gin.New()
+	router.PUT("/admin/listing-rules/:category", UpdateListingRules)
+
+	req, _ := http.NewRequest("PUT", "/admin/listing-rules/*", bytes.NewBuffer([]byte(`{"price_floor": 5}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response struct {
+		Fields []models.FieldError `json:"fields"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	codes := fieldCodes(response.Fields)
+	assert.Len(t, codes, 3)
+	assert.Equal(t, "required", codes["min_discount_percent"])
+	assert.NotContains(t, codes, "price_floor")
+}
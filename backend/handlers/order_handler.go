@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var (
+	errOrderNotFound     = errors.New("order not found")
+	errInvalidTransition = errors.New("invalid order status transition")
+	errPaymentIncomplete = errors.New("payment has not been completed")
+)
+
+// orderStatusTimestamps maps each status to the column stamped when an order
+// enters it.
+var orderStatusTimestamps = map[models.OrderStatus]string{
+	models.OrderStatusConfirmed:           "confirmed_at",
+	models.OrderStatusReadyForPickup:      "ready_at",
+	models.OrderStatusPickedUp:            "picked_up_at",
+	models.OrderStatusCancelledByConsumer: "cancelled_at",
+	models.OrderStatusCancelledByMerchant: "cancelled_at",
+	models.OrderStatusNoShow:              "no_show_at",
+	models.OrderStatusPaymentFailed:       "cancelled_at",
+}
+
+// orderRef is the locked view of an order that transitions work on.
+type orderRef struct {
+	ID            int
+	ProductID     int
+	ConsumerID    string
+	MerchantID    string
+	ProductName   string
+	Status        models.OrderStatus
+	PaymentStatus string
+}
+
+// lockOrder locks an order row for the rest of tx and resolves its merchant.
+func lockOrder(tx *sql.Tx, orderID int) (orderRef, error) {
+	var o orderRef
+	err := tx.QueryRow(`
+		SELECT o.id, o.product_id, o.consumer_id, COALESCE(p.merchant_id, ''), p.name, COALESCE(o.status, 'pending'),
+		       COALESCE(o.payment_status, 'not_required')
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = $1
+		FOR UPDATE OF o
+	`, orderID).Scan(&o.ID, &o.ProductID, &o.ConsumerID, &o.MerchantID, &o.ProductName, &o.Status, &o.PaymentStatus)
+	if err == sql.ErrNoRows {
+		return o, errOrderNotFound
+	}
+	return o, err
+}
+
+// transitionOrder moves a locked order to next and stamps the step's time.
+// Orders awaiting payment cannot be confirmed, no-shows wait for the pickup
+// window to end, cancelling frees the order's pickup slot and picking up
+// issues its receipt.
+func transitionOrder(tx *sql.Tx, o *orderRef, next models.OrderStatus) error {
+	if !o.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s to %s", errInvalidTransition, o.Status, next)
+	}
+	if next == models.OrderStatusConfirmed && (o.PaymentStatus == models.PaymentStatusPending || o.PaymentStatus == models.PaymentStatusFailed) {
+		return errPaymentIncomplete
+	}
+	if next == models.OrderStatusNoShow {
+		if err := checkNoShowDue(tx, *o, time.Now()); err != nil {
+			return err
+		}
+	}
+	if next == models.OrderStatusCancelledByConsumer || next == models.OrderStatusCancelledByMerchant || next == models.OrderStatusPaymentFailed {
+		if err := releasePickupSlot(tx, o.ID); err != nil {
+			return err
+		}
+	}
+	column := orderStatusTimestamps[next]
+	_, err := tx.Exec(`UPDATE orders SET status = $1, `+column+` = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		next, o.ID)
+	if err != nil {
+		return err
+	}
+	if next == models.OrderStatusPickedUp {
+		if err := createReceipt(tx, o.ID, time.Now()); err != nil {
+			return err
+		}
+	}
+	o.Status = next
+	return nil
+}
+
+// notifyOrderTransition tells the other party that an order changed status.
+// Consumer cancellations go to the merchant; everything else to the consumer.
+func notifyOrderTransition(o orderRef, reason string) {
+	var userID, title, body string
+	switch o.Status {
+	case models.OrderStatusConfirmed:
+		userID, title, body = o.ConsumerID, "Order confirmed", fmt.Sprintf("Your order for %s has been confirmed.", o.ProductName)
+	case models.OrderStatusReadyForPickup:
+		userID, title, body = o.ConsumerID, "Ready for pickup", fmt.Sprintf("%s is ready for pickup.", o.ProductName)
+	case models.OrderStatusPickedUp:
+		userID, title, body = o.ConsumerID, "Order picked up", fmt.Sprintf("Thanks for rescuing %s!", o.ProductName)
+	case models.OrderStatusCancelledByMerchant:
+		userID, title, body = o.ConsumerID, "Order cancelled", fmt.Sprintf("The shop cancelled your order for %s.", o.ProductName)
+	case models.OrderStatusNoShow:
+		userID, title, body = o.ConsumerID, "Missed pickup", fmt.Sprintf("Your order for %s was marked as not picked up. If you did collect it, you can dispute this within %d days.", o.ProductName, disputeWindowDays)
+	case models.OrderStatusPaymentFailed:
+		userID, title, body = o.ConsumerID, "Order cancelled", fmt.Sprintf("Your order for %s was cancelled because payment was not completed.", o.ProductName)
+	case models.OrderStatusCancelledByConsumer:
+		userID, title, body = o.MerchantID, "Order cancelled", fmt.Sprintf("A customer cancelled order #%d for %s.", o.ID, o.ProductName)
+	default:
+		return
+	}
+	if reason != "" {
+		body += " Reason: " + reason
+	}
+	notifyUser(userID, title, body, "order")
+}
+
+// respondOrderError maps order lookup and transition errors to responses.
+func respondOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, errInvalidTransition), errors.Is(err, errCancelTooLate), errors.Is(err, errPaymentIncomplete),
+		errors.Is(err, errNoShowTooEarly):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+	}
+}
+
+// =========================================================================
+// ORDER TRANSITIONS
+// =========================================================================
+
+// ConfirmOrder - POST /merchant/orders/:id/confirm
+func ConfirmOrder(c *gin.Context) {
+	merchantOrderTransition(c, models.OrderStatusConfirmed)
+}
+
+// MarkOrderReady - POST /merchant/orders/:id/ready
+func MarkOrderReady(c *gin.Context) {
+	merchantOrderTransition(c, models.OrderStatusReadyForPickup)
+}
+
+// MarkOrderPickedUp - POST /merchant/orders/:id/picked-up
+func MarkOrderPickedUp(c *gin.Context) {
+	merchantOrderTransition(c, models.OrderStatusPickedUp)
+}
+
+// MarkOrderNoShow - POST /merchant/orders/:id/no-show
+func MarkOrderNoShow(c *gin.Context) {
+	merchantOrderTransition(c, models.OrderStatusNoShow)
+}
+
+// merchantOrderTransition applies next to one of the calling merchant's
+// orders. The body, with an optional reason, may be omitted.
+func merchantOrderTransition(c *gin.Context, next models.OrderStatus) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	merchantID := c.GetString("user_id")
+	applyOrderTransition(c, next, input.Reason, func(o orderRef) bool {
+		return o.MerchantID == merchantID
+	})
+}
+
+// applyOrderTransition moves the order in the :id param to next if owns
+// accepts the caller, then notifies the other party.
+func applyOrderTransition(c *gin.Context, next models.OrderStatus, reason string, owns func(orderRef) bool) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := lockOrder(tx, orderID)
+	if err == nil && !owns(o) {
+		err = errOrderNotFound
+	}
+	if err == nil {
+		err = transitionOrder(tx, &o, next)
+	}
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	notifyOrderTransition(o, reason)
+	c.JSON(http.StatusOK, gin.H{"message": "Order updated", "order_id": o.ID, "status": o.Status})
+}
+
+// =========================================================================
+// ORDER HISTORY
+// =========================================================================
+
+// GetMyOrders - GET /me/orders?status=&from=&to=&page=&page_size=
+func GetMyOrders(c *gin.Context) {
+	listOrders(c, "o.consumer_id", c.GetString("user_id"))
+}
+
+// GetMerchantOrders - GET /merchant/orders?status=&from=&to=&page=&page_size=
+func GetMerchantOrders(c *gin.Context) {
+	listOrders(c, "o.merchant_id", c.GetString("user_id"))
+}
+
+// parseStatusFilter splits a comma-separated status list, rejecting
+// anything that is not a known order status.
+func parseStatusFilter(value string) ([]string, bool) {
+	var statuses []string
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		_, known := orderStatusTimestamps[models.OrderStatus(s)]
+		if !known && s != string(models.OrderStatusPending) {
+			return nil, false
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, true
+}
+
+// parseDateBound parses an RFC3339 time or a YYYY-MM-DD date in loc. A date
+// used as an upper bound covers the whole day.
+func parseDateBound(value string, loc *time.Location, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d, nil
+}
+
+// listOrders writes a filtered, paginated order history scoped to one
+// consumer or merchant. scopeColumn is trusted (never user input).
+func listOrders(c *gin.Context, scopeColumn, scopeID string) {
+	where := " WHERE " + scopeColumn + " = $1"
+	args := []interface{}{scopeID}
+	argIndex := 2
+
+	if status := c.Query("status"); status != "" {
+		statuses, ok := parseStatusFilter(status)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+			return
+		}
+		where += " AND COALESCE(o.status, 'pending') = ANY($" + strconv.Itoa(argIndex) + ")"
+		args = append(args, pq.Array(statuses))
+		argIndex++
+	}
+
+	loc := loadLocation("")
+	for _, bound := range []struct {
+		param string
+		op    string
+		upper bool
+	}{{"from", ">=", false}, {"to", "<", true}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseDateBound(value, loc, bound.upper)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": bound.param + " must be YYYY-MM-DD or RFC3339"})
+			return
+		}
+		where += " AND o.created_at " + bound.op + " $" + strconv.Itoa(argIndex)
+		args = append(args, t.UTC())
+		argIndex++
+	}
+
+	var total int
+	if err := db.DB.QueryRow("SELECT COUNT(*) FROM orders o"+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	query := `
+		SELECT o.id, o.product_id, o.consumer_id, COALESCE(o.merchant_id, p.merchant_id, ''),
+		       COALESCE(o.product_name, p.name), COALESCE(o.price_paid, p.current_price), COALESCE(o.status, 'pending'),
+		       o.confirmed_at, o.ready_at, o.picked_up_at, o.cancelled_at, o.no_show_at,
+		       o.checkout_id, COALESCE(o.payment_status, 'not_required'), o.created_at, COALESCE(o.updated_at, o.created_at),
+		       COALESCE(m.shop_name, ''), COALESCE(p.pickup_start, p.created_at), COALESCE(p.pickup_end, p.expiry_date),
+		       COALESCE(m.timezone, ''), r.id
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		LEFT JOIN merchants m ON m.user_id = COALESCE(o.merchant_id, p.merchant_id)
+		LEFT JOIN LATERAL (SELECT id FROM reviews WHERE order_id = o.id ORDER BY id LIMIT 1) r ON TRUE` + where + `
+		ORDER BY o.created_at DESC, o.id DESC
+		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+	defer rows.Close()
+
+	orders := []models.OrderHistoryItem{}
+	for rows.Next() {
+		var o models.OrderHistoryItem
+		var tz string
+		if err := rows.Scan(&o.ID, &o.ProductID, &o.ConsumerID, &o.MerchantID,
+			&o.ProductName, &o.PricePaid, &o.Status,
+			&o.ConfirmedAt, &o.ReadyAt, &o.PickedUpAt, &o.CancelledAt, &o.NoShowAt,
+			&o.CheckoutID, &o.PaymentStatus, &o.CreatedAt, &o.UpdatedAt,
+			&o.ShopName, &o.PickupStart, &o.PickupEnd, &tz, &o.ReviewID); err != nil {
+			continue
+		}
+		merchantLoc := loadLocation(tz)
+		o.Timezone = merchantLoc.String()
+		o.PickupStart = o.PickupStart.In(merchantLoc)
+		o.PickupEnd = o.PickupEnd.In(merchantLoc)
+		o.Reviewed = o.ReviewID != nil
+		orders = append(orders, o)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders":    orders,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
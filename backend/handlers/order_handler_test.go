@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// ORDER LIFECYCLE TESTS
+// =========================================================================
+
+func TestOrderStatusTransitions(t *testing.T) {
+	assert.True(t, models.OrderStatusPending.CanTransitionTo(models.OrderStatusConfirmed))
+	assert.True(t, models.OrderStatusConfirmed.CanTransitionTo(models.OrderStatusReadyForPickup))
+	assert.True(t, models.OrderStatusReadyForPickup.CanTransitionTo(models.OrderStatusPickedUp))
+	assert.True(t, models.OrderStatusReadyForPickup.CanTransitionTo(models.OrderStatusNoShow))
+
+	// No skipping ahead from pending, no leaving a final status
+	assert.False(t, models.OrderStatusPending.CanTransitionTo(models.OrderStatusPickedUp))
+	assert.False(t, models.OrderStatusPending.CanTransitionTo(models.OrderStatusNoShow))
+	assert.False(t, models.OrderStatusPickedUp.CanTransitionTo(models.OrderStatusCancelledByConsumer))
+	assert.False(t, models.OrderStatusCancelledByMerchant.CanTransitionTo(models.OrderStatusConfirmed))
+	assert.True(t, models.OrderStatusNoShow.IsFinal())
+}
+
+func TestEveryTransitionTargetHasTimestamp(t *testing.T) {
+	statuses := []models.OrderStatus{
+		models.OrderStatusPending, models.OrderStatusConfirmed, models.OrderStatusReadyForPickup,
+		models.OrderStatusPickedUp, models.OrderStatusCancelledByConsumer, models.OrderStatusCancelledByMerchant,
+		models.OrderStatusNoShow,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if from.CanTransitionTo(to) {
+				assert.NotEmpty(t, orderStatusTimestamps[to], "missing timestamp column for %s", to)
+			}
+		}
+	}
+}
+
+func TestConfirmOrderRequiresMerchantToken(t *testing.T) {
+	router := gin.New()
+	router.POST("/merchant/orders/:id/confirm", AuthRequired(), ConfirmOrder)
+
+	// The body no longer identifies the merchant
+	req, _ := http.NewRequest("POST", "/merchant/orders/1/confirm", bytes.NewBuffer([]byte(`{"merchant_id": "m1"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("POST", "/merchant/orders/abc/confirm", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-1"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("POST", "/merchant/orders/1/confirm", bytes.NewBuffer([]byte("{")))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-1"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCancelOrderInvalidID(t *testing.T) {
+	router := gin.New()
+	router.POST("/orders/:id/cancel", AuthRequired(), CancelOrder)
+
+	req, _ := http.NewRequest("POST", "/orders/abc/cancel", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "user1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// =========================================================================
+// ORDER HISTORY TESTS
+// =========================================================================
+
+func signedTestToken(t *testing.T, userID string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	s, err := token.SignedString(jwtSecret)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestGetMyOrdersRequiresToken(t *testing.T) {
+	r := gin.New()
+	r.GET("/me/orders", AuthRequired(), GetMyOrders)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me/orders", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me/orders", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestGetMyOrdersRejectsUnknownStatus(t *testing.T) {
+	r := gin.New()
+	r.GET("/me/orders", AuthRequired(), GetMyOrders)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me/orders?status=picked_up,shipped", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "consumer-1"))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid status filter")
+}
+
+func TestGetMerchantOrdersRejectsBadDate(t *testing.T) {
+	r := gin.New()
+	r.GET("/merchant/orders", AuthRequired(), GetMerchantOrders)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/merchant/orders?from=yesterday", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-1"))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestParseDateBound(t *testing.T) {
+	loc := loadLocation("Asia/Taipei")
+
+	from, err := parseDateBound("2026-03-01", loc, false)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 2, 28, 16, 0, 0, 0, time.UTC), from.UTC())
+
+	// A date as upper bound includes the whole day
+	to, err := parseDateBound("2026-03-01", loc, true)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 1, 16, 0, 0, 0, time.UTC), to.UTC())
+
+	exact, err := parseDateBound("2026-03-01T10:00:00Z", loc, true)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), exact)
+}
+
+func TestParsePagination(t *testing.T) {
+	for _, tc := range []struct {
+		query          string
+		page, pageSize int
+	}{
+		{"", 1, defaultPageSize},
+		{"page=3&page_size=5", 3, 5},
+		{"page=0&page_size=-1", 1, defaultPageSize},
+		{"page_size=1000", 1, maxPageSize},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/?"+tc.query, nil)
+		page, pageSize := parsePagination(c)
+		assert.Equal(t, tc.page, page, tc.query)
+		assert.Equal(t, tc.pageSize, pageSize, tc.query)
+	}
+}
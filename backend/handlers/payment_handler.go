@@ -0,0 +1,717 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"food-platform-backend/payments"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errPaymentNotFound = errors.New("payment not found")
+
+// defaultPaymentTimeoutMinutes is how long an order may wait for payment
+// before it is cancelled, overridable with PAYMENT_TIMEOUT_MINUTES.
+const defaultPaymentTimeoutMinutes = 15
+
+// providerCallTimeout bounds every call to a payment provider.
+const providerCallTimeout = 30 * time.Second
+
+// paymentTimeout is how long unpaid orders hold their product.
+func paymentTimeout() time.Duration {
+	minutes := defaultPaymentTimeoutMinutes
+	if v, err := strconv.Atoi(os.Getenv("PAYMENT_TIMEOUT_MINUTES")); err == nil && v > 0 {
+		minutes = v
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// paymentCurrency is the currency orders are charged in.
+func paymentCurrency() string {
+	if v := os.Getenv("PAYMENT_CURRENCY"); v != "" {
+		return strings.ToUpper(v)
+	}
+	return "TWD"
+}
+
+// publicBaseURL is where providers and customers' browsers reach this API.
+func publicBaseURL() string {
+	if v := os.Getenv("PUBLIC_BASE_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// orderPaymentRef is the reference providers carry back to us.
+func orderPaymentRef(orderID int) string {
+	return fmt.Sprintf("order-%d", orderID)
+}
+
+// paymentRef is the locked view of a payment row.
+type paymentRef struct {
+	ID       int
+	OrderID  int
+	Provider string
+	IntentID string
+	Amount   float64
+	Status   string
+}
+
+// lockPayment locks the payment a provider knows as intentID.
+func lockPayment(tx *sql.Tx, provider, intentID string) (paymentRef, error) {
+	p := paymentRef{Provider: provider, IntentID: intentID}
+	err := tx.QueryRow(`
+		SELECT id, order_id, amount, status FROM payments
+		WHERE provider = $1 AND intent_id = $2
+		FOR UPDATE
+	`, provider, intentID).Scan(&p.ID, &p.OrderID, &p.Amount, &p.Status)
+	if err == sql.ErrNoRows {
+		return p, errPaymentNotFound
+	}
+	return p, err
+}
+
+// startOrderPayment opens a payment with provider for an order and marks
+// the order as awaiting payment.
+func startOrderPayment(orderID int, provider payments.PaymentProvider) (payments.Intent, error) {
+	var amount float64
+	var productName, wallet string
+	err := db.DB.QueryRow(`
+		SELECT COALESCE(o.price_paid, p.current_price), COALESCE(o.product_name, p.name), COALESCE(u.wallet_address, '')
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		LEFT JOIN users u ON u.id = o.consumer_id
+		WHERE o.id = $1
+	`, orderID).Scan(&amount, &productName, &wallet)
+	if err != nil {
+		return payments.Intent{}, err
+	}
+
+	base := publicBaseURL()
+	ctx, cancel := context.WithTimeout(context.Background(), providerCallTimeout)
+	defer cancel()
+	intent, err := provider.CreateIntent(ctx, payments.CreateIntentRequest{
+		OrderRef:    orderPaymentRef(orderID),
+		Amount:      amount,
+		Currency:    paymentCurrency(),
+		Description: productName,
+		ReturnURL:   fmt.Sprintf("%s/payments/%s/return?order_id=%d", base, provider.Name(), orderID),
+		CancelURL:   fmt.Sprintf("%s/payments/%s/return?order_id=%d&cancelled=1", base, provider.Name(), orderID),
+		NotifyURL:   fmt.Sprintf("%s/payments/webhook/%s", base, provider.Name()),
+		Payer:       wallet,
+	})
+	if err != nil {
+		return payments.Intent{}, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return payments.Intent{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO payments (order_id, provider, intent_id, amount, currency)
+		VALUES ($1, $2, $3, $4, $5)
+	`, orderID, provider.Name(), intent.ID, intent.Amount, intent.Currency)
+	if err != nil {
+		return payments.Intent{}, err
+	}
+	_, err = tx.Exec(`
+		UPDATE orders SET payment_method = $1, payment_status = 'pending', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`, provider.Name(), orderID)
+	if err != nil {
+		return payments.Intent{}, err
+	}
+	return intent, tx.Commit()
+}
+
+// =========================================================================
+// PAYMENTS
+// =========================================================================
+
+// PayOrder - POST /orders/:id/pay
+// Starts (or restarts after a failure) online payment of one of the
+// caller's pending orders.
+func PayOrder(c *gin.Context) {
+	var input struct {
+		PaymentMethod string `json:"payment_method" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment method required"})
+		return
+	}
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	provider, err := payments.Get(input.PaymentMethod)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown payment method"})
+		return
+	}
+
+	var consumerID, paymentStatus string
+	var status models.OrderStatus
+	err = db.DB.QueryRow(`
+		SELECT consumer_id, COALESCE(status, 'pending'), COALESCE(payment_status, 'not_required')
+		FROM orders WHERE id = $1
+	`, orderID).Scan(&consumerID, &status, &paymentStatus)
+	if err == sql.ErrNoRows || (err == nil && consumerID != c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if status != models.OrderStatusPending || paymentStatus == models.PaymentStatusPaid || paymentStatus == models.PaymentStatusRefunded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be paid", "status": status, "payment_status": paymentStatus})
+		return
+	}
+
+	intent, err := startOrderPayment(orderID, provider)
+	if errors.Is(err, payments.ErrPayerRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Add a wallet address to your account to pay with " + provider.Name()})
+		return
+	}
+	if err != nil {
+		log.Printf("Payment for order %d via %s: %v", orderID, provider.Name(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not start payment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "payment": intent})
+}
+
+// PaymentWebhook - POST /payments/webhook/:provider
+// Providers retry until they get a 2xx, so anything we fail to record
+// returns 500.
+func PaymentWebhook(c *gin.Context) {
+	provider, err := payments.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read body"})
+		return
+	}
+
+	evt, err := provider.VerifyWebhook(c.Request.Header, body)
+	switch {
+	case errors.Is(err, payments.ErrWebhookUnsupported):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider does not send webhooks"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook"})
+		return
+	}
+
+	if err := applyPaymentEvent(provider.Name(), evt); err != nil && !errors.Is(err, errPaymentNotFound) {
+		log.Printf("Payment webhook %s %s: %v", provider.Name(), evt.IntentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+		return
+	}
+
+	if ack, ok := provider.(payments.WebhookAcknowledger); ok {
+		contentType, data := ack.WebhookAck()
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// PaymentReturn - GET /payments/:provider/return?order_id=
+// Where customers land after paying. Asks the provider for the result
+// rather than trusting the redirect, capturing approved payments.
+func PaymentReturn(c *gin.Context) {
+	provider, err := payments.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+	orderID, err := strconv.Atoi(c.Query("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var intentID string
+	var amount float64
+	err = db.DB.QueryRow(`
+		SELECT intent_id, amount FROM payments
+		WHERE order_id = $1 AND provider = $2
+		ORDER BY id DESC LIMIT 1
+	`, orderID, provider.Name()).Scan(&intentID, &amount)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := syncPayment(provider, intentID, amount); err != nil {
+		log.Printf("Payment return %s %s: %v", provider.Name(), intentID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not confirm payment"})
+		return
+	}
+
+	var status models.OrderStatus
+	var paymentStatus string
+	db.DB.QueryRow(`SELECT COALESCE(status, 'pending'), COALESCE(payment_status, 'not_required') FROM orders WHERE id = $1`, orderID).
+		Scan(&status, &paymentStatus)
+	c.JSON(http.StatusOK, gin.H{"order_id": orderID, "status": status, "payment_status": paymentStatus})
+}
+
+// TrackPaymentTransfers lets providers that confirm payments from public
+// transfers see which transfers already settled a payment.
+func TrackPaymentTransfers() {
+	for _, name := range payments.Names() {
+		provider, err := payments.Get(name)
+		if err != nil {
+			continue
+		}
+		if claimer, ok := provider.(payments.TransferClaimer); ok {
+			claimer.SetClaimCheck(func(ctx context.Context, intentID, txHash string) (bool, error) {
+				var claimed bool
+				err := db.DB.QueryRowContext(ctx, `
+					SELECT EXISTS (SELECT 1 FROM payments WHERE provider = $1 AND transaction_id = $2 AND intent_id <> $3)
+				`, name, txHash, intentID).Scan(&claimed)
+				return claimed, err
+			})
+		}
+	}
+}
+
+// syncPayment fetches the provider's view of a payment, captures it if it
+// is only authorized, and applies the outcome. Payments still waiting for
+// the customer are left alone.
+func syncPayment(provider payments.PaymentProvider, intentID string, amount float64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), providerCallTimeout)
+	defer cancel()
+
+	intent, err := provider.GetIntent(ctx, intentID)
+	if err != nil {
+		return err
+	}
+	if intent.Status == payments.StatusAuthorized {
+		if intent, err = provider.Capture(ctx, intentID, amount); err != nil {
+			return err
+		}
+	}
+
+	evt := payments.Event{IntentID: intentID, Amount: intent.Amount, TransactionID: intent.TransactionID}
+	switch intent.Status {
+	case payments.StatusCaptured:
+		evt.Type = payments.EventCaptured
+	case payments.StatusFailed, payments.StatusCancelled:
+		evt.Type = payments.EventFailed
+	case payments.StatusRefunded:
+		evt.Type = payments.EventRefunded
+	default:
+		return nil
+	}
+	return applyPaymentEvent(provider.Name(), evt)
+}
+
+// applyPaymentEvent records a verified provider event. It is idempotent, as
+// providers deliver webhooks at least once and the reconciler replays what
+// it finds.
+//
+// A capture confirms the pending order it pays for. Captures that arrive
+// for orders that are no longer pending or already paid, or for less than
+// was asked, are refunded in full after commit.
+func applyPaymentEvent(provider string, evt payments.Event) error {
+	if evt.Type == payments.EventIgnored {
+		return nil
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the order before the payment, in the same order as every other
+	// order update (expireUnpaidOrder cancels the payments of a locked order)
+	var orderID int
+	err = tx.QueryRow(`SELECT order_id FROM payments WHERE provider = $1 AND intent_id = $2`, provider, evt.IntentID).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return errPaymentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	o, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	p, err := lockPayment(tx, provider, evt.IntentID)
+	if err != nil {
+		return err
+	}
+
+	notify := func() {}
+	refund := false
+	switch evt.Type {
+	case payments.EventCaptured:
+		switch p.Status {
+		case "captured", "refund_due", "refunding", "refunded":
+			return nil
+		}
+		// A transfer another payment claimed first fails the unique index;
+		// the claim check skips it on the next sync
+		_, err := tx.Exec(`
+			UPDATE payments SET status = 'captured', transaction_id = NULLIF($2, ''), updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, p.ID, evt.TransactionID)
+		if err != nil {
+			return err
+		}
+		underpaid := evt.Amount > 0 && payments.ToMinorUnits(evt.Amount, "") < payments.ToMinorUnits(p.Amount, "")
+		if o.Status != models.OrderStatusPending || o.PaymentStatus == models.PaymentStatusPaid || underpaid {
+			refund = true
+			break
+		}
+		if _, err := tx.Exec(`UPDATE orders SET payment_status = 'paid', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, o.ID); err != nil {
+			return err
+		}
+		o.PaymentStatus = models.PaymentStatusPaid
+		if err := transitionOrder(tx, &o, models.OrderStatusConfirmed); err != nil {
+			return err
+		}
+		notify = func() { notifyOrderTransition(o, "") }
+
+	case payments.EventFailed:
+		if p.Status != models.PaymentStatusPending && p.Status != string(payments.StatusAuthorized) {
+			return nil
+		}
+		if _, err := tx.Exec(`UPDATE payments SET status = 'failed', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, p.ID); err != nil {
+			return err
+		}
+		// Another attempt may still be open; the order only fails with its
+		// last one. It stays pending so the customer can retry until the
+		// reconciler expires it.
+		var open int
+		tx.QueryRow(`SELECT COUNT(*) FROM payments WHERE order_id = $1 AND status IN ('pending', 'authorized')`, o.ID).Scan(&open)
+		if o.Status != models.OrderStatusPending || o.PaymentStatus != models.PaymentStatusPending || open > 0 {
+			break
+		}
+		if _, err := tx.Exec(`UPDATE orders SET payment_status = 'failed', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, o.ID); err != nil {
+			return err
+		}
+		notify = func() {
+			notifyUser(o.ConsumerID, "Payment failed",
+				fmt.Sprintf("Payment for %s did not go through. Please try again to keep your order.", o.ProductName), "order")
+		}
+
+	case payments.EventRefunded:
+		refunded := evt.Amount
+		if refunded <= 0 {
+			refunded = p.Amount
+		}
+		_, err := tx.Exec(`
+			UPDATE payments
+			SET refunded_amount = GREATEST(refunded_amount, $2),
+			    status = CASE WHEN GREATEST(refunded_amount, $2) >= amount THEN 'refunded' ELSE status END,
+			    updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, p.ID, refunded)
+		if err != nil {
+			return err
+		}
+		if o.PaymentStatus == models.PaymentStatusPaid {
+			if _, err := tx.Exec(`UPDATE orders SET payment_status = 'refunded', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, o.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if refund {
+		// Picked up by the reconciler if the refund below fails
+		if _, err := tx.Exec(`UPDATE payments SET status = 'refund_due' WHERE id = $1`, p.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	notify()
+	if refund {
+		log.Printf("Payment %s %s arrived for order %d in status %s, refunding", provider, evt.IntentID, o.ID, o.Status)
+		if err := refundDuePayment(p.ID); err != nil {
+			log.Printf("Refund of payment %d: %v", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// refundPayment refunds amount of a captured payment with its provider and
+// records it.
+func refundPayment(paymentID int, provider, intentID string, amount float64) error {
+	pp, err := payments.Get(provider)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), providerCallTimeout)
+	defer cancel()
+	if _, err := pp.Refund(ctx, intentID, amount); err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(`
+		UPDATE payments
+		SET refunded_amount = refunded_amount + $2,
+		    status = CASE WHEN refunded_amount + $2 >= amount THEN 'refunded' ELSE 'captured' END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, paymentID, amount)
+	return err
+}
+
+// refundDuePayment refunds a capture that should not have happened. The
+// refund_due status is claimed first so only one caller refunds.
+func refundDuePayment(paymentID int) error {
+	var provider, intentID string
+	var amount float64
+	err := db.DB.QueryRow(`
+		UPDATE payments SET status = 'refunding', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'refund_due'
+		RETURNING provider, intent_id, amount - refunded_amount
+	`, paymentID).Scan(&provider, &intentID, &amount)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := refundPayment(paymentID, provider, intentID, amount); err != nil {
+		status := "refund_due"
+		if errors.Is(err, payments.ErrRefundUnsupported) {
+			status = "refund_manual"
+		}
+		db.DB.Exec(`UPDATE payments SET status = $1 WHERE id = $2`, status, paymentID)
+		return err
+	}
+	return nil
+}
+
+// refundOrderPayment refunds amount of a cancelled order's captured payment
+// and records the outcome on its cancellation. The pending (or failed)
+// refund is claimed first so the cancel handler and the reconciler never
+// both refund it.
+func refundOrderPayment(orderID int, amount float64) error {
+	res, err := db.DB.Exec(`
+		UPDATE order_cancellations SET refund_status = 'processing'
+		WHERE order_id = $1 AND refund_status IN ('pending', 'failed')
+	`, orderID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errPaymentNotFound
+	}
+
+	var paymentID int
+	var provider, intentID string
+	err = db.DB.QueryRow(`
+		SELECT id, provider, intent_id FROM payments
+		WHERE order_id = $1 AND status = 'captured'
+		ORDER BY id DESC LIMIT 1
+	`, orderID).Scan(&paymentID, &provider, &intentID)
+	if err == nil {
+		err = refundPayment(paymentID, provider, intentID, amount)
+	}
+
+	refundStatus := "succeeded"
+	switch {
+	case errors.Is(err, payments.ErrRefundUnsupported):
+		// Settled by hand; the reconciler leaves these alone
+		refundStatus = "manual"
+	case err != nil:
+		refundStatus = "failed"
+	default:
+		db.DB.Exec(`UPDATE orders SET payment_status = 'refunded', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, orderID)
+	}
+	db.DB.Exec(`UPDATE order_cancellations SET refund_status = $1 WHERE order_id = $2`, refundStatus, orderID)
+	return err
+}
+
+// =========================================================================
+// PAYMENT RECONCILIATION
+// =========================================================================
+
+// StartPaymentReconciler periodically settles payments whose webhooks never
+// arrived, cancels orders left unpaid past the payment timeout and retries
+// failed refunds.
+func StartPaymentReconciler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := reconcilePayments(time.Now()); err != nil {
+				log.Println("Payment reconciler:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func reconcilePayments(now time.Time) error {
+	// 1. Ask providers about payments still open. Recent ones are skipped so
+	// customers have time to pay and webhooks time to arrive. Payments
+	// cancelled with their order in the last day are still checked, as
+	// on-chain transfers can confirm after the order expired; they are
+	// refunded when found.
+	rows, err := db.DB.Query(`
+		SELECT provider, intent_id, amount FROM payments
+		WHERE (status IN ('pending', 'authorized') AND created_at < $1)
+		   OR (status = 'cancelled' AND updated_at > $2)
+		ORDER BY id
+	`, now.Add(-time.Minute).UTC(), now.Add(-24*time.Hour).UTC())
+	if err != nil {
+		return err
+	}
+	type openPayment struct {
+		provider, intentID string
+		amount             float64
+	}
+	var open []openPayment
+	for rows.Next() {
+		var p openPayment
+		if err := rows.Scan(&p.provider, &p.intentID, &p.amount); err == nil {
+			open = append(open, p)
+		}
+	}
+	rows.Close()
+
+	for _, p := range open {
+		provider, err := payments.Get(p.provider)
+		if err != nil {
+			continue
+		}
+		if err := syncPayment(provider, p.intentID, p.amount); err != nil {
+			log.Printf("Payment reconciler: %s %s: %v", p.provider, p.intentID, err)
+		}
+	}
+
+	// 2. Cancel orders that were never paid
+	expired, err := queryIDs(`
+		SELECT id FROM orders
+		WHERE status = 'pending' AND payment_status IN ('pending', 'failed') AND created_at < $1
+	`, now.Add(-paymentTimeout()).UTC())
+	if err != nil {
+		return err
+	}
+	for _, orderID := range expired {
+		if err := expireUnpaidOrder(orderID, now); err != nil {
+			log.Printf("Payment reconciler: expire order %d: %v", orderID, err)
+		}
+	}
+
+	// 3. Retry refunds that did not go through
+	due, err := queryIDs(`SELECT id FROM payments WHERE status = 'refund_due'`)
+	if err != nil {
+		return err
+	}
+	for _, paymentID := range due {
+		if err := refundDuePayment(paymentID); err != nil {
+			log.Printf("Payment reconciler: refund payment %d: %v", paymentID, err)
+		}
+	}
+
+	rows, err = db.DB.Query(`
+		SELECT order_id, refund_amount FROM order_cancellations
+		WHERE refund_status IN ('pending', 'failed')
+	`)
+	if err != nil {
+		return err
+	}
+	refunds := map[int]float64{}
+	for rows.Next() {
+		var orderID int
+		var amount float64
+		if err := rows.Scan(&orderID, &amount); err == nil {
+			refunds[orderID] = amount
+		}
+	}
+	rows.Close()
+	for orderID, amount := range refunds {
+		if err := refundOrderPayment(orderID, amount); err != nil {
+			log.Printf("Payment reconciler: refund order %d: %v", orderID, err)
+		}
+	}
+	return nil
+}
+
+// queryIDs runs a query selecting a single int column.
+func queryIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// expireUnpaidOrder fails an order whose payment never completed and puts
+// its product back on sale. Payments still open are marked cancelled; if
+// one is captured later it is refunded.
+func expireUnpaidOrder(orderID int, now time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	o, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if o.Status != models.OrderStatusPending || (o.PaymentStatus != models.PaymentStatusPending && o.PaymentStatus != models.PaymentStatusFailed) {
+		return nil
+	}
+	if err := transitionOrder(tx, &o, models.OrderStatusPaymentFailed); err != nil {
+		return err
+	}
+	if err := restockProduct(tx, o.ProductID, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE orders SET payment_status = 'failed' WHERE id = $1`, o.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE payments SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP WHERE order_id = $1 AND status IN ('pending', 'authorized')`, o.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	notifyOrderTransition(o, "")
+	return nil
+}
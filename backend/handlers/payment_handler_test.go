@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"food-platform-backend/payments"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// PAYMENT TESTS
+// =========================================================================
+
+func TestPaymentTimeout(t *testing.T) {
+	t.Setenv("PAYMENT_TIMEOUT_MINUTES", "")
+	assert.Equal(t, 15*time.Minute, paymentTimeout())
+	t.Setenv("PAYMENT_TIMEOUT_MINUTES", "5")
+	assert.Equal(t, 5*time.Minute, paymentTimeout())
+	t.Setenv("PAYMENT_TIMEOUT_MINUTES", "-1")
+	assert.Equal(t, 15*time.Minute, paymentTimeout())
+}
+
+func TestPayOrderValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/orders/:id/pay", AuthRequired(), PayOrder)
+
+	cases := []struct {
+		path, body string
+		status     int
+	}{
+		{"/orders/1/pay", `{}`, http.StatusBadRequest},
+		{"/orders/abc/pay", `{"payment_method":"fake"}`, http.StatusBadRequest},
+		{"/orders/1/pay", `{"payment_method":"bitcoin"}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "c1"))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.body)
+	}
+
+	// The consumer comes from the token, not the body
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/orders/1/pay", bytes.NewBufferString(`{"consumer_id":"c1","payment_method":"fake"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestPaymentWebhookRejectsUnverified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	payments.Register(payments.NewFakeProvider("http://unused", "whsec_test"))
+	payments.Register(payments.NewLinePay("http://unused", "id", "secret", ""))
+	r := gin.New()
+	r.POST("/payments/webhook/:provider", PaymentWebhook)
+
+	body := `{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_1"}}}`
+	cases := []struct {
+		provider, signature string
+		status              int
+	}{
+		{"bitcoin", "", http.StatusNotFound},
+		{"linepay", "", http.StatusNotFound},
+		{"fake", "", http.StatusBadRequest},
+		{"fake", payments.SignStripePayload("whsec_other", []byte(body), time.Now()), http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/payments/webhook/"+tc.provider, bytes.NewBufferString(body))
+		req.Header.Set("Stripe-Signature", tc.signature)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.provider)
+	}
+}
+
+func TestPaymentReturnValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	payments.Register(payments.NewFakeProvider("http://unused", "whsec_test"))
+	r := gin.New()
+	r.GET("/payments/:provider/return", PaymentReturn)
+
+	for path, status := range map[string]int{
+		"/payments/bitcoin/return?order_id=1": http.StatusNotFound,
+		"/payments/fake/return":               http.StatusBadRequest,
+		"/payments/fake/return?order_id=x":    http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+}
+
+func TestPurchaseProductUnknownPaymentMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/purchase/:id", AuthRequired(), PurchaseProduct)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/purchase/1", bytes.NewBufferString(`{"payment_method":"bitcoin"}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "c1"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// pickupCodeAlphabet leaves out characters that are easy to misread
+// (0/O, 1/I). Its length divides 256, so byte%len is unbiased.
+const pickupCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const pickupCodeLength = 6
+
+var errInvalidPickupPass = errors.New("invalid pickup pass")
+
+var (
+	pickupKeyOnce sync.Once
+	pickupKey     ed25519.PrivateKey
+)
+
+// pickupSigningKey is the Ed25519 key that signs pickup QR payloads. It is
+// seeded from PICKUP_SIGNING_SEED (64 hex chars) or, failing that, derived
+// from the JWT secret so that every instance signs with the same key.
+func pickupSigningKey() ed25519.PrivateKey {
+	pickupKeyOnce.Do(func() {
+		seed, err := hex.DecodeString(os.Getenv("PICKUP_SIGNING_SEED"))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			if os.Getenv("PICKUP_SIGNING_SEED") != "" {
+				log.Println("PICKUP_SIGNING_SEED must be 64 hex characters, deriving key from JWT secret")
+			}
+			sum := sha256.Sum256(append([]byte("pickup-pass:"), jwtSecret...))
+			seed = sum[:]
+		}
+		pickupKey = ed25519.NewKeyFromSeed(seed)
+	})
+	return pickupKey
+}
+
+// randomPickupCode returns a fresh code of pickupCodeLength characters.
+func randomPickupCode() (string, error) {
+	b := make([]byte, pickupCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = pickupCodeAlphabet[int(b[i])%len(pickupCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// newPickupCode picks a code not used by any open order of the product's
+// merchant. uk_orders_merchant_id_pickup_code still rejects a code a
+// concurrent order took first; callers retry on isUniqueViolation.
+func newPickupCode(q queryer, productID int) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := randomPickupCode()
+		if err != nil {
+			return "", err
+		}
+		var taken bool
+		err = q.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM orders
+				WHERE merchant_id = (SELECT merchant_id FROM products WHERE id = $1)
+				  AND pickup_code = $2 AND COALESCE(status, 'pending') IN ('pending', 'confirmed', 'ready_for_pickup')
+			)
+		`, productID, code).Scan(&taken)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+	return "", errors.New("could not allocate a pickup code")
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// normalizePickupCode upper-cases a typed code and drops spaces and dashes.
+func normalizePickupCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// signPickupPass encodes a pass as "<base64url json>.<base64url signature>".
+func signPickupPass(pass models.PickupPass, key ed25519.PrivateKey) (string, error) {
+	pass.Payload = ""
+	body, err := json.Marshal(pass)
+	if err != nil {
+		return "", err
+	}
+	sig := ed25519.Sign(key, body)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(body) + "." + enc.EncodeToString(sig), nil
+}
+
+// verifyPickupPass checks a payload's signature and returns its contents.
+// Expiry is left to the caller.
+func verifyPickupPass(payload string, pub ed25519.PublicKey) (models.PickupPass, error) {
+	var pass models.PickupPass
+	bodyPart, sigPart, found := strings.Cut(payload, ".")
+	if !found {
+		return pass, errInvalidPickupPass
+	}
+	enc := base64.RawURLEncoding
+	body, err := enc.DecodeString(bodyPart)
+	if err != nil {
+		return pass, errInvalidPickupPass
+	}
+	sig, err := enc.DecodeString(sigPart)
+	if err != nil || !ed25519.Verify(pub, body, sig) {
+		return pass, errInvalidPickupPass
+	}
+	if err := json.Unmarshal(body, &pass); err != nil {
+		return pass, errInvalidPickupPass
+	}
+	return pass, nil
+}
+
+// =========================================================================
+// PICKUP VERIFICATION
+// =========================================================================
+
+// GetPickupPass - GET /me/orders/:id/pickup-pass
+// Returns the order's pickup code and signed QR payload.
+func GetPickupPass(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var pass models.PickupPass
+	var consumerID string
+	var code sql.NullString
+	var status models.OrderStatus
+	err = db.DB.QueryRow(`
+		SELECT o.id, o.consumer_id, COALESCE(o.merchant_id, p.merchant_id, ''), COALESCE(o.product_name, p.name),
+		       o.pickup_code, COALESCE(p.pickup_end, p.expiry_date), COALESCE(o.status, 'pending')
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = $1
+	`, orderID).Scan(&pass.OrderID, &consumerID, &pass.MerchantID, &pass.ProductName, &code, &pass.ExpiresAt, &status)
+	if err == sql.ErrNoRows || (err == nil && consumerID != c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if status.IsFinal() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is already " + string(status)})
+		return
+	}
+
+	// Orders placed before pickup codes existed get one on first request
+	if !code.Valid {
+		var productID int
+		db.DB.QueryRow("SELECT product_id FROM orders WHERE id = $1", orderID).Scan(&productID)
+		for attempt := 0; attempt < 3; attempt++ {
+			var newCode string
+			if newCode, err = newPickupCode(db.DB, productID); err != nil {
+				break
+			}
+			_, err = db.DB.Exec("UPDATE orders SET pickup_code = $1 WHERE id = $2 AND pickup_code IS NULL", newCode, orderID)
+			if !isUniqueViolation(err) {
+				break
+			}
+		}
+		if err == nil {
+			err = db.DB.QueryRow("SELECT pickup_code FROM orders WHERE id = $1", orderID).Scan(&code)
+		}
+		if err != nil || !code.Valid {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue pickup code"})
+			return
+		}
+	}
+	pass.Code = code.String
+	pass.ExpiresAt = pass.ExpiresAt.UTC()
+
+	pass.Payload, err = signPickupPass(pass, pickupSigningKey())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign pickup pass"})
+		return
+	}
+	c.JSON(http.StatusOK, pass)
+}
+
+// GetPickupPublicKey - GET /pickup/public-key
+// Lets staff apps verify QR payloads without reaching the server.
+func GetPickupPublicKey(c *gin.Context) {
+	pub := pickupSigningKey().Public().(ed25519.PublicKey)
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "Ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(pub),
+		"format":     "base64url(json) + \".\" + base64url(signature over the json bytes)",
+	})
+}
+
+// LookupPickupPass - POST /merchant/pickup/lookup
+// Verifies a scanned payload without touching the database, so the counter
+// can still identify an order when the order service is unreachable.
+func LookupPickupPass(c *gin.Context) {
+	var input struct {
+		Payload string `json:"payload" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload required"})
+		return
+	}
+
+	pass, err := verifyPickupPass(input.Payload, pickupSigningKey().Public().(ed25519.PublicKey))
+	if err != nil || pass.MerchantID != c.GetString("user_id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid pickup pass"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pass": pass, "expired": time.Now().After(pass.ExpiresAt)})
+}
+
+// RedeemPickup - POST /merchant/pickup/redeem
+// Accepts a typed code or a scanned payload and marks the order picked up.
+// A code can only be redeemed once, and only by the shop that sold it.
+func RedeemPickup(c *gin.Context) {
+	var input struct {
+		Code    string `json:"code"`
+		Payload string `json:"payload"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (input.Code == "" && input.Payload == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code or payload required"})
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	code := normalizePickupCode(input.Code)
+	orderID := 0
+	if input.Payload != "" {
+		pass, err := verifyPickupPass(input.Payload, pickupSigningKey().Public().(ed25519.PublicKey))
+		if err != nil || pass.MerchantID != merchantID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid pickup code"})
+			return
+		}
+		orderID, code = pass.OrderID, pass.Code
+	} else {
+		// Prefer the open order if a code was reused after an earlier pickup
+		err := db.DB.QueryRow(`
+			SELECT id FROM orders
+			WHERE merchant_id = $1 AND pickup_code = $2
+			ORDER BY COALESCE(status, 'pending') IN ('pending', 'confirmed', 'ready_for_pickup') DESC, id DESC
+			LIMIT 1
+		`, merchantID, code).Scan(&orderID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid pickup code"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := lockOrder(tx, orderID)
+	var storedCode sql.NullString
+	if err == nil {
+		err = tx.QueryRow("SELECT pickup_code FROM orders WHERE id = $1", orderID).Scan(&storedCode)
+	}
+	if err == nil && (o.MerchantID != merchantID || storedCode.String != code) {
+		err = errOrderNotFound
+	}
+	if errors.Is(err, errOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid pickup code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if o.Status == models.OrderStatusPickedUp {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pickup code already used"})
+		return
+	}
+
+	// Handing the food over implies the shop accepted the order
+	if o.Status == models.OrderStatusPending {
+		err = transitionOrder(tx, &o, models.OrderStatusConfirmed)
+	}
+	if err == nil {
+		err = transitionOrder(tx, &o, models.OrderStatusPickedUp)
+	}
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	notifyOrderTransition(o, "")
+	c.JSON(http.StatusOK, gin.H{"message": "Order picked up", "order_id": o.ID, "product_name": o.ProductName})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// PICKUP VERIFICATION TESTS
+// =========================================================================
+
+func testPickupPass() models.PickupPass {
+	return models.PickupPass{
+		OrderID:     42,
+		MerchantID:  "merchant-1",
+		ProductName: "Sushi Box",
+		Code:        "K7M2QX",
+		ExpiresAt:   time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRandomPickupCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		code, err := randomPickupCode()
+		assert.NoError(t, err)
+		assert.Len(t, code, pickupCodeLength)
+		for _, r := range code {
+			assert.Contains(t, pickupCodeAlphabet, string(r))
+		}
+		seen[code] = true
+	}
+	assert.Greater(t, len(seen), 45)
+}
+
+func TestNormalizePickupCode(t *testing.T) {
+	assert.Equal(t, "K7M2QX", normalizePickupCode(" k7m-2qx "))
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	assert.True(t, isUniqueViolation(fmt.Errorf("insert order: %w", &pq.Error{Code: "23505"})))
+	assert.False(t, isUniqueViolation(&pq.Error{Code: "23503"}))
+	assert.False(t, isUniqueViolation(nil))
+}
+
+func TestPickupPassRoundTrip(t *testing.T) {
+	key := pickupSigningKey()
+	payload, err := signPickupPass(testPickupPass(), key)
+	assert.NoError(t, err)
+
+	pass, err := verifyPickupPass(payload, key.Public().(ed25519.PublicKey))
+	assert.NoError(t, err)
+	assert.Equal(t, testPickupPass(), pass)
+}
+
+func TestPickupPassRejectsTampering(t *testing.T) {
+	key := pickupSigningKey()
+	payload, _ := signPickupPass(testPickupPass(), key)
+	pub := key.Public().(ed25519.PublicKey)
+
+	// Re-signing someone else's order with another key
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	forged, _ := signPickupPass(testPickupPass(), otherKey)
+	_, err := verifyPickupPass(forged, pub)
+	assert.ErrorIs(t, err, errInvalidPickupPass)
+
+	// Swapping the body while keeping the signature
+	other := testPickupPass()
+	other.OrderID = 43
+	otherPayload, _ := signPickupPass(other, key)
+	spliced := strings.Split(otherPayload, ".")[0] + "." + strings.Split(payload, ".")[1]
+	_, err = verifyPickupPass(spliced, pub)
+	assert.ErrorIs(t, err, errInvalidPickupPass)
+
+	_, err = verifyPickupPass("not-a-payload", pub)
+	assert.ErrorIs(t, err, errInvalidPickupPass)
+}
+
+func TestLookupPickupPassOtherShop(t *testing.T) {
+	payload, _ := signPickupPass(testPickupPass(), pickupSigningKey())
+
+	r := gin.New()
+	r.POST("/merchant/pickup/lookup", AuthRequired(), LookupPickupPass)
+
+	// Works without the database for the owning shop
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/merchant/pickup/lookup", bytes.NewBufferString(`{"payload": "`+payload+`"}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-1"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Sushi Box")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/merchant/pickup/lookup", bytes.NewBufferString(`{"payload": "`+payload+`"}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-2"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRedeemPickupValidation(t *testing.T) {
+	r := gin.New()
+	r.POST("/merchant/pickup/redeem", AuthRequired(), RedeemPickup)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/merchant/pickup/redeem", bytes.NewBufferString(`{}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-1"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A pass signed for another shop is refused before any lookup
+	payload, _ := signPickupPass(testPickupPass(), pickupSigningKey())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/merchant/pickup/redeem", bytes.NewBufferString(`{"payload": "`+payload+`"}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-2"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
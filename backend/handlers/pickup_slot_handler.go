@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errSlotNotFound    = errors.New("pickup slot not found")
+	errSlotFull        = errors.New("pickup slot is full")
+	errSlotUnavailable = errors.New("pickup slot is outside the pickup window")
+)
+
+// defaultPickupReminderMinutes is how long before a slot starts its
+// customers are reminded, overridable with PICKUP_REMINDER_MINUTES.
+const defaultPickupReminderMinutes = 30
+
+// pickupReminderLead is how far ahead of a slot reminders go out.
+func pickupReminderLead() time.Duration {
+	minutes := defaultPickupReminderMinutes
+	if v, err := strconv.Atoi(os.Getenv("PICKUP_REMINDER_MINUTES")); err == nil && v > 0 {
+		minutes = v
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+type slotSpan struct {
+	start, end time.Time
+}
+
+// pickupSlotTimes splits the business hours opening on day into slots of
+// length. A trailing remainder shorter than length is not offered.
+func pickupSlotTimes(open, close string, day time.Time, loc *time.Location, length time.Duration) ([]slotSpan, error) {
+	o, err := time.Parse("15:04", open)
+	if err != nil {
+		return nil, fmt.Errorf("invalid business hours open %q", open)
+	}
+	if _, err := time.Parse("15:04", close); err != nil {
+		return nil, fmt.Errorf("invalid business hours close %q", close)
+	}
+
+	d := day.In(loc)
+	opening := time.Date(d.Year(), d.Month(), d.Day(), o.Hour(), o.Minute(), 0, 0, loc)
+	start, end, ok := businessHoursSpan(open, close, opening, loc)
+	if !ok {
+		return nil, nil
+	}
+
+	var slots []slotSpan
+	for t := start; !t.Add(length).After(end); t = t.Add(length) {
+		slots = append(slots, slotSpan{start: t.UTC(), end: t.Add(length).UTC()})
+	}
+	return slots, nil
+}
+
+// slotFitsPickup reports whether a slot overlaps the listing's pickup window
+// and has not ended yet.
+func slotFitsPickup(slotStart, slotEnd, pickupStart, pickupEnd, now time.Time) bool {
+	return slotStart.Before(pickupEnd) && slotEnd.After(pickupStart) && slotEnd.After(now)
+}
+
+// respondSlotError maps slot booking errors to responses.
+func respondSlotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errSlotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pickup slot not found"})
+	case errors.Is(err, errSlotFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "Pickup slot is full"})
+	case errors.Is(err, errSlotUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Pickup slot is outside the pickup window"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to book pickup slot"})
+	}
+}
+
+// lockPickupSlots locks slots in id order so that two bookings touching the
+// same slots cannot deadlock.
+func lockPickupSlots(tx *sql.Tx, ids ...int) error {
+	sort.Ints(ids)
+	for _, id := range ids {
+		var locked int
+		err := tx.QueryRow("SELECT id FROM pickup_slots WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return nil
+}
+
+// bookPickupSlot books slotID for an order created or locked in tx. The
+// slot must belong to the order's merchant, overlap the listing's pickup
+// window and have capacity left.
+func bookPickupSlot(tx *sql.Tx, orderID, slotID int, now time.Time) error {
+	var merchantID string
+	var pickupStart, pickupEnd time.Time
+	err := tx.QueryRow(`
+		SELECT COALESCE(o.merchant_id, p.merchant_id, ''), COALESCE(p.pickup_start, p.created_at), COALESCE(p.pickup_end, p.expiry_date)
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = $1
+	`, orderID).Scan(&merchantID, &pickupStart, &pickupEnd)
+	if err != nil {
+		return err
+	}
+
+	var slotMerchant string
+	var startsAt, endsAt time.Time
+	var capacity, booked int
+	err = tx.QueryRow(`
+		SELECT COALESCE(merchant_id, ''), starts_at, ends_at, capacity, COALESCE(booked, 0)
+		FROM pickup_slots WHERE id = $1 FOR UPDATE
+	`, slotID).Scan(&slotMerchant, &startsAt, &endsAt, &capacity, &booked)
+	if err == sql.ErrNoRows || (err == nil && slotMerchant != merchantID) {
+		return errSlotNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !slotFitsPickup(startsAt, endsAt, pickupStart, pickupEnd, now) {
+		return errSlotUnavailable
+	}
+	if booked >= capacity {
+		return errSlotFull
+	}
+
+	if _, err := tx.Exec("UPDATE pickup_slots SET booked = COALESCE(booked, 0) + 1 WHERE id = $1", slotID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO pickup_schedules (order_id, slot_id, scheduled_time, status)
+		VALUES ($1, $2, $3, 'pending')
+		ON CONFLICT (order_id) DO UPDATE SET slot_id = $2, scheduled_time = $3, status = 'pending'
+	`, orderID, slotID, startsAt)
+	return err
+}
+
+// releasePickupSlot frees the slot booked by an order, if any.
+func releasePickupSlot(tx *sql.Tx, orderID int) error {
+	_, err := tx.Exec(`
+		UPDATE pickup_slots SET booked = booked - 1
+		WHERE booked > 0 AND id = (
+			SELECT slot_id FROM pickup_schedules WHERE order_id = $1 AND status <> 'cancelled'
+		)
+	`, orderID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE pickup_schedules SET status = 'cancelled' WHERE order_id = $1", orderID)
+	return err
+}
+
+// =========================================================================
+// PICKUP SLOTS
+// =========================================================================
+
+// GeneratePickupSlots - POST /merchant/pickup-slots
+// Creates slots covering the merchant's business hours on a date. Slots that
+// already exist are kept as they are.
+func GeneratePickupSlots(c *gin.Context) {
+	var input struct {
+		Date        string `json:"date" binding:"required"`
+		SlotMinutes int    `json:"slot_minutes" binding:"omitempty,min=5,max=240"`
+		Capacity    int    `json:"capacity" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": bindingFieldErrors(err, &input)})
+		return
+	}
+	if input.SlotMinutes == 0 {
+		input.SlotMinutes = 30
+	}
+	merchantID := c.GetString("user_id")
+
+	var open, close, tz string
+	err := db.DB.QueryRow(`
+		SELECT COALESCE(business_hours_open, ''), COALESCE(business_hours_close, ''), COALESCE(timezone, '')
+		FROM merchants WHERE user_id = $1
+	`, merchantID).Scan(&open, &close, &tz)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	loc := loadLocation(tz)
+	day, err := time.ParseInLocation("2006-01-02", input.Date, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+	slots, err := pickupSlotTimes(open, close, day, loc, time.Duration(input.SlotMinutes)*time.Minute)
+	if err != nil || len(slots) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set business hours before generating pickup slots"})
+		return
+	}
+
+	created := 0
+	for _, s := range slots {
+		res, err := db.DB.Exec(`
+			INSERT INTO pickup_slots (merchant_id, starts_at, ends_at, capacity)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (merchant_id, starts_at) DO NOTHING
+		`, merchantID, s.start, s.end, input.Capacity)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pickup slots"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			created++
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Pickup slots generated", "created": created, "total": len(slots)})
+}
+
+// scanPickupSlots reads slot rows, converting times to loc.
+func scanPickupSlots(rows *sql.Rows, loc *time.Location) []models.PickupSlot {
+	slots := []models.PickupSlot{}
+	for rows.Next() {
+		var s models.PickupSlot
+		if err := rows.Scan(&s.ID, &s.MerchantID, &s.StartsAt, &s.EndsAt, &s.Capacity, &s.Booked); err != nil {
+			continue
+		}
+		s.StartsAt, s.EndsAt = s.StartsAt.In(loc), s.EndsAt.In(loc)
+		s.Remaining = s.Capacity - s.Booked
+		if s.Remaining < 0 {
+			s.Remaining = 0
+		}
+		slots = append(slots, s)
+	}
+	return slots
+}
+
+// GetMerchantPickupSlots - GET /merchant/pickup-slots?date=YYYY-MM-DD
+func GetMerchantPickupSlots(c *gin.Context) {
+	merchantID := c.GetString("user_id")
+	loc := merchantLocation(db.DB, merchantID)
+
+	day := time.Now().In(loc)
+	if date := c.Query("date"); date != "" {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", date, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+	}
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+
+	rows, err := db.DB.Query(`
+		SELECT id, merchant_id, starts_at, ends_at, capacity, COALESCE(booked, 0)
+		FROM pickup_slots
+		WHERE merchant_id = $1 AND starts_at >= $2 AND starts_at < $3
+		ORDER BY starts_at
+	`, merchantID, from.UTC(), from.AddDate(0, 0, 1).UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pickup slots"})
+		return
+	}
+	defer rows.Close()
+
+	c.JSON(http.StatusOK, scanPickupSlots(rows, loc))
+}
+
+// GetProductPickupSlots - GET /products/:id/pickup-slots
+// Lists the merchant's upcoming slots that fall within the listing's pickup
+// window, so the consumer can pick one at purchase.
+func GetProductPickupSlots(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var merchantID, tz string
+	var pickupStart, pickupEnd time.Time
+	err = db.DB.QueryRow(`
+		SELECT COALESCE(p.merchant_id, ''), COALESCE(p.pickup_start, p.created_at), COALESCE(p.pickup_end, p.expiry_date), COALESCE(m.timezone, '')
+		FROM products p
+		LEFT JOIN merchants m ON m.user_id = p.merchant_id
+		WHERE p.id = $1
+	`, productID).Scan(&merchantID, &pickupStart, &pickupEnd, &tz)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT id, merchant_id, starts_at, ends_at, capacity, COALESCE(booked, 0)
+		FROM pickup_slots
+		WHERE merchant_id = $1 AND starts_at < $2 AND ends_at > $3 AND ends_at > $4
+		ORDER BY starts_at
+	`, merchantID, pickupEnd, pickupStart, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pickup slots"})
+		return
+	}
+	defer rows.Close()
+
+	c.JSON(http.StatusOK, scanPickupSlots(rows, loadLocation(tz)))
+}
+
+// ChangePickupSlot - PUT /orders/:id/pickup-slot
+// Moves one of the caller's open orders to another slot, freeing its
+// previous one.
+func ChangePickupSlot(c *gin.Context) {
+	var input struct {
+		SlotID int `json:"slot_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slot ID required"})
+		return
+	}
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	o, err := lockOrder(tx, orderID)
+	if err == nil && o.ConsumerID != c.GetString("user_id") {
+		err = errOrderNotFound
+	}
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+	if o.Status.IsFinal() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is already " + string(o.Status)})
+		return
+	}
+
+	slotIDs := []int{input.SlotID}
+	var current sql.NullInt64
+	tx.QueryRow("SELECT slot_id FROM pickup_schedules WHERE order_id = $1 AND status <> 'cancelled'", orderID).Scan(&current)
+	if current.Valid {
+		if int(current.Int64) == input.SlotID {
+			c.JSON(http.StatusOK, gin.H{"message": "Pickup slot unchanged", "slot_id": input.SlotID})
+			return
+		}
+		slotIDs = append(slotIDs, int(current.Int64))
+	}
+	if err := lockPickupSlots(tx, slotIDs...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := releasePickupSlot(tx, orderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release pickup slot"})
+		return
+	}
+	if err := bookPickupSlot(tx, orderID, input.SlotID, time.Now()); err != nil {
+		respondSlotError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pickup slot updated", "slot_id": input.SlotID})
+}
+
+// GetPickupSlotManifest - GET /merchant/pickup-slots/:id/manifest
+// Lists who is coming during a slot.
+func GetPickupSlotManifest(c *gin.Context) {
+	slotID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slot ID"})
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	var slot models.PickupSlot
+	err = db.DB.QueryRow(`
+		SELECT id, merchant_id, starts_at, ends_at, capacity, COALESCE(booked, 0)
+		FROM pickup_slots WHERE id = $1 AND merchant_id = $2
+	`, slotID, merchantID).Scan(&slot.ID, &slot.MerchantID, &slot.StartsAt, &slot.EndsAt, &slot.Capacity, &slot.Booked)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pickup slot not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	loc := merchantLocation(db.DB, merchantID)
+	slot.StartsAt, slot.EndsAt = slot.StartsAt.In(loc), slot.EndsAt.In(loc)
+	slot.Remaining = slot.Capacity - slot.Booked
+
+	rows, err := db.DB.Query(`
+		SELECT o.id, o.consumer_id, COALESCE(o.product_name, p.name), COALESCE(o.status, 'pending')
+		FROM pickup_schedules ps
+		JOIN orders o ON o.id = ps.order_id
+		JOIN products p ON p.id = o.product_id
+		WHERE ps.slot_id = $1 AND ps.status <> 'cancelled'
+		ORDER BY o.id
+	`, slotID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch manifest"})
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.PickupManifestEntry{}
+	for rows.Next() {
+		var e models.PickupManifestEntry
+		if err := rows.Scan(&e.OrderID, &e.ConsumerID, &e.ProductName, &e.Status); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slot": slot, "orders": entries})
+}
+
+// =========================================================================
+// PICKUP REMINDERS
+// =========================================================================
+
+// StartPickupReminders notifies customers of slots starting soon every
+// interval.
+func StartPickupReminders(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := sendPickupReminders(time.Now()); err != nil {
+				log.Println("Pickup reminders:", err)
+			} else if n > 0 {
+				log.Printf("Pickup reminders: sent %d reminders", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// sendPickupReminders marks slots starting within the reminder lead as
+// reminded and notifies their open orders after commit.
+func sendPickupReminders(now time.Time) (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id FROM pickup_slots
+		WHERE reminded_at IS NULL AND booked > 0 AND starts_at > $1 AND starts_at <= $2
+		ORDER BY id
+		FOR UPDATE SKIP LOCKED
+	`, now.UTC(), now.Add(pickupReminderLead()).UTC())
+	if err != nil {
+		return 0, err
+	}
+	var slotIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		slotIDs = append(slotIDs, id)
+	}
+	rows.Close()
+
+	type reminder struct {
+		consumerID, productName string
+		startsAt                time.Time
+		tz                      string
+	}
+	var reminders []reminder
+	for _, id := range slotIDs {
+		rows, err := tx.Query(`
+			SELECT o.consumer_id, COALESCE(o.product_name, p.name), s.starts_at, COALESCE(m.timezone, '')
+			FROM pickup_schedules ps
+			JOIN pickup_slots s ON s.id = ps.slot_id
+			JOIN orders o ON o.id = ps.order_id
+			JOIN products p ON p.id = o.product_id
+			LEFT JOIN merchants m ON m.user_id = s.merchant_id
+			WHERE ps.slot_id = $1 AND ps.status <> 'cancelled'
+			  AND COALESCE(o.status, 'pending') IN ('pending', 'confirmed', 'ready_for_pickup')
+		`, id)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			var r reminder
+			if err := rows.Scan(&r.consumerID, &r.productName, &r.startsAt, &r.tz); err == nil {
+				reminders = append(reminders, r)
+			}
+		}
+		rows.Close()
+
+		if _, err := tx.Exec("UPDATE pickup_slots SET reminded_at = CURRENT_TIMESTAMP WHERE id = $1", id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range reminders {
+		at := r.startsAt.In(loadLocation(r.tz)).Format("15:04")
+		notifyUser(r.consumerID, "Pickup reminder", fmt.Sprintf("Your pickup slot for %s starts at %s.", r.productName, at), "pickup")
+	}
+	return len(reminders), nil
+}
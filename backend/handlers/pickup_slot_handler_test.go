@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// PICKUP SLOT TESTS
+// =========================================================================
+
+func TestPickupSlotTimes(t *testing.T) {
+	loc := loadLocation("Asia/Taipei")
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+
+	slots, err := pickupSlotTimes("18:00", "20:00", day, loc, 30*time.Minute)
+	assert.NoError(t, err)
+	assert.Len(t, slots, 4)
+	assert.Equal(t, time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), slots[0].start)
+	assert.Equal(t, time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), slots[3].end)
+
+	// A remainder shorter than a slot is dropped
+	slots, err = pickupSlotTimes("18:00", "19:45", day, loc, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, slots, 1)
+
+	_, err = pickupSlotTimes("", "20:00", day, loc, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestPickupSlotTimesOvernight(t *testing.T) {
+	loc := loadLocation("Asia/Taipei")
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+
+	slots, err := pickupSlotTimes("22:00", "02:00", day, loc, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, slots, 4)
+	assert.Equal(t, time.Date(2026, 3, 3, 2, 0, 0, 0, loc).UTC(), slots[3].end)
+}
+
+func TestSlotFitsPickup(t *testing.T) {
+	base := time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)
+	pickupStart, pickupEnd := base, base.Add(2*time.Hour)
+
+	assert.True(t, slotFitsPickup(base.Add(30*time.Minute), base.Add(time.Hour), pickupStart, pickupEnd, base))
+	// Partial overlap at either edge is allowed
+	assert.True(t, slotFitsPickup(base.Add(-30*time.Minute), base.Add(30*time.Minute), pickupStart, pickupEnd, base.Add(-time.Hour)))
+	// Entirely after the pickup window
+	assert.False(t, slotFitsPickup(pickupEnd, pickupEnd.Add(30*time.Minute), pickupStart, pickupEnd, base))
+	// Already over
+	assert.False(t, slotFitsPickup(base, base.Add(30*time.Minute), pickupStart, pickupEnd, base.Add(time.Hour)))
+}
+
+func TestGeneratePickupSlotsValidation(t *testing.T) {
+	r := gin.New()
+	r.POST("/merchant/pickup-slots", AuthRequired(), GeneratePickupSlots)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/merchant/pickup-slots", bytes.NewBufferString(`{"date": "2026-03-02"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/merchant/pickup-slots", bytes.NewBufferString(`{"date": "2026-03-02", "capacity": 0}`))
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "merchant-1"))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "capacity")
+}
+
+func TestChangePickupSlotValidation(t *testing.T) {
+	r := gin.New()
+	r.PUT("/orders/:id/pickup-slot", AuthRequired(), ChangePickupSlot)
+	token := "Bearer " + signedTestToken(t, "c1")
+
+	// The consumer comes from the token, not the body
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/orders/1/pickup-slot", bytes.NewBufferString(`{"consumer_id": "c1", "slot_id": 3}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/orders/1/pickup-slot", bytes.NewBufferString(`{}`))
+	req.Header.Set("Authorization", token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/orders/abc/pickup-slot", bytes.NewBufferString(`{"slot_id": 3}`))
+	req.Header.Set("Authorization", token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid order ID")
+}
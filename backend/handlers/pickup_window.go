@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"food-platform-backend/models"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultPickupLeadMinutes is how long before the pickup window closes a
+// purchase is still accepted, overridable with PICKUP_MIN_LEAD_MINUTES.
+const defaultPickupLeadMinutes = 10
+
+// pickupLeadTime is the minimum time a buyer needs to reach the shop.
+func pickupLeadTime() time.Duration {
+	minutes := defaultPickupLeadMinutes
+	if v, err := strconv.Atoi(os.Getenv("PICKUP_MIN_LEAD_MINUTES")); err == nil && v >= 0 {
+		minutes = v
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// listingTimes resolves when a listing expires and when it can be collected.
+// Without an explicit window, pickup runs from listing until expiry.
+func listingTimes(input productInput, listedAt time.Time) (expiry, pickupStart, pickupEnd time.Time) {
+	expiry = listedAt.Add(time.Duration(input.ExpiryMinutes) * time.Minute).UTC()
+	pickupStart, pickupEnd = listedAt.UTC(), expiry
+	if input.PickupStart != nil {
+		pickupStart = input.PickupStart.UTC()
+	}
+	if input.PickupEnd != nil {
+		pickupEnd = input.PickupEnd.UTC()
+	}
+	return expiry, pickupStart, pickupEnd
+}
+
+// businessHoursSpan returns the opening span containing t. open and close
+// are "15:04" in loc; a close at or before open means the shop closes after
+// midnight, so the previous day's span is considered too.
+func businessHoursSpan(open, close string, t time.Time, loc *time.Location) (start, end time.Time, ok bool) {
+	o, err := time.Parse("15:04", open)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	cl, err := time.Parse("15:04", close)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	local := t.In(loc)
+	for _, dayOffset := range []int{-1, 0} {
+		d := local.AddDate(0, 0, dayOffset)
+		start = time.Date(d.Year(), d.Month(), d.Day(), o.Hour(), o.Minute(), 0, 0, loc)
+		end = time.Date(d.Year(), d.Month(), d.Day(), cl.Hour(), cl.Minute(), 0, 0, loc)
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !local.Before(start) && local.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// checkPickupWindow validates a listing's pickup window against its expiry
+// and the merchant's business hours. Empty hours skip the hours check.
+func checkPickupWindow(start, end, expiry, listedAt time.Time, hoursOpen, hoursClose string, loc *time.Location) []models.FieldError {
+	errs := []models.FieldError{}
+	add := func(field, code, message string) {
+		errs = append(errs, models.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if !end.After(start) {
+		add("pickup_end", "before_start", "Pickup must end after it starts")
+		return errs
+	}
+	if !end.After(listedAt) {
+		add("pickup_end", "in_past", "Pickup window has already ended")
+	}
+	if expiry.After(end) {
+		add("expiry_minutes", "after_pickup_end", "Listing cannot stay visible after the pickup window ends")
+	}
+
+	if _, err := time.Parse("15:04", hoursOpen); err != nil {
+		return errs
+	}
+	if _, err := time.Parse("15:04", hoursClose); err != nil {
+		return errs
+	}
+	hours := fmt.Sprintf("Pickup must be within business hours (%s-%s)", hoursOpen, hoursClose)
+	_, spanEnd, ok := businessHoursSpan(hoursOpen, hoursClose, start, loc)
+	if !ok {
+		add("pickup_start", "outside_business_hours", hours)
+	} else if end.After(spanEnd) {
+		add("pickup_end", "outside_business_hours", hours)
+	}
+
+	return errs
+}
+
+// purchaseBlockReason explains why a locked product cannot be bought at now,
+// or returns "" when it can.
+func purchaseBlockReason(status string, expiry time.Time, pickupEnd sql.NullTime, now time.Time) string {
+	if status == string(models.ProductStatusSold) {
+		return "Product already sold"
+	}
+	if status == string(models.ProductStatusReserved) {
+		return "Product is reserved"
+	}
+	if now.After(expiry) {
+		return "Product expired"
+	}
+	if pickupEnd.Valid && now.Add(pickupLeadTime()).After(pickupEnd.Time) {
+		return "Pickup window has ended"
+	}
+	return ""
+}
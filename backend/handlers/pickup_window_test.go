@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// PICKUP WINDOW TESTS
+// =========================================================================
+
+func TestBusinessHoursSpanOvernight(t *testing.T) {
+	taipei := loadLocation("Asia/Taipei")
+
+	// 01:00 belongs to the span that opened 18:00 the previous evening
+	at := time.Date(2025, 1, 7, 1, 0, 0, 0, taipei)
+	start, end, ok := businessHoursSpan("18:00", "02:00", at, taipei)
+
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2025, 1, 6, 18, 0, 0, 0, taipei), start)
+	assert.Equal(t, time.Date(2025, 1, 7, 2, 0, 0, 0, taipei), end)
+
+	// 03:00 is closed
+	_, _, ok = businessHoursSpan("18:00", "02:00", time.Date(2025, 1, 7, 3, 0, 0, 0, taipei), taipei)
+	assert.False(t, ok)
+}
+
+func TestCheckPickupWindow(t *testing.T) {
+	taipei := loadLocation("Asia/Taipei")
+	listedAt := time.Date(2025, 1, 6, 19, 0, 0, 0, taipei)
+	at := func(h, m int) time.Time { return time.Date(2025, 1, 6, h, m, 0, 0, taipei) }
+
+	// Within 09:00-21:00
+	errs := checkPickupWindow(at(20, 0), at(21, 0), at(20, 30), listedAt, "09:00", "21:00", taipei)
+	assert.Empty(t, errs)
+
+	// Ends after closing
+	codes := fieldCodes(checkPickupWindow(at(20, 0), at(22, 0), at(20, 30), listedAt, "09:00", "21:00", taipei))
+	assert.Equal(t, "outside_business_hours", codes["pickup_end"])
+
+	// Listing visible after pickup has closed
+	codes = fieldCodes(checkPickupWindow(at(20, 0), at(20, 30), at(21, 0), listedAt, "", "", taipei))
+	assert.Equal(t, "after_pickup_end", codes["expiry_minutes"])
+
+	// End before start
+	codes = fieldCodes(checkPickupWindow(at(20, 0), at(19, 30), at(19, 15), listedAt, "", "", taipei))
+	assert.Equal(t, "before_start", codes["pickup_end"])
+}
+
+func TestPurchaseBlockReason(t *testing.T) {
+	now := time.Now()
+	later := now.Add(2 * time.Hour)
+
+	assert.Equal(t, "", purchaseBlockReason("AVAILABLE", later, sql.NullTime{Time: later, Valid: true}, now))
+	assert.Equal(t, "Product already sold", purchaseBlockReason("SOLD", later, sql.NullTime{}, now))
+	assert.Equal(t, "Product expired", purchaseBlockReason("AVAILABLE", now.Add(-time.Minute), sql.NullTime{}, now))
+
+	// Pickup closes sooner than the minimum lead time
+	soon := sql.NullTime{Time: now.Add(5 * time.Minute), Valid: true}
+	assert.Equal(t, "Pickup window has ended", purchaseBlockReason("AVAILABLE", later, soon, now))
+}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"food-platform-backend/db"
 	"food-platform-backend/models"
+	"food-platform-backend/payments"
+	"log"
 	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,33 +28,69 @@ func distance(lat1, lon1, lat2, lon2 float64) float64 {
 	return R * c
 }
 
+// queryer is satisfied by both *sql.DB and *sql.Tx so listing helpers can
+// run standalone or inside a caller's transaction.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// productInput is the listing payload accepted by CreateProduct. Other
+// listing sources (schedules, imports) build the same struct so they share
+// validation and insertion.
+type productInput struct {
+	MerchantID    string  `json:"merchant_id" binding:"required"`
+	Name          string  `json:"name" binding:"required"`
+	OriginalPrice float64 `json:"original_price" binding:"required"`
+	CurrentPrice  float64 `json:"current_price" binding:"required"`
+	ExpiryMinutes int     `json:"expiry_minutes" binding:"required"`
+	Latitude      float64 `json:"latitude" binding:"required"`
+	Longitude     float64 `json:"longitude" binding:"required"`
+	// Optional pickup window; defaults to listing time until expiry
+	PickupStart *time.Time `json:"pickup_start"`
+	PickupEnd   *time.Time `json:"pickup_end"`
+	ScheduleID  *int       `json:"-"`
+}
+
+// createListing inserts an AVAILABLE listing whose expiry is counted from
+// listedAt.
+func createListing(q queryer, input productInput, listedAt time.Time) (int, error) {
+	expiryDate, pickupStart, pickupEnd := listingTimes(input, listedAt)
+
+	var productID int
+	query := `
+		INSERT INTO products (merchant_id, name, original_price, current_price, expiry_date, latitude, longitude, is_listed, status, schedule_id, pickup_start, pickup_end)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, true, 'AVAILABLE', $8, $9, $10)
+		RETURNING id
+	`
+	err := q.QueryRow(query, input.MerchantID, input.Name, input.OriginalPrice, input.CurrentPrice, expiryDate, input.Latitude, input.Longitude,
+		input.ScheduleID, pickupStart, pickupEnd).Scan(&productID)
+	return productID, err
+}
+
 // === Merchant API ===
 
 func CreateProduct(c *gin.Context) {
-	var input struct {
-		MerchantID    string  `json:"merchant_id" binding:"required"`
-		Name          string  `json:"name" binding:"required"`
-		OriginalPrice float64 `json:"original_price" binding:"required"`
-		CurrentPrice  float64 `json:"current_price" binding:"required"`
-		ExpiryMinutes int     `json:"expiry_minutes" binding:"required"`
-		Latitude      float64 `json:"latitude" binding:"required"`
-		Longitude     float64 `json:"longitude" binding:"required"`
-	}
+	var input productInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "fields": bindingFieldErrors(err, &input)})
 		return
 	}
 
-	expiryDate := time.Now().Add(time.Duration(input.ExpiryMinutes) * time.Minute)
+	v, err := newListingValidator(db.DB, input.MerchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	now := time.Now()
+	if fieldErrs := v.Validate(input, now); len(fieldErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": fieldErrs})
+		return
+	}
 
-	var productID int
-	query := `
-		INSERT INTO products (merchant_id, name, original_price, current_price, expiry_date, latitude, longitude, is_listed, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, true, 'AVAILABLE')
-		RETURNING id
-	`
-	err := db.DB.QueryRow(query, input.MerchantID, input.Name, input.OriginalPrice, input.CurrentPrice, expiryDate, input.Latitude, input.Longitude).Scan(&productID)
+	productID, err := createListing(db.DB, input, now)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
@@ -67,9 +107,11 @@ func GetProducts(c *gin.Context) {
 
 	// Filter: Status=AVAILABLE and Expiry > Now
 	rows, err := db.DB.Query(`
-		SELECT id, merchant_id, name, original_price, current_price, expiry_date, latitude, longitude, is_listed, status 
-		FROM products 
-		WHERE status = 'AVAILABLE' AND expiry_date > NOW()
+		SELECT p.id, p.merchant_id, p.name, p.original_price, p.current_price, p.expiry_date, p.latitude, p.longitude, p.is_listed, p.status,
+		       COALESCE(p.pickup_start, p.created_at), COALESCE(p.pickup_end, p.expiry_date), COALESCE(m.timezone, '')
+		FROM products p
+		LEFT JOIN merchants m ON m.user_id = p.merchant_id
+		WHERE p.status = 'AVAILABLE' AND p.expiry_date > NOW()
 	`)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -80,24 +122,49 @@ func GetProducts(c *gin.Context) {
 	var products []models.Product
 	for rows.Next() {
 		var p models.Product
-		if err := rows.Scan(&p.ID, &p.MerchantID, &p.Name, &p.OriginalPrice, &p.CurrentPrice, &p.ExpiryDate, &p.Latitude, &p.Longitude, &p.IsListed, &p.Status); err != nil {
+		var tz string
+		if err := rows.Scan(&p.ID, &p.MerchantID, &p.Name, &p.OriginalPrice, &p.CurrentPrice, &p.ExpiryDate, &p.Latitude, &p.Longitude, &p.IsListed, &p.Status,
+			&p.PickupStart, &p.PickupEnd, &tz); err != nil {
 			continue
 		}
+		// Present times in the shop's local time
+		loc := loadLocation(tz)
+		p.Timezone = loc.String()
+		p.ExpiryDate = p.ExpiryDate.In(loc)
+		p.PickupStart = p.PickupStart.In(loc)
+		p.PickupEnd = p.PickupEnd.In(loc)
 		products = append(products, p)
 	}
 
 	c.JSON(http.StatusOK, products)
 }
 
+// PurchaseProduct - POST /purchase/:id
+// Buys a listing for the caller. The body is optional.
 func PurchaseProduct(c *gin.Context) {
-	productID := c.Param("id")
 	var input struct {
-		ConsumerID string `json:"consumer_id" binding:"required"`
+		SlotID        *int   `json:"slot_id"`
+		PaymentMethod string `json:"payment_method"` // empty: pay at pickup
 	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Consumer ID required"})
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	consumerID := c.GetString("user_id")
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
 		return
 	}
+	var provider payments.PaymentProvider
+	if input.PaymentMethod != "" {
+		if provider, err = payments.Get(input.PaymentMethod); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown payment method"})
+			return
+		}
+	}
 
 	// 1. Start Transaction
 	tx, err := db.DB.Begin()
@@ -110,9 +177,10 @@ func PurchaseProduct(c *gin.Context) {
 	// 2. Lock Row (Pessimistic Locking)
 	var status string
 	var expiry time.Time
+	var pickupEnd sql.NullTime
 
 	// FOR UPDATE ensures no one else can read/write this row until we commit/rollback
-	err = tx.QueryRow("SELECT status, expiry_date FROM products WHERE id = $1 FOR UPDATE", productID).Scan(&status, &expiry)
+	err = tx.QueryRow("SELECT status, expiry_date, pickup_end FROM products WHERE id = $1 FOR UPDATE", productID).Scan(&status, &expiry, &pickupEnd)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
@@ -123,12 +191,12 @@ func PurchaseProduct(c *gin.Context) {
 	}
 
 	// 3. Validation
-	if status == "SOLD" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product already sold"})
+	if reason := purchaseBlockReason(status, expiry, pickupEnd, time.Now()); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reason})
 		return
 	}
-	if time.Now().After(expiry) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product expired"})
+	if err := checkConsumerMayBuy(tx, consumerID, []int{productID}, provider != nil, time.Now()); err != nil {
+		respondPurchaseBlocked(c, err)
 		return
 	}
 
@@ -140,20 +208,87 @@ func PurchaseProduct(c *gin.Context) {
 	}
 
 	// 5. Create Order Record
-	_, err = tx.Exec("INSERT INTO orders (product_id, consumer_id) VALUES ($1, $2)", productID, input.ConsumerID)
+	orderID, err := createOrder(tx, productID, consumerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
 
-	// 6. Commit Transaction
+	// 6. Book the chosen pickup slot, if any
+	if input.SlotID != nil {
+		if err := bookPickupSlot(tx, orderID, *input.SlotID, time.Now()); err != nil {
+			respondSlotError(c, err)
+			return
+		}
+	}
+
+	// 7. Hold the order until it is paid, if paying online
+	if provider != nil {
+		_, err = tx.Exec("UPDATE orders SET payment_method = $1, payment_status = 'pending' WHERE id = $2", provider.Name(), orderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			return
+		}
+	}
+
+	// 8. Commit Transaction
 	err = tx.Commit()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Purchase successful! Enjoy your food."})
+	if provider == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Purchase successful! Enjoy your food.", "order_id": orderID})
+		return
+	}
+
+	// 9. Open the payment; the order is confirmed once the provider reports it paid
+	intent, err := startOrderPayment(orderID, provider)
+	if errors.Is(err, payments.ErrPayerRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Add a wallet address to your account to pay with " + provider.Name() + ", or choose another payment method with POST /orders/:id/pay", "order_id": orderID})
+		return
+	}
+	if err != nil {
+		log.Printf("Payment for order %d via %s: %v", orderID, provider.Name(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not start payment, retry with POST /orders/:id/pay", "order_id": orderID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Complete payment to confirm your order.", "order_id": orderID, "payment": intent})
+}
+
+// createOrder records a consumer's order for a product that the caller has
+// already locked and marked SOLD in tx, snapshotting its name and price and
+// issuing its pickup code. A code taken by a concurrent order is retried
+// from a savepoint, so the caller's transaction survives the conflict.
+func createOrder(tx *sql.Tx, productID int, consumerID string) (int, error) {
+	var orderID int
+	for attempt := 0; attempt < 3; attempt++ {
+		code, err := newPickupCode(tx, productID)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec("SAVEPOINT create_order"); err != nil {
+			return 0, err
+		}
+		err = tx.QueryRow(`
+			INSERT INTO orders (product_id, consumer_id, merchant_id, product_name, price_paid, pickup_code)
+			SELECT id, $2, merchant_id, name, current_price, $3 FROM products WHERE id = $1
+			RETURNING id
+		`, productID, consumerID, code).Scan(&orderID)
+		if isUniqueViolation(err) {
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT create_order"); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec("RELEASE SAVEPOINT create_order")
+		return orderID, err
+	}
+	return 0, errors.New("could not allocate a pickup code")
 }
 
 // Legacy demo seed
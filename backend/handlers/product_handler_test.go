@@ -48,16 +48,20 @@ func TestCreateProductMissingFields(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
-func TestPurchaseProductNoBody(t *testing.T) {
+func TestPurchaseProductRequiresToken(t *testing.T) {
 	router := gin.New()
-	router.POST("/purchase/:id", PurchaseProduct)
+	router.POST("/purchase/:id", AuthRequired(), PurchaseProduct)
 
-	req, _ := http.NewRequest("POST", "/purchase/999", bytes.NewBuffer([]byte("{}")))
+	req, _ := http.NewRequest("POST", "/purchase/999", bytes.NewBuffer([]byte(`{"consumer_id": "someone-else"}`)))
 	req.Header.Set("Content-Type", "application/json")
-
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
 
+	req, _ = http.NewRequest("POST", "/purchase/abc", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "consumer-1"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var errPurchaseLimit = errors.New("purchase limit reached")
+
+// Platform purchase limits, overridable with PURCHASE_LIMIT_DAILY,
+// PURCHASE_LIMIT_SHOP_DAILY and PURCHASE_LIMIT_PER_LISTING. Merchants can
+// only tighten the per-shop and per-listing limits.
+const (
+	defaultDailyPurchaseLimit     = 10
+	defaultShopDailyPurchaseLimit = 4
+	defaultListingPurchaseLimit   = 2
+)
+
+func envLimit(name string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v >= 0 {
+		return v
+	}
+	return fallback
+}
+
+// platformPurchaseLimits returns the limits every merchant starts from.
+func platformPurchaseLimits() models.PurchaseLimits {
+	return models.PurchaseLimits{
+		DailyTotal:   envLimit("PURCHASE_LIMIT_DAILY", defaultDailyPurchaseLimit),
+		PerShopDaily: envLimit("PURCHASE_LIMIT_SHOP_DAILY", defaultShopDailyPurchaseLimit),
+		PerListing:   envLimit("PURCHASE_LIMIT_PER_LISTING", defaultListingPurchaseLimit),
+	}
+}
+
+// stricterLimit returns the tighter of two limits, where 0 means none.
+func stricterLimit(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// loadPurchaseLimits returns the limits in force at a merchant's shop.
+func loadPurchaseLimits(q queryer, merchantID string) (models.PurchaseLimits, error) {
+	limits := platformPurchaseLimits()
+	limits.MerchantID = merchantID
+	var perShopDaily, perListing int
+	err := q.QueryRow(`SELECT per_shop_daily, per_listing FROM purchase_limits WHERE merchant_id = $1`, merchantID).
+		Scan(&perShopDaily, &perListing)
+	if err == sql.ErrNoRows {
+		return limits, nil
+	}
+	if err != nil {
+		return limits, err
+	}
+	limits.PerShopDaily = stricterLimit(limits.PerShopDaily, perShopDaily)
+	limits.PerListing = stricterLimit(limits.PerListing, perListing)
+	return limits, nil
+}
+
+// purchaseLine is one product a consumer holds or is about to buy.
+type purchaseLine struct {
+	productID  int
+	merchantID string
+	listing    string
+	at         time.Time
+}
+
+// listingKey groups the units of one listing. Scheduled units published in
+// the same run share their expiry; manual products stand alone.
+func listingKey(productID int, scheduleID sql.NullInt64, expiry time.Time) string {
+	if scheduleID.Valid {
+		return fmt.Sprintf("schedule:%d:%d", scheduleID.Int64, expiry.Unix())
+	}
+	return fmt.Sprintf("product:%d", productID)
+}
+
+// purchaseLimitError checks the lines a consumer is about to buy against
+// what they already hold. Daily limits count lines since dayStart.
+func purchaseLimitError(held, lines []purchaseLine, shops map[string]models.PurchaseLimits, daily int, dayStart time.Time) error {
+	today := 0
+	shopToday := map[string]int{}
+	perListing := map[string]int{}
+	for _, l := range append(append([]purchaseLine(nil), held...), lines...) {
+		if !l.at.Before(dayStart) {
+			today++
+			shopToday[l.merchantID]++
+		}
+		perListing[l.listing]++
+	}
+
+	if daily > 0 && today > daily {
+		return fmt.Errorf("%w: %d items per day", errPurchaseLimit, daily)
+	}
+	for _, l := range lines {
+		limits := shops[l.merchantID]
+		if limits.PerShopDaily > 0 && shopToday[l.merchantID] > limits.PerShopDaily {
+			return fmt.Errorf("%w: %d items per day from this shop", errPurchaseLimit, limits.PerShopDaily)
+		}
+		if limits.PerListing > 0 && perListing[l.listing] > limits.PerListing {
+			return fmt.Errorf("%w: %d per listing", errPurchaseLimit, limits.PerListing)
+		}
+	}
+	return nil
+}
+
+// enforcePurchaseLimits checks that the consumer may buy productIDs, which
+// the caller has locked in tx. Live orders and active reservations count;
+// reservations of productIDs themselves are being confirmed and do not.
+func enforcePurchaseLimits(tx *sql.Tx, consumerID string, productIDs []int, now time.Time) error {
+	scan := func(rows *sql.Rows) ([]purchaseLine, error) {
+		defer rows.Close()
+		var lines []purchaseLine
+		for rows.Next() {
+			var l purchaseLine
+			var scheduleID sql.NullInt64
+			var expiry time.Time
+			if err := rows.Scan(&l.productID, &l.merchantID, &scheduleID, &expiry, &l.at); err != nil {
+				return nil, err
+			}
+			l.listing = listingKey(l.productID, scheduleID, expiry)
+			lines = append(lines, l)
+		}
+		return lines, rows.Err()
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(merchant_id, ''), schedule_id, expiry_date, $2::timestamp
+		FROM products WHERE id = ANY($1)
+	`, pq.Array(productIDs), now.UTC())
+	if err != nil {
+		return err
+	}
+	lines, err := scan(rows)
+	if err != nil {
+		return err
+	}
+
+	dayStart := localDayStart(now, loadLocation(""))
+	rows, err = tx.Query(`
+		SELECT p.id, COALESCE(p.merchant_id, ''), p.schedule_id, p.expiry_date, h.created_at
+		FROM (
+			SELECT product_id, created_at FROM orders
+			WHERE consumer_id = $1
+			  AND COALESCE(status, 'pending') NOT IN ('cancelled_by_consumer', 'cancelled_by_merchant', 'payment_failed')
+			UNION ALL
+			SELECT product_id, created_at FROM reservations
+			WHERE consumer_id = $1 AND status = 'active' AND product_id <> ALL($2)
+		) h
+		JOIN products p ON p.id = h.product_id
+		WHERE h.created_at >= $3 OR p.expiry_date >= $4
+	`, consumerID, pq.Array(productIDs), dayStart.UTC(), now.UTC())
+	if err != nil {
+		return err
+	}
+	held, err := scan(rows)
+	if err != nil {
+		return err
+	}
+
+	shops := map[string]models.PurchaseLimits{}
+	for _, l := range lines {
+		if _, ok := shops[l.merchantID]; ok {
+			continue
+		}
+		if shops[l.merchantID], err = loadPurchaseLimits(tx, l.merchantID); err != nil {
+			return err
+		}
+	}
+	return purchaseLimitError(held, lines, shops, platformPurchaseLimits().DailyTotal, dayStart)
+}
+
+// localDayStart returns midnight of now's day in loc.
+func localDayStart(now time.Time, loc *time.Location) time.Time {
+	y, m, d := now.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// checkConsumerMayBuy locks the consumer for the rest of tx, so concurrent
+// purchases are checked one after another, then applies their reliability
+// restrictions and purchase limits to productIDs.
+func checkConsumerMayBuy(tx *sql.Tx, consumerID string, productIDs []int, prepaid bool, now time.Time) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('consumer:' || $1))`, consumerID); err != nil {
+		return err
+	}
+	if err := enforceReliability(tx, consumerID, len(productIDs), prepaid, now); err != nil {
+		return err
+	}
+	return enforcePurchaseLimits(tx, consumerID, productIDs, now)
+}
+
+// respondPurchaseBlocked writes the response for a checkConsumerMayBuy error.
+func respondPurchaseBlocked(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errPurchaseLimit), errors.Is(err, errConsumerSuspended), errors.Is(err, errPrepaymentRequired),
+		errors.Is(err, errOpenOrderLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	}
+}
+
+// =========================================================================
+// MERCHANT PURCHASE LIMITS
+// =========================================================================
+
+// GetPurchaseLimits - GET /merchant/:merchant_id/purchase-limits
+func GetPurchaseLimits(c *gin.Context) {
+	limits, err := loadPurchaseLimits(db.DB, c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load purchase limits"})
+		return
+	}
+	c.JSON(http.StatusOK, limits)
+}
+
+// UpdatePurchaseLimits - PUT /merchant/purchase-limits
+// Zero falls back to the platform limit; limits looser than the platform's
+// are rejected.
+func UpdatePurchaseLimits(c *gin.Context) {
+	var input struct {
+		PerShopDaily int `json:"per_shop_daily" binding:"min=0"`
+		PerListing   int `json:"per_listing" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": bindingFieldErrors(err, &input)})
+		return
+	}
+	platform := platformPurchaseLimits()
+	if platform.PerShopDaily > 0 && input.PerShopDaily > platform.PerShopDaily {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("per_shop_daily cannot exceed the platform limit of %d", platform.PerShopDaily)})
+		return
+	}
+	if platform.PerListing > 0 && input.PerListing > platform.PerListing {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("per_listing cannot exceed the platform limit of %d", platform.PerListing)})
+		return
+	}
+	if !requireMerchant(c) {
+		return
+	}
+
+	merchantID := c.GetString("user_id")
+	_, err := db.DB.Exec(`
+		INSERT INTO purchase_limits (merchant_id, per_shop_daily, per_listing)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (merchant_id) DO UPDATE
+		SET per_shop_daily=$2, per_listing=$3, updated_at=CURRENT_TIMESTAMP
+	`, merchantID, input.PerShopDaily, input.PerListing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase limits"})
+		return
+	}
+
+	limits, err := loadPurchaseLimits(db.DB, merchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load purchase limits"})
+		return
+	}
+	c.JSON(http.StatusOK, limits)
+}
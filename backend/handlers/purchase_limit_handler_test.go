@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// PURCHASE LIMIT TESTS
+// =========================================================================
+
+func TestStricterLimit(t *testing.T) {
+	assert.Equal(t, 2, stricterLimit(4, 2))
+	assert.Equal(t, 4, stricterLimit(4, 6))
+	assert.Equal(t, 4, stricterLimit(4, 0), "0 keeps the platform limit")
+	assert.Equal(t, 3, stricterLimit(0, 3))
+}
+
+func TestPlatformPurchaseLimits(t *testing.T) {
+	t.Setenv("PURCHASE_LIMIT_DAILY", "")
+	t.Setenv("PURCHASE_LIMIT_SHOP_DAILY", "6")
+	t.Setenv("PURCHASE_LIMIT_PER_LISTING", "0")
+	assert.Equal(t, models.PurchaseLimits{DailyTotal: 10, PerShopDaily: 6, PerListing: 0}, platformPurchaseLimits())
+}
+
+func TestListingKey(t *testing.T) {
+	expiry := time.Date(2026, 3, 2, 21, 0, 0, 0, time.UTC)
+	schedule := sql.NullInt64{Int64: 4, Valid: true}
+	assert.Equal(t, listingKey(10, schedule, expiry), listingKey(11, schedule, expiry), "units of one run share a listing")
+	assert.NotEqual(t, listingKey(10, schedule, expiry), listingKey(11, schedule, expiry.AddDate(0, 0, 1)))
+	assert.NotEqual(t, listingKey(10, sql.NullInt64{}, expiry), listingKey(11, sql.NullInt64{}, expiry))
+}
+
+func TestPurchaseLimitError(t *testing.T) {
+	dayStart := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	now := dayStart.Add(12 * time.Hour)
+	yesterday := dayStart.Add(-time.Hour)
+	shops := map[string]models.PurchaseLimits{
+		"m1": {PerShopDaily: 2, PerListing: 2},
+		"m2": {PerShopDaily: 4, PerListing: 1},
+	}
+	line := func(merchantID, listing string, at time.Time) purchaseLine {
+		return purchaseLine{merchantID: merchantID, listing: listing, at: at}
+	}
+
+	// Per shop per day, yesterday's orders do not count
+	held := []purchaseLine{line("m1", "a", yesterday), line("m1", "b", now)}
+	assert.NoError(t, purchaseLimitError(held, []purchaseLine{line("m1", "c", now)}, shops, 10, dayStart))
+	held = append(held, line("m1", "c", now))
+	assert.ErrorIs(t, purchaseLimitError(held, []purchaseLine{line("m1", "d", now)}, shops, 10, dayStart), errPurchaseLimit)
+	assert.NoError(t, purchaseLimitError(held, []purchaseLine{line("m2", "x", now)}, shops, 10, dayStart))
+
+	// Per listing, across days
+	held = []purchaseLine{line("m2", "x", yesterday)}
+	err := purchaseLimitError(held, []purchaseLine{line("m2", "x", now)}, shops, 10, dayStart)
+	assert.ErrorIs(t, err, errPurchaseLimit)
+	assert.Contains(t, err.Error(), "1 per listing")
+
+	// Across all shops
+	held = []purchaseLine{line("m1", "a", now), line("m2", "x", now)}
+	assert.ErrorIs(t, purchaseLimitError(held, []purchaseLine{line("m2", "y", now)}, shops, 2, dayStart), errPurchaseLimit)
+	assert.NoError(t, purchaseLimitError(held, []purchaseLine{line("m2", "y", now)}, shops, 0, dayStart))
+
+	// A checkout's own lines count together
+	lines := []purchaseLine{line("m1", "a", now), line("m1", "b", now), line("m1", "c", now)}
+	assert.ErrorIs(t, purchaseLimitError(nil, lines, shops, 10, dayStart), errPurchaseLimit)
+}
+
+func TestLocalDayStart(t *testing.T) {
+	taipei := loadLocation("Asia/Taipei")
+	// 17:00 UTC is already the next day in Taipei
+	now := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 3, 0, 0, 0, 0, taipei), localDayStart(now, taipei))
+}
+
+func TestUpdatePurchaseLimitsValidation(t *testing.T) {
+	t.Setenv("PURCHASE_LIMIT_SHOP_DAILY", "4")
+	t.Setenv("PURCHASE_LIMIT_PER_LISTING", "2")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/merchant/purchase-limits", UpdatePurchaseLimits)
+
+	for _, body := range []string{
+		`{"per_shop_daily":-1}`,
+		`{"per_shop_daily":5}`,
+		`{"per_listing":3}`,
+		`{"per_listing":"two"}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/merchant/purchase-limits", bytes.NewBufferString(body))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"database/sql"
+	"food-platform-backend/models"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Ratings are kept as running per-merchant totals plus daily buckets for the
+// recent average, updated in the same transaction as the review change, so
+// listings and search never aggregate the reviews table.
+
+const recentRatingDays = 90
+
+// Bayesian ranking pulls every merchant's average towards the platform mean
+// as if it had defaultRatingPriorWeight extra reviews at that mean
+// (RATING_PRIOR_WEIGHT), so one 5★ review does not outrank hundreds at 4.8★.
+const (
+	defaultRatingPriorWeight = 10
+	defaultRatingPriorMean   = 3.5 // Until the platform has reviews
+)
+
+func ratingPriorWeight() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("RATING_PRIOR_WEIGHT"), 64); err == nil && v >= 0 {
+		return v
+	}
+	return defaultRatingPriorWeight
+}
+
+// reviewCounts reports whether reviews in status count towards ratings.
+func reviewCounts(status string) bool {
+	return status == models.ReviewStatusPublished
+}
+
+// bayesianScore is the average of count reviews summing to sum, weighted
+// towards priorMean by priorWeight phantom reviews.
+func bayesianScore(sum, count int, priorMean, priorWeight float64) float64 {
+	if count == 0 && priorWeight == 0 {
+		return 0
+	}
+	return (priorWeight*priorMean + float64(sum)) / (priorWeight + float64(count))
+}
+
+func roundRating(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// updateRatingAggregates adds (delta 1) or removes (delta -1) one review from
+// its merchant's totals and its day's bucket.
+func updateRatingAggregates(tx *sql.Tx, merchantID string, rating int, createdAt time.Time, delta int) error {
+	stars := make([]int64, 5)
+	stars[rating-1] = int64(delta)
+	_, err := tx.Exec(`
+		INSERT INTO merchant_rating_stats (merchant_id, review_count, rating_sum, stars)
+		VALUES ($1, $2, $2 * $3, $4)
+		ON CONFLICT (merchant_id) DO UPDATE SET
+			review_count = merchant_rating_stats.review_count + $2,
+			rating_sum = merchant_rating_stats.rating_sum + $2 * $3,
+			stars[$3] = merchant_rating_stats.stars[$3] + $2,
+			updated_at = CURRENT_TIMESTAMP
+	`, merchantID, delta, rating, pq.Array(stars))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO merchant_rating_days (merchant_id, day, review_count, rating_sum)
+		VALUES ($1, $2, $3, $3 * $4)
+		ON CONFLICT (merchant_id, day) DO UPDATE SET
+			review_count = merchant_rating_days.review_count + $3,
+			rating_sum = merchant_rating_days.rating_sum + $3 * $4
+	`, merchantID, createdAt.UTC().Format("2006-01-02"), delta, rating)
+	return err
+}
+
+// reviewStatusChanged keeps the aggregates in step when a review locked in
+// tx moves between statuses.
+func reviewStatusChanged(tx *sql.Tx, reviewID int, from, to string) error {
+	if reviewCounts(from) == reviewCounts(to) {
+		return nil
+	}
+	var merchantID string
+	var rating int
+	var createdAt time.Time
+	if err := tx.QueryRow(`SELECT merchant_id, rating, created_at FROM reviews WHERE id = $1`, reviewID).Scan(&merchantID, &rating, &createdAt); err != nil {
+		return err
+	}
+	delta := 1
+	if reviewCounts(from) {
+		delta = -1
+	}
+	return updateRatingAggregates(tx, merchantID, rating, createdAt, delta)
+}
+
+// ratingPriorMean is the average of every counted review on the platform.
+func ratingPriorMean(q queryer) float64 {
+	var sum, count int
+	if err := q.QueryRow(`SELECT COALESCE(SUM(rating_sum), 0), COALESCE(SUM(review_count), 0) FROM merchant_rating_stats`).
+		Scan(&sum, &count); err != nil || count == 0 {
+		return defaultRatingPriorMean
+	}
+	return float64(sum) / float64(count)
+}
+
+// loadRatingSummary reads a merchant's aggregates.
+func loadRatingSummary(q queryer, merchantID string, now time.Time) (models.RatingSummary, error) {
+	s := models.RatingSummary{Histogram: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	var sum int
+	var stars []int64
+	err := q.QueryRow(`SELECT review_count, rating_sum, stars FROM merchant_rating_stats WHERE merchant_id = $1`, merchantID).
+		Scan(&s.Count, &sum, pq.Array(&stars))
+	if err != nil && err != sql.ErrNoRows {
+		return s, err
+	}
+	for i, n := range stars {
+		s.Histogram[i+1] = int(n)
+	}
+	if s.Count > 0 {
+		s.Average = roundRating(float64(sum) / float64(s.Count))
+	}
+
+	var recentSum int
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(review_count), 0), COALESCE(SUM(rating_sum), 0)
+		FROM merchant_rating_days WHERE merchant_id = $1 AND day >= $2
+	`, merchantID, now.UTC().AddDate(0, 0, -recentRatingDays).Format("2006-01-02")).Scan(&s.RecentCount, &recentSum)
+	if err != nil {
+		return s, err
+	}
+	if s.RecentCount > 0 {
+		s.RecentAverage = roundRating(float64(recentSum) / float64(s.RecentCount))
+	}
+
+	s.Score = roundRating(bayesianScore(sum, s.Count, ratingPriorMean(q), ratingPriorWeight()))
+	return s, nil
+}
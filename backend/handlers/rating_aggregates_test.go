@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"food-platform-backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// RATING AGGREGATE TESTS
+// =========================================================================
+
+func TestBayesianScoreRanksVolume(t *testing.T) {
+	oneFiveStar := bayesianScore(5, 1, 4.0, defaultRatingPriorWeight)
+	manyGood := bayesianScore(1920, 400, 4.0, defaultRatingPriorWeight) // 400 reviews at 4.8
+	assert.Greater(t, manyGood, oneFiveStar)
+	assert.InDelta(t, 4.09, oneFiveStar, 0.01)
+	assert.InDelta(t, 4.78, manyGood, 0.01)
+
+	assert.Equal(t, 4.0, bayesianScore(0, 0, 4.0, 10), "no reviews score the prior mean")
+	assert.Equal(t, 4.8, bayesianScore(24, 5, 4.0, 0), "without a prior the score is the average")
+	assert.Equal(t, 0.0, bayesianScore(0, 0, 4.0, 0))
+}
+
+func TestRatingPriorWeight(t *testing.T) {
+	t.Setenv("RATING_PRIOR_WEIGHT", "")
+	assert.Equal(t, float64(defaultRatingPriorWeight), ratingPriorWeight())
+	t.Setenv("RATING_PRIOR_WEIGHT", "25")
+	assert.Equal(t, 25.0, ratingPriorWeight())
+	t.Setenv("RATING_PRIOR_WEIGHT", "-1")
+	assert.Equal(t, float64(defaultRatingPriorWeight), ratingPriorWeight())
+}
+
+func TestReviewCounts(t *testing.T) {
+	assert.True(t, reviewCounts(models.ReviewStatusPublished))
+	for _, s := range []string{models.ReviewStatusPending, models.ReviewStatusHidden, models.ReviewStatusDeleted} {
+		assert.False(t, reviewCounts(s), s)
+	}
+	assert.Equal(t, 4.67, roundRating(14.0/3))
+}
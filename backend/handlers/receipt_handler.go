@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/einvoice"
+	"food-platform-backend/models"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// receiptTaxRate is Taiwan's business tax, included in listed prices.
+const receiptTaxRate = 0.05
+
+// maxInvoiceAttempts is how often a failing e-invoice is retried before it
+// is left for the merchant to issue by hand.
+const maxInvoiceAttempts = 5
+
+// includedTax is the tax contained in a tax-inclusive amount, to the cent.
+func includedTax(total, rate float64) float64 {
+	return math.Round((total-total/(1+rate))*100) / 100
+}
+
+// invoiceAmounts splits a tax-inclusive total into the whole-dollar sales
+// and tax amounts e-invoices carry.
+func invoiceAmounts(total, rate float64) (sales, tax, rounded int64) {
+	rounded = int64(math.Round(total))
+	sales = int64(math.Round(float64(rounded) / (1 + rate)))
+	return sales, rounded - sales, rounded
+}
+
+// receiptNumber is the human-facing number printed on a receipt.
+func receiptNumber(orderID int, at time.Time) string {
+	return fmt.Sprintf("R%s-%06d", at.Format("20060102"), orderID)
+}
+
+// createReceipt issues the receipt for a picked-up order, snapshotting the
+// shop, price and the consumer's invoice carrier. E-invoices are queued
+// for merchants with a tax ID. Receipts are issued once per order.
+func createReceipt(q queryer, orderID int, now time.Time) error {
+	var consumerID, merchantID, itemName, paymentMethod, shopName, address, taxID, carrier string
+	var original, total float64
+	err := q.QueryRow(`
+		SELECT o.consumer_id, COALESCE(o.merchant_id, p.merchant_id, ''), COALESCE(o.product_name, p.name),
+		       COALESCE(p.original_price, 0), COALESCE(o.price_paid, p.current_price), COALESCE(o.payment_method, ''),
+		       COALESCE(m.shop_name, ''), COALESCE(m.address, ''), COALESCE(m.tax_id, ''), COALESCE(u.invoice_carrier, '')
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		LEFT JOIN merchants m ON m.user_id = COALESCE(o.merchant_id, p.merchant_id)
+		LEFT JOIN users u ON u.id = o.consumer_id
+		WHERE o.id = $1
+	`, orderID).Scan(&consumerID, &merchantID, &itemName, &original, &total, &paymentMethod, &shopName, &address, &taxID, &carrier)
+	if err != nil {
+		return err
+	}
+
+	invoiceStatus := models.InvoiceStatusNotRequired
+	if taxID != "" {
+		invoiceStatus = models.InvoiceStatusPending
+	}
+	_, err = q.Exec(`
+		INSERT INTO receipts (order_id, receipt_number, consumer_id, merchant_id, shop_name, merchant_address, merchant_tax_id,
+		                      item_name, original_price, total, tax_rate, tax_amount, currency, payment_method,
+		                      invoice_status, invoice_carrier, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15, NULLIF($16, ''), $17)
+		ON CONFLICT (order_id) DO NOTHING
+	`, orderID, receiptNumber(orderID, now), consumerID, merchantID, shopName, address, taxID,
+		itemName, math.Max(original, total), total, receiptTaxRate, includedTax(total, receiptTaxRate), paymentCurrency(), paymentMethod,
+		invoiceStatus, carrier, now.UTC())
+	return err
+}
+
+// loadReceipt reads the receipt of an order.
+func loadReceipt(orderID int) (models.Receipt, error) {
+	var r models.Receipt
+	var original float64
+	var taxID, paymentMethod, carrier, number, randomCode, invoiceErr sql.NullString
+	var invoiceIssuedAt sql.NullTime
+	var itemName string
+	err := db.DB.QueryRow(`
+		SELECT id, receipt_number, order_id, consumer_id, merchant_id, COALESCE(shop_name, ''), COALESCE(merchant_address, ''),
+		       merchant_tax_id, item_name, original_price, total, tax_rate, tax_amount, currency, payment_method,
+		       invoice_status, invoice_carrier, invoice_number, invoice_random_code, invoice_issued_at, invoice_error, created_at
+		FROM receipts WHERE order_id = $1
+	`, orderID).Scan(&r.ID, &r.ReceiptNumber, &r.OrderID, &r.ConsumerID, &r.MerchantID, &r.ShopName, &r.MerchantAddress,
+		&taxID, &itemName, &original, &r.Total, &r.TaxRate, &r.TaxAmount, &r.Currency, &paymentMethod,
+		&r.Invoice.Status, &carrier, &number, &randomCode, &invoiceIssuedAt, &invoiceErr, &r.IssuedAt)
+	if err != nil {
+		return r, err
+	}
+
+	r.MerchantTaxID = taxID.String
+	r.PaymentMethod = paymentMethod.String
+	r.Subtotal = original
+	r.Discount = math.Round((original-r.Total)*100) / 100
+	r.Items = []models.ReceiptItem{{
+		Name:      itemName,
+		Quantity:  1,
+		UnitPrice: original,
+		Discount:  r.Discount,
+		Amount:    r.Total,
+	}}
+	r.Invoice.Carrier = carrier.String
+	r.Invoice.Number = number.String
+	r.Invoice.RandomCode = randomCode.String
+	r.Invoice.Error = invoiceErr.String
+	if invoiceIssuedAt.Valid {
+		r.Invoice.IssuedAt = &invoiceIssuedAt.Time
+	}
+	return r, nil
+}
+
+// =========================================================================
+// RECEIPTS
+// =========================================================================
+
+// GetMyReceipt - GET /me/orders/:id/receipt?format=pdf
+func GetMyReceipt(c *gin.Context) {
+	respondReceipt(c, func(consumerID, merchantID string) bool {
+		return consumerID == c.GetString("user_id")
+	})
+}
+
+// GetMerchantReceipt - GET /merchant/orders/:id/receipt?format=pdf
+func GetMerchantReceipt(c *gin.Context) {
+	respondReceipt(c, func(consumerID, merchantID string) bool {
+		return merchantID == c.GetString("user_id")
+	})
+}
+
+// respondReceipt serves the receipt of the order in the :id param as JSON,
+// or as a PDF download with format=pdf.
+func respondReceipt(c *gin.Context, owns func(consumerID, merchantID string) bool) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or pdf"})
+		return
+	}
+
+	var consumerID, merchantID string
+	var status models.OrderStatus
+	err = db.DB.QueryRow(`
+		SELECT o.consumer_id, COALESCE(o.merchant_id, p.merchant_id, ''), COALESCE(o.status, 'pending')
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = $1
+	`, orderID).Scan(&consumerID, &merchantID, &status)
+	if err == sql.ErrNoRows || (err == nil && !owns(consumerID, merchantID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if status != models.OrderStatusPickedUp {
+		c.JSON(http.StatusConflict, gin.H{"error": "Receipts are issued once the order is picked up"})
+		return
+	}
+
+	receipt, err := loadReceipt(orderID)
+	// Orders completed before receipts existed get one on first request
+	if err == sql.ErrNoRows {
+		if err = createReceipt(db.DB, orderID, time.Now()); err == nil {
+			receipt, err = loadReceipt(orderID)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load receipt"})
+		return
+	}
+
+	if format == "pdf" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="receipt-%s.pdf"`, receipt.ReceiptNumber))
+		c.Data(http.StatusOK, "application/pdf", renderReceiptPDF(receipt))
+		return
+	}
+	c.JSON(http.StatusOK, receipt)
+}
+
+// =========================================================================
+// E-INVOICE SETTINGS
+// =========================================================================
+
+// GetInvoiceCarrier - GET /me/invoice-carrier
+func GetInvoiceCarrier(c *gin.Context) {
+	var carrier sql.NullString
+	err := db.DB.QueryRow("SELECT invoice_carrier FROM users WHERE id = $1", c.GetString("user_id")).Scan(&carrier)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"carrier": carrier.String})
+}
+
+// UpdateInvoiceCarrier - PUT /me/invoice-carrier
+// Saves the mobile barcode (手機條碼) future e-invoices go to; an empty
+// carrier removes it.
+func UpdateInvoiceCarrier(c *gin.Context) {
+	var input struct {
+		Carrier string `json:"carrier"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	carrier := strings.ToUpper(strings.TrimSpace(input.Carrier))
+	if carrier != "" && !einvoice.ValidMobileBarcode(carrier) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mobile barcode must be / followed by 7 characters (0-9, A-Z, . + -)"})
+		return
+	}
+
+	res, err := db.DB.Exec("UPDATE users SET invoice_carrier = NULLIF($1, '') WHERE id = $2", carrier, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update carrier"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"carrier": carrier})
+}
+
+// UpdateInvoiceSettings - PUT /merchant/invoice-settings
+// Setting a tax ID turns on e-invoices for the merchant's receipts.
+func UpdateInvoiceSettings(c *gin.Context) {
+	var input struct {
+		TaxID string `json:"tax_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	taxID := strings.TrimSpace(input.TaxID)
+	if taxID != "" && !einvoice.ValidBAN(taxID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid uniform business number"})
+		return
+	}
+
+	res, err := db.DB.Exec("UPDATE merchants SET tax_id = NULLIF($1, '') WHERE user_id = $2", taxID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invoice settings"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tax_id": taxID, "einvoice_enabled": taxID != ""})
+}
+
+// =========================================================================
+// E-INVOICE SUBMISSION
+// =========================================================================
+
+// StartInvoiceSubmitter periodically submits queued e-invoices. Without an
+// issuer, receipts are still issued but no e-invoices are.
+func StartInvoiceSubmitter(issuer einvoice.Issuer, interval time.Duration) {
+	if issuer == nil {
+		log.Println("E-invoices: no platform configured, submission disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := submitPendingInvoices(issuer); err != nil {
+				log.Println("E-invoices:", err)
+			} else if n > 0 {
+				log.Printf("E-invoices: issued %d invoices", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// submitPendingInvoices issues queued and failed e-invoices that have
+// attempts left, returning how many were issued.
+func submitPendingInvoices(issuer einvoice.Issuer) (int, error) {
+	ids, err := queryIDs(`
+		SELECT order_id FROM receipts
+		WHERE invoice_status IN ('pending', 'failed') AND invoice_attempts < $1
+		ORDER BY id
+	`, maxInvoiceAttempts)
+	if err != nil {
+		return 0, err
+	}
+	issued := 0
+	for _, orderID := range ids {
+		if err := issueInvoice(issuer, orderID); err != nil {
+			log.Printf("E-invoices: order %d: %v", orderID, err)
+			continue
+		}
+		issued++
+	}
+	return issued, nil
+}
+
+// buildInvoice maps a receipt to the invoice submitted for it.
+func buildInvoice(r models.Receipt) einvoice.Invoice {
+	sales, tax, total := invoiceAmounts(r.Total, r.TaxRate)
+	inv := einvoice.Invoice{
+		RelateNumber: r.ReceiptNumber,
+		SellerBAN:    r.MerchantTaxID,
+		SellerName:   r.ShopName,
+		SalesAmount:  sales,
+		TaxType:      einvoice.TaxTypeTaxable,
+		TaxRate:      r.TaxRate,
+		TaxAmount:    tax,
+		TotalAmount:  total,
+		IssuedAt:     r.IssuedAt,
+		Print:        r.Invoice.Carrier == "",
+	}
+	if r.Invoice.Carrier != "" {
+		inv.CarrierType = einvoice.CarrierTypeMobileBarcode
+		inv.CarrierID = r.Invoice.Carrier
+	}
+	for _, item := range r.Items {
+		amount := int64(math.Round(item.Amount))
+		inv.Items = append(inv.Items, einvoice.Item{
+			Description: item.Name,
+			Quantity:    item.Quantity,
+			UnitPrice:   amount / int64(item.Quantity),
+			Amount:      amount,
+		})
+	}
+	return inv
+}
+
+// issueInvoice submits one receipt's e-invoice and records the outcome.
+func issueInvoice(issuer einvoice.Issuer, orderID int) error {
+	r, err := loadReceipt(orderID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), providerCallTimeout)
+	defer cancel()
+
+	issued, err := issuer.Issue(ctx, buildInvoice(r))
+	if err != nil {
+		db.DB.Exec(`
+			UPDATE receipts SET invoice_status = 'failed', invoice_attempts = invoice_attempts + 1, invoice_error = $1
+			WHERE id = $2
+		`, err.Error(), r.ID)
+		return err
+	}
+	_, err = db.DB.Exec(`
+		UPDATE receipts
+		SET invoice_status = 'issued', invoice_number = $1, invoice_random_code = $2, invoice_issued_at = $3,
+		    invoice_attempts = invoice_attempts + 1, invoice_error = NULL
+		WHERE id = $4
+	`, issued.InvoiceNumber, issued.RandomNumber, issued.InvoiceDate.UTC(), r.ID)
+	if err != nil {
+		return err
+	}
+
+	notifyUser(r.ConsumerID, "E-invoice issued",
+		fmt.Sprintf("E-invoice %s from %s (%s %.0f) has been issued.", issued.InvoiceNumber, r.ShopName, r.Currency, r.Total), "order")
+	return nil
+}
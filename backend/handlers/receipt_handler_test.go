@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"food-platform-backend/einvoice"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =========================================================================
+// RECEIPT TESTS
+// =========================================================================
+
+func testReceipt() models.Receipt {
+	issuedAt := time.Date(2026, 3, 2, 18, 30, 0, 0, time.UTC)
+	return models.Receipt{
+		ReceiptNumber: receiptNumber(7, issuedAt),
+		OrderID:       7,
+		ShopName:      "好食麵包店",
+		MerchantTaxID: "22099131",
+		Items:         []models.ReceiptItem{{Name: "Bento", Quantity: 1, UnitPrice: 200, Discount: 95, Amount: 105}},
+		Subtotal:      200,
+		Discount:      95,
+		Total:         105,
+		TaxRate:       receiptTaxRate,
+		TaxAmount:     includedTax(105, receiptTaxRate),
+		Currency:      "TWD",
+		Invoice:       models.EInvoice{Status: models.InvoiceStatusPending, Carrier: "/ABC+123"},
+		IssuedAt:      issuedAt,
+	}
+}
+
+func TestReceiptAmounts(t *testing.T) {
+	assert.Equal(t, 5.0, includedTax(105, 0.05))
+	assert.Equal(t, 4.76, includedTax(99.99, 0.05))
+
+	sales, tax, total := invoiceAmounts(105, 0.05)
+	assert.Equal(t, []int64{100, 5, 105}, []int64{sales, tax, total})
+	sales, tax, total = invoiceAmounts(49.5, 0.05)
+	assert.Equal(t, int64(50), total)
+	assert.Equal(t, total, sales+tax)
+
+	assert.Equal(t, "R20260302-000007", receiptNumber(7, time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)))
+}
+
+func TestBuildInvoice(t *testing.T) {
+	inv := buildInvoice(testReceipt())
+	require.NoError(t, inv.Validate())
+	assert.Equal(t, "R20260302-000007", inv.RelateNumber)
+	assert.Equal(t, einvoice.CarrierTypeMobileBarcode, inv.CarrierType)
+	assert.False(t, inv.Print)
+	assert.Equal(t, []einvoice.Item{{Description: "Bento", Quantity: 1, UnitPrice: 105, Amount: 105}}, inv.Items)
+
+	r := testReceipt()
+	r.Invoice.Carrier = ""
+	inv = buildInvoice(r)
+	assert.True(t, inv.Print, "invoices without a carrier get a paper proof")
+	assert.Empty(t, inv.CarrierType)
+}
+
+func TestRenderReceiptPDF(t *testing.T) {
+	r := testReceipt()
+	issuedAt := r.IssuedAt
+	r.Invoice = models.EInvoice{Status: models.InvoiceStatusIssued, Number: "AB00000001", RandomCode: "0420", IssuedAt: &issuedAt}
+	pdf := renderReceiptPDF(r)
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), pdfText("好食麵包店"))
+	assert.Contains(t, string(pdf), pdfText("AB-00000001"))
+	assert.Contains(t, string(pdf), pdfText("115年03-04月"))
+
+	// Every xref entry points at its object
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(pdf)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n0 8\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(pdf[xref:], -1)
+	require.Len(t, entries, 7)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(pdf[off:], []byte(fmt.Sprintf("%d 0 obj", i+1))), "object %d", i+1)
+	}
+}
+
+func TestPDFText(t *testing.T) {
+	assert.Equal(t, "<00410042>", pdfText("AB"))
+	assert.Equal(t, "<6536>", pdfText("收"))
+	assert.Equal(t, 10.0, pdfTextWidth("AB", 10))
+	assert.Equal(t, 20.0, pdfTextWidth("收據", 10))
+}
+
+func TestReceiptValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me/orders/:id/receipt", GetMyReceipt)
+	r.PUT("/me/invoice-carrier", UpdateInvoiceCarrier)
+	r.PUT("/merchant/invoice-settings", UpdateInvoiceSettings)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{"GET", "/me/orders/abc/receipt", ""},
+		{"GET", "/me/orders/1/receipt?format=docx", ""},
+		{"PUT", "/me/invoice-carrier", `{"carrier":"ABC1234"}`},
+		{"PUT", "/me/invoice-carrier", `{"carrier":"/ABC_123"}`},
+		{"PUT", "/merchant/invoice-settings", `{"tax_id":"12345678"}`},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.path+" "+tc.body)
+	}
+}
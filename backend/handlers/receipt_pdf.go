@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"food-platform-backend/einvoice"
+	"food-platform-backend/models"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Receipts are rendered as a single A6 page using Adobe's MSung-Light, a
+// predefined CJK font PDF readers supply themselves, so Chinese shop and
+// item names print without embedding a font.
+const (
+	receiptPageWidth  = 297.64
+	receiptPageHeight = 419.53
+	receiptMargin     = 24.0
+)
+
+// pdfPage collects text drawing operators for one page.
+type pdfPage struct {
+	content bytes.Buffer
+	y       float64
+}
+
+// pdfText encodes s as a UTF-16BE hex string for the UniCNS-UCS2-H encoding.
+func pdfText(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// pdfTextWidth approximates the width of s: half-width for ASCII, full
+// width for everything else.
+func pdfTextWidth(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		if r < 0x80 {
+			w += 0.5
+		} else {
+			w += 1
+		}
+	}
+	return w * size
+}
+
+func (p *pdfPage) text(x float64, s string, size float64) {
+	fmt.Fprintf(&p.content, "BT /F1 %.1f Tf %.2f %.2f Td %s Tj ET\n", size, x, p.y, pdfText(s))
+}
+
+// row writes left and right aligned text on the current line and moves down.
+func (p *pdfPage) row(left, right string, size float64) {
+	p.text(receiptMargin, left, size)
+	if right != "" {
+		p.text(receiptPageWidth-receiptMargin-pdfTextWidth(right, size), right, size)
+	}
+	p.y -= size * 1.6
+}
+
+func (p *pdfPage) rule() {
+	fmt.Fprintf(&p.content, "%.2f %.2f m %.2f %.2f l S\n", receiptMargin, p.y+4, receiptPageWidth-receiptMargin, p.y+4)
+	p.y -= 8
+}
+
+func formatMoney(currency string, amount float64) string {
+	return fmt.Sprintf("%s %.2f", currency, amount)
+}
+
+// renderReceiptPDF lays out a receipt and, once issued, its e-invoice.
+func renderReceiptPDF(r models.Receipt) []byte {
+	p := &pdfPage{y: receiptPageHeight - receiptMargin - 14}
+	p.row(r.ShopName, "", 14)
+	if r.MerchantAddress != "" {
+		p.row(r.MerchantAddress, "", 8)
+	}
+	if r.MerchantTaxID != "" {
+		p.row("統一編號 Tax ID: "+r.MerchantTaxID, "", 8)
+	}
+	p.y -= 4
+	p.row("收據 Receipt", r.ReceiptNumber, 10)
+	p.row("日期 Date", r.IssuedAt.Format("2006-01-02 15:04"), 8)
+	p.row("訂單 Order", fmt.Sprintf("#%d", r.OrderID), 8)
+	p.rule()
+
+	for _, item := range r.Items {
+		p.row(fmt.Sprintf("%s x%d", item.Name, item.Quantity), formatMoney(r.Currency, item.UnitPrice*float64(item.Quantity)), 9)
+	}
+	p.rule()
+	p.row("小計 Subtotal", formatMoney(r.Currency, r.Subtotal), 9)
+	p.row("惜食折扣 Discount", "-"+formatMoney(r.Currency, r.Discount), 9)
+	p.row("總計 Total", formatMoney(r.Currency, r.Total), 11)
+	p.row(fmt.Sprintf("含稅 Tax incl. (%.0f%%)", r.TaxRate*100), formatMoney(r.Currency, r.TaxAmount), 8)
+	if r.PaymentMethod != "" {
+		p.row("付款方式 Paid with", r.PaymentMethod, 8)
+	}
+
+	if r.Invoice.Status == models.InvoiceStatusIssued && r.Invoice.IssuedAt != nil {
+		p.rule()
+		period := einvoice.Period(*r.Invoice.IssuedAt)
+		number := r.Invoice.Number
+		if len(number) == 10 {
+			number = number[:2] + "-" + number[2:]
+		}
+		p.row("電子發票 E-invoice", number, 10)
+		month, _ := strconv.Atoi(period[3:])
+		p.row("期別 Period", fmt.Sprintf("%s年%02d-%02d月", period[:3], month-1, month), 8)
+		p.row("隨機碼 Random code", r.Invoice.RandomCode, 8)
+		if r.Invoice.Carrier != "" {
+			p.row("手機條碼 Carrier", r.Invoice.Carrier, 8)
+		}
+	}
+
+	return buildPDF(p.content.Bytes())
+}
+
+// buildPDF wraps one page's content stream in a minimal PDF document.
+func buildPDF(content []byte) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+			receiptPageWidth, receiptPageHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /MSung-Light /Encoding /UniCNS-UCS2-H /DescendantFonts [6 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /MSung-Light " +
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (CNS1) /Supplement 0 >> " +
+			"/FontDescriptor 7 0 R /DW 1000 /W [13648 13742 500] >>",
+		"<< /Type /FontDescriptor /FontName /MSung-Light /Flags 6 /FontBBox [0 -200 1000 900] " +
+			"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errNoShowTooEarly     = errors.New("pickup window has not ended yet")
+	errConsumerSuspended  = errors.New("ordering is suspended after repeated missed pickups")
+	errPrepaymentRequired = errors.New("recent pickups were missed, pay online to order")
+	errOpenOrderLimit     = errors.New("too many open orders, pick up your current orders first")
+)
+
+// Reliability is scored over recent orders only, so consumers recover from
+// old no-shows. The score starts every consumer with reliabilityPrior
+// pickups so one early miss does not sink a new account.
+const (
+	reliabilityWindowDays = 90
+	reliabilityPrior      = 3
+	suspensionDays        = 30
+	disputeWindowDays     = 7
+)
+
+// defaultNoShowGraceMinutes is how long after the pickup window closes an
+// uncollected order is marked as a no-show, overridable with
+// NO_SHOW_GRACE_MINUTES.
+const defaultNoShowGraceMinutes = 30
+
+// noShowGrace is how long the sweeper waits after a pickup window ends.
+func noShowGrace() time.Duration {
+	minutes := defaultNoShowGraceMinutes
+	if v, err := strconv.Atoi(os.Getenv("NO_SHOW_GRACE_MINUTES")); err == nil && v >= 0 {
+		minutes = v
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// reliabilityFor scores a consumer and picks their restrictions from the
+// pickups and counted no-shows in the window. Restrictions escalate:
+//
+//	0-1 no-shows: none
+//	2:            at most 2 open orders
+//	3-4:          online prepayment required, at most 1 open order
+//	5+:           suspended for 30 days after the last no-show, then as 3-4
+func reliabilityFor(pickedUp, noShows int, lastNoShow *time.Time, now time.Time) models.ConsumerReliability {
+	r := models.ConsumerReliability{
+		PickedUp:   pickedUp,
+		NoShows:    noShows,
+		WindowDays: reliabilityWindowDays,
+		Score:      int(math.Round(100 * float64(pickedUp+reliabilityPrior) / float64(pickedUp+noShows+reliabilityPrior))),
+		Tier:       models.ReliabilityTierGood,
+	}
+	switch {
+	case noShows >= 5 && lastNoShow != nil && now.Before(lastNoShow.AddDate(0, 0, suspensionDays)):
+		until := lastNoShow.AddDate(0, 0, suspensionDays)
+		r.Tier, r.SuspendedUntil = models.ReliabilityTierSuspended, &until
+		r.PrepaymentRequired, r.MaxOpenOrders = true, 1
+	case noShows >= 3:
+		r.Tier, r.PrepaymentRequired, r.MaxOpenOrders = models.ReliabilityTierPrepay, true, 1
+	case noShows == 2:
+		r.Tier, r.MaxOpenOrders = models.ReliabilityTierWarned, 2
+	}
+	return r
+}
+
+// loadReliability scores a consumer from their orders. No-shows under open
+// dispute still count until the dispute is overturned.
+func loadReliability(q queryer, consumerID string, now time.Time) (models.ConsumerReliability, error) {
+	var pickedUp, noShows int
+	var lastNoShow *time.Time
+	err := q.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE o.status = 'picked_up'),
+		       COUNT(*) FILTER (WHERE o.status = 'no_show' AND COALESCE(d.status, '') <> 'overturned'),
+		       MAX(o.no_show_at) FILTER (WHERE o.status = 'no_show' AND COALESCE(d.status, '') <> 'overturned')
+		FROM orders o
+		LEFT JOIN no_show_disputes d ON d.order_id = o.id
+		WHERE o.consumer_id = $1 AND COALESCE(o.picked_up_at, o.no_show_at) >= $2
+	`, consumerID, now.AddDate(0, 0, -reliabilityWindowDays).UTC()).Scan(&pickedUp, &noShows, &lastNoShow)
+	if err != nil {
+		return models.ConsumerReliability{}, err
+	}
+	r := reliabilityFor(pickedUp, noShows, lastNoShow, now)
+	r.ConsumerID = consumerID
+	return r, nil
+}
+
+// enforceReliability checks that a consumer may place newOrders more orders
+// in tx. Callers lock the consumer first (see checkConsumerMayBuy) so
+// concurrent purchases cannot both slip under the open order limit.
+func enforceReliability(tx *sql.Tx, consumerID string, newOrders int, prepaid bool, now time.Time) error {
+	r, err := loadReliability(tx, consumerID, now)
+	if err != nil {
+		return err
+	}
+	var open int
+	if r.MaxOpenOrders > 0 {
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM orders
+			WHERE consumer_id = $1 AND COALESCE(status, 'pending') IN ('pending', 'confirmed', 'ready_for_pickup')
+		`, consumerID).Scan(&open)
+		if err != nil {
+			return err
+		}
+	}
+	return reliabilityBlock(r, open, newOrders, prepaid)
+}
+
+// reliabilityBlock applies a consumer's restrictions to an order for
+// newOrders more items on top of their open orders.
+func reliabilityBlock(r models.ConsumerReliability, open, newOrders int, prepaid bool) error {
+	if r.SuspendedUntil != nil {
+		return fmt.Errorf("%w until %s", errConsumerSuspended, r.SuspendedUntil.UTC().Format("2006-01-02"))
+	}
+	if r.PrepaymentRequired && !prepaid {
+		return errPrepaymentRequired
+	}
+	if r.MaxOpenOrders > 0 && open+newOrders > r.MaxOpenOrders {
+		return fmt.Errorf("%w (limit %d)", errOpenOrderLimit, r.MaxOpenOrders)
+	}
+	return nil
+}
+
+// checkNoShowDue rejects a no-show before the order's pickup window ends.
+func checkNoShowDue(tx *sql.Tx, o orderRef, now time.Time) error {
+	var pickupEnd time.Time
+	if err := tx.QueryRow(`SELECT COALESCE(pickup_end, expiry_date) FROM products WHERE id = $1`, o.ProductID).Scan(&pickupEnd); err != nil {
+		return err
+	}
+	if now.Before(pickupEnd) {
+		return errNoShowTooEarly
+	}
+	return nil
+}
+
+// GetMyReliability - GET /me/reliability
+func GetMyReliability(c *gin.Context) {
+	r, err := loadReliability(db.DB, c.GetString("user_id"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load reliability"})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// =========================================================================
+// NO-SHOW SWEEPER
+// =========================================================================
+
+// StartNoShowSweeper marks confirmed orders nobody collected as no-shows
+// every interval.
+func StartNoShowSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := sweepNoShows(time.Now()); err != nil {
+				log.Println("No-show sweeper:", err)
+			} else if n > 0 {
+				log.Printf("No-show sweeper: marked %d orders", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// sweepNoShows marks confirmed and ready orders as no-shows once their
+// pickup window ended more than the grace period ago. Orders the merchant
+// never confirmed are left for the merchant to cancel.
+func sweepNoShows(now time.Time) (int, error) {
+	ids, err := queryIDs(`
+		SELECT o.id FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.status IN ('confirmed', 'ready_for_pickup') AND COALESCE(p.pickup_end, p.expiry_date) < $1
+		ORDER BY o.id
+		LIMIT 100
+	`, now.Add(-noShowGrace()).UTC())
+	if err != nil {
+		return 0, err
+	}
+	marked := 0
+	for _, id := range ids {
+		if err := markNoShow(id, now); err != nil {
+			log.Printf("No-show sweeper: order %d: %v", id, err)
+			continue
+		}
+		marked++
+	}
+	return marked, nil
+}
+
+func markNoShow(orderID int, now time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	o, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if o.Status != models.OrderStatusConfirmed && o.Status != models.OrderStatusReadyForPickup {
+		return nil
+	}
+	if err := transitionOrder(tx, &o, models.OrderStatusNoShow); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	notifyOrderTransition(o, "")
+	return nil
+}
+
+// =========================================================================
+// NO-SHOW DISPUTES
+// =========================================================================
+
+// DisputeNoShow - POST /me/orders/:id/no-show-dispute
+// Lets a consumer contest a no-show within 7 days. The merchant can accept
+// the dispute; otherwise an admin decides.
+func DisputeNoShow(c *gin.Context) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	var input struct {
+		Reason string `json:"reason" binding:"required,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason required"})
+		return
+	}
+
+	var o orderRef
+	var noShowAt *time.Time
+	err = db.DB.QueryRow(`
+		SELECT o.id, o.consumer_id, COALESCE(o.merchant_id, p.merchant_id, ''), COALESCE(o.product_name, p.name),
+		       COALESCE(o.status, 'pending'), o.no_show_at
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = $1
+	`, orderID).Scan(&o.ID, &o.ConsumerID, &o.MerchantID, &o.ProductName, &o.Status, &noShowAt)
+	if err == sql.ErrNoRows || (err == nil && o.ConsumerID != c.GetString("user_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if o.Status != models.OrderStatusNoShow {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order was not marked as a no-show"})
+		return
+	}
+	if noShowAt != nil && time.Since(*noShowAt) > disputeWindowDays*24*time.Hour {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("No-shows can only be disputed within %d days", disputeWindowDays)})
+		return
+	}
+
+	var d models.NoShowDispute
+	err = db.DB.QueryRow(`
+		INSERT INTO no_show_disputes (order_id, consumer_id, merchant_id, reason)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_id) DO NOTHING
+		RETURNING id, status, created_at
+	`, o.ID, o.ConsumerID, o.MerchantID, strings.TrimSpace(input.Reason)).Scan(&d.ID, &d.Status, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "This no-show is already disputed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dispute"})
+		return
+	}
+	d.OrderID, d.ConsumerID, d.MerchantID, d.ProductName = o.ID, o.ConsumerID, o.MerchantID, o.ProductName
+	d.Reason, d.NoShowAt = strings.TrimSpace(input.Reason), noShowAt
+
+	notifyUser(o.MerchantID, "No-show disputed",
+		fmt.Sprintf("A customer disputes the no-show on order #%d for %s: %s", o.ID, o.ProductName, d.Reason), "no_show_dispute")
+	c.JSON(http.StatusCreated, d)
+}
+
+// GetMerchantNoShowDisputes - GET /merchant/no-show-disputes?status=
+func GetMerchantNoShowDisputes(c *gin.Context) {
+	listNoShowDisputes(c, c.GetString("user_id"))
+}
+
+// GetNoShowDisputes - GET /admin/no-show-disputes?status=
+func GetNoShowDisputes(c *gin.Context) {
+	listNoShowDisputes(c, "")
+}
+
+// listNoShowDisputes writes disputes, newest first, for one merchant or,
+// with an empty merchantID, for everyone.
+func listNoShowDisputes(c *gin.Context, merchantID string) {
+	status := c.Query("status")
+	switch status {
+	case "", models.NoShowDisputeOpen, models.NoShowDisputeUpheld, models.NoShowDisputeOverturned:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	rows, err := db.DB.Query(`
+		SELECT d.id, d.order_id, d.consumer_id, d.merchant_id, COALESCE(o.product_name, ''), d.reason, d.status,
+		       COALESCE(d.merchant_response, ''), COALESCE(d.resolution_note, ''), o.no_show_at, d.resolved_at, d.created_at
+		FROM no_show_disputes d
+		JOIN orders o ON o.id = d.order_id
+		WHERE ($1 = '' OR d.merchant_id = $1) AND ($2 = '' OR d.status = $2)
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $3 OFFSET $4
+	`, merchantID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch disputes"})
+		return
+	}
+	defer rows.Close()
+
+	disputes := []models.NoShowDispute{}
+	for rows.Next() {
+		var d models.NoShowDispute
+		if err := rows.Scan(&d.ID, &d.OrderID, &d.ConsumerID, &d.MerchantID, &d.ProductName, &d.Reason, &d.Status,
+			&d.MerchantResponse, &d.ResolutionNote, &d.NoShowAt, &d.ResolvedAt, &d.CreatedAt); err != nil {
+			continue
+		}
+		disputes = append(disputes, d)
+	}
+	c.JSON(http.StatusOK, gin.H{"disputes": disputes, "page": page, "page_size": pageSize})
+}
+
+// RespondNoShowDispute - POST /merchant/no-show-disputes/:id/respond
+// Accepting a dispute overturns the no-show. Otherwise the merchant's
+// response is kept for the admin who decides it.
+func RespondNoShowDispute(c *gin.Context) {
+	var input struct {
+		Accept   bool   `json:"accept"`
+		Response string `json:"response" binding:"max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (!input.Accept && strings.TrimSpace(input.Response) == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Accept the dispute or give a response"})
+		return
+	}
+	status := models.NoShowDisputeOpen
+	if input.Accept {
+		status = models.NoShowDisputeOverturned
+	}
+	updateNoShowDispute(c, c.GetString("user_id"), status, strings.TrimSpace(input.Response), "")
+}
+
+// ResolveNoShowDispute - PUT /admin/no-show-disputes/:id
+func ResolveNoShowDispute(c *gin.Context) {
+	var input struct {
+		Status string `json:"status" binding:"required,oneof=upheld overturned"`
+		Note   string `json:"note" binding:"max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be upheld or overturned"})
+		return
+	}
+	updateNoShowDispute(c, "", input.Status, "", strings.TrimSpace(input.Note))
+}
+
+// updateNoShowDispute records a response on, or resolves, the open dispute in
+// the :id param. An empty merchantID acts as an admin. The consumer is told
+// once the dispute is decided.
+func updateNoShowDispute(c *gin.Context, merchantID, status, response, note string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispute ID"})
+		return
+	}
+
+	var d models.NoShowDispute
+	err = db.DB.QueryRow(`
+		UPDATE no_show_disputes d SET
+			status = $3,
+			merchant_response = COALESCE(NULLIF($4, ''), d.merchant_response),
+			resolution_note = COALESCE(NULLIF($5, ''), d.resolution_note),
+			resolved_at = CASE WHEN $3 = 'open' THEN NULL ELSE CURRENT_TIMESTAMP END
+		FROM orders o
+		WHERE d.id = $1 AND o.id = d.order_id AND ($2 = '' OR d.merchant_id = $2) AND d.status = 'open'
+		RETURNING d.id, d.order_id, d.consumer_id, d.merchant_id, COALESCE(o.product_name, ''), d.reason, d.status,
+		          COALESCE(d.merchant_response, ''), COALESCE(d.resolution_note, ''), o.no_show_at, d.resolved_at, d.created_at
+	`, id, merchantID, status, response, note).Scan(&d.ID, &d.OrderID, &d.ConsumerID, &d.MerchantID, &d.ProductName,
+		&d.Reason, &d.Status, &d.MerchantResponse, &d.ResolutionNote, &d.NoShowAt, &d.ResolvedAt, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Open dispute not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dispute"})
+		return
+	}
+
+	switch d.Status {
+	case models.NoShowDisputeOverturned:
+		notifyUser(d.ConsumerID, "No-show removed",
+			fmt.Sprintf("The missed pickup on order #%d for %s no longer counts against you.", d.OrderID, d.ProductName), "no_show_dispute")
+	case models.NoShowDisputeUpheld:
+		body := fmt.Sprintf("Your dispute of the missed pickup on order #%d for %s was not accepted.", d.OrderID, d.ProductName)
+		if d.ResolutionNote != "" {
+			body += " Note: " + d.ResolutionNote
+		}
+		notifyUser(d.ConsumerID, "No-show upheld", body, "no_show_dispute")
+	}
+	c.JSON(http.StatusOK, d)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =========================================================================
+// RELIABILITY TESTS
+// =========================================================================
+
+func TestReliabilityScore(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 100, reliabilityFor(0, 0, nil, now).Score)
+	assert.Equal(t, 75, reliabilityFor(0, 1, nil, now).Score)
+	assert.Equal(t, 96, reliabilityFor(20, 1, nil, now).Score)
+	assert.Equal(t, 50, reliabilityFor(0, 3, nil, now).Score)
+}
+
+func TestReliabilityTiers(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	recent := now.AddDate(0, 0, -3)
+
+	r := reliabilityFor(10, 1, &recent, now)
+	assert.Equal(t, models.ReliabilityTierGood, r.Tier)
+	assert.Zero(t, r.MaxOpenOrders)
+	assert.False(t, r.PrepaymentRequired)
+
+	r = reliabilityFor(10, 2, &recent, now)
+	assert.Equal(t, models.ReliabilityTierWarned, r.Tier)
+	assert.Equal(t, 2, r.MaxOpenOrders)
+	assert.False(t, r.PrepaymentRequired)
+
+	r = reliabilityFor(10, 4, &recent, now)
+	assert.Equal(t, models.ReliabilityTierPrepay, r.Tier)
+	assert.Equal(t, 1, r.MaxOpenOrders)
+	assert.True(t, r.PrepaymentRequired)
+	assert.Nil(t, r.SuspendedUntil)
+
+	r = reliabilityFor(10, 5, &recent, now)
+	assert.Equal(t, models.ReliabilityTierSuspended, r.Tier)
+	require.NotNil(t, r.SuspendedUntil)
+	assert.Equal(t, recent.AddDate(0, 0, suspensionDays), *r.SuspendedUntil)
+
+	// Once the suspension lapses the consumer still has to prepay
+	old := now.AddDate(0, 0, -suspensionDays-1)
+	r = reliabilityFor(10, 5, &old, now)
+	assert.Equal(t, models.ReliabilityTierPrepay, r.Tier)
+	assert.Nil(t, r.SuspendedUntil)
+}
+
+func TestRestrictedConsumerIsBlocked(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	recent := now.AddDate(0, 0, -3)
+
+	good := reliabilityFor(10, 0, nil, now)
+	assert.NoError(t, reliabilityBlock(good, 5, 3, false))
+
+	warned := reliabilityFor(10, 2, &recent, now)
+	assert.NoError(t, reliabilityBlock(warned, 1, 1, false))
+	assert.ErrorIs(t, reliabilityBlock(warned, 1, 2, false), errOpenOrderLimit)
+
+	prepay := reliabilityFor(10, 3, &recent, now)
+	assert.ErrorIs(t, reliabilityBlock(prepay, 0, 1, false), errPrepaymentRequired)
+	assert.NoError(t, reliabilityBlock(prepay, 0, 1, true))
+	assert.ErrorIs(t, reliabilityBlock(prepay, 1, 1, true), errOpenOrderLimit)
+
+	suspended := reliabilityFor(10, 5, &recent, now)
+	err := reliabilityBlock(suspended, 0, 1, true)
+	assert.ErrorIs(t, err, errConsumerSuspended)
+	assert.Contains(t, err.Error(), "2026-03-29")
+
+	// Every restriction is refused outright, not reported as a server error
+	for _, err := range []error{
+		reliabilityBlock(warned, 2, 1, false),
+		reliabilityBlock(prepay, 0, 1, false),
+		reliabilityBlock(suspended, 0, 1, true),
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondPurchaseBlocked(c, err)
+		assert.Equal(t, http.StatusForbidden, w.Code, err.Error())
+	}
+}
+
+func TestNoShowGrace(t *testing.T) {
+	t.Setenv("NO_SHOW_GRACE_MINUTES", "")
+	assert.Equal(t, 30*time.Minute, noShowGrace())
+	t.Setenv("NO_SHOW_GRACE_MINUTES", "0")
+	assert.Equal(t, time.Duration(0), noShowGrace())
+	t.Setenv("NO_SHOW_GRACE_MINUTES", "-5")
+	assert.Equal(t, 30*time.Minute, noShowGrace())
+}
+
+func TestNoShowDisputeValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/me/orders/:id/no-show-dispute", DisputeNoShow)
+	r.GET("/merchant/no-show-disputes", GetMerchantNoShowDisputes)
+	r.POST("/merchant/no-show-disputes/:id/respond", RespondNoShowDispute)
+	r.PUT("/admin/no-show-disputes/:id", ResolveNoShowDispute)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{"POST", "/me/orders/abc/no-show-dispute", `{"reason":"I was there"}`},
+		{"POST", "/me/orders/1/no-show-dispute", `{}`},
+		{"POST", "/me/orders/1/no-show-dispute", `{"reason":"   "}`},
+		{"GET", "/merchant/no-show-disputes?status=closed", ""},
+		{"POST", "/merchant/no-show-disputes/1/respond", `{"accept":false}`},
+		{"POST", "/merchant/no-show-disputes/abc/respond", `{"accept":true}`},
+		{"PUT", "/admin/no-show-disputes/1", `{"status":"open"}`},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.path+" "+tc.body)
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"database/sql"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultReservationTTLMinutes is how long a hold lasts, overridable with
+// RESERVATION_TTL_MINUTES.
+const defaultReservationTTLMinutes = 10
+
+// reservationTTL is how long a reservation holds a product.
+func reservationTTL() time.Duration {
+	minutes := defaultReservationTTLMinutes
+	if v, err := strconv.Atoi(os.Getenv("RESERVATION_TTL_MINUTES")); err == nil && v > 0 {
+		minutes = v
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// holdExpiry is when a hold placed at now lapses: after the TTL, but never
+// later than the listing itself.
+func holdExpiry(now, listingExpiry time.Time, ttl time.Duration) time.Time {
+	expires := now.Add(ttl)
+	if listingExpiry.Before(expires) {
+		expires = listingExpiry
+	}
+	return expires.UTC()
+}
+
+// =========================================================================
+// RESERVATIONS
+// =========================================================================
+
+// ReserveProduct - POST /products/:id/reserve
+// Moves an AVAILABLE product to RESERVED for the caller until the hold
+// expires or is confirmed.
+func ReserveProduct(c *gin.Context) {
+	consumerID := c.GetString("user_id")
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	var expiry time.Time
+	var pickupEnd sql.NullTime
+	err = tx.QueryRow("SELECT status, expiry_date, pickup_end FROM products WHERE id = $1 FOR UPDATE", productID).Scan(&status, &expiry, &pickupEnd)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	now := time.Now()
+	if reason := purchaseBlockReason(status, expiry, pickupEnd, now); reason != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": reason})
+		return
+	}
+	if err := checkConsumerMayBuy(tx, consumerID, []int{productID}, false, now); err != nil {
+		respondPurchaseBlocked(c, err)
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE products SET status = 'RESERVED' WHERE id = $1", productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		return
+	}
+
+	var r models.Reservation
+	err = tx.QueryRow(`
+		INSERT INTO reservations (product_id, consumer_id, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, product_id, consumer_id, status, expires_at, created_at
+	`, productID, consumerID, holdExpiry(now, expiry, reservationTTL())).
+		Scan(&r.ID, &r.ProductID, &r.ConsumerID, &r.Status, &r.ExpiresAt, &r.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reservation"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, r)
+}
+
+// lockReservation loads and locks a reservation owned by consumerID,
+// writing the error response when it cannot be used.
+func lockReservation(c *gin.Context, tx *sql.Tx, consumerID string) (models.Reservation, bool) {
+	var r models.Reservation
+	err := tx.QueryRow(`
+		SELECT id, product_id, consumer_id, status, expires_at, created_at
+		FROM reservations WHERE id = $1 FOR UPDATE
+	`, c.Param("id")).Scan(&r.ID, &r.ProductID, &r.ConsumerID, &r.Status, &r.ExpiresAt, &r.CreatedAt)
+	if err == sql.ErrNoRows || (err == nil && r.ConsumerID != consumerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return r, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return r, false
+	}
+	if r.Status != models.ReservationStatusActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reservation is " + string(r.Status)})
+		return r, false
+	}
+	return r, true
+}
+
+// endReservation sets the final status of an active reservation and returns
+// its product to AVAILABLE.
+func endReservation(tx *sql.Tx, r models.Reservation, status models.ReservationStatus) error {
+	if _, err := tx.Exec("UPDATE products SET status = 'AVAILABLE' WHERE id = $1 AND status = 'RESERVED'", r.ProductID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE reservations SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", status, r.ID)
+	return err
+}
+
+// ConfirmReservation - POST /reservations/:id/confirm
+// Turns an active hold into an order.
+func ConfirmReservation(c *gin.Context) {
+	var input struct {
+		SlotID *int `json:"slot_id"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock order is always reservation, then product (same as the reaper)
+	r, ok := lockReservation(c, tx, c.GetString("user_id"))
+	if !ok {
+		return
+	}
+
+	if !time.Now().Before(r.ExpiresAt) {
+		if err := endReservation(tx, r, models.ReservationStatusExpired); err == nil {
+			tx.Commit()
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Reservation expired"})
+		return
+	}
+
+	var status string
+	if err := tx.QueryRow("SELECT status FROM products WHERE id = $1 FOR UPDATE", r.ProductID).Scan(&status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if status != string(models.ProductStatusReserved) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Product is no longer held"})
+		return
+	}
+	if err := checkConsumerMayBuy(tx, r.ConsumerID, []int{r.ProductID}, false, time.Now()); err != nil {
+		respondPurchaseBlocked(c, err)
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE products SET status = 'SOLD' WHERE id = $1", r.ProductID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		return
+	}
+
+	orderID, err := createOrder(tx, r.ProductID, r.ConsumerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		return
+	}
+	if input.SlotID != nil {
+		if err := bookPickupSlot(tx, orderID, *input.SlotID, time.Now()); err != nil {
+			respondSlotError(c, err)
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reservations SET status = 'confirmed', order_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+	`, orderID, r.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reservation"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase successful! Enjoy your food.", "order_id": orderID})
+}
+
+// ReleaseReservation - POST /reservations/:id/release
+// Lets the consumer give up a hold before it expires.
+func ReleaseReservation(c *gin.Context) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, ok := lockReservation(c, tx, c.GetString("user_id"))
+	if !ok {
+		return
+	}
+
+	if err := endReservation(tx, r, models.ReservationStatusReleased); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release reservation"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reservation released"})
+}
+
+// =========================================================================
+// RESERVATION REAPER
+// =========================================================================
+
+// StartReservationReaper releases expired holds every interval.
+func StartReservationReaper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := releaseExpiredReservations(time.Now()); err != nil {
+				log.Println("Reservation reaper:", err)
+			} else if n > 0 {
+				log.Printf("Reservation reaper: released %d expired holds", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// releaseExpiredReservations expires holds in batches. SKIP LOCKED leaves
+// reservations that are being confirmed right now to their own transaction.
+func releaseExpiredReservations(now time.Time) (int, error) {
+	released := 0
+	for {
+		n, err := releaseExpiredBatch(now, 100)
+		released += n
+		if err != nil || n < 100 {
+			return released, err
+		}
+	}
+}
+
+func releaseExpiredBatch(now time.Time, limit int) (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, product_id FROM reservations
+		WHERE status = 'active' AND expires_at <= $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, now.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	var expired []models.Reservation
+	for rows.Next() {
+		var r models.Reservation
+		if err := rows.Scan(&r.ID, &r.ProductID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, r)
+	}
+	rows.Close()
+
+	for _, r := range expired {
+		if err := endReservation(tx, r, models.ReservationStatusExpired); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(expired), tx.Commit()
+}
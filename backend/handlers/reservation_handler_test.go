@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// RESERVATION TESTS
+// =========================================================================
+
+func TestReserveProductRequiresToken(t *testing.T) {
+	router := gin.New()
+	router.POST("/products/:id/reserve", AuthRequired(), ReserveProduct)
+
+	req, _ := http.NewRequest("POST", "/products/1/reserve", bytes.NewBuffer([]byte(`{"consumer_id": "user1"}`)))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestReserveProductInvalidID(t *testing.T) {
+	router := gin.New()
+	router.POST("/products/:id/reserve", AuthRequired(), ReserveProduct)
+
+	req, _ := http.NewRequest("POST", "/products/abc/reserve", nil)
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "user1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestReservationHoldsRequireToken(t *testing.T) {
+	router := gin.New()
+	router.POST("/reservations/:id/confirm", AuthRequired(), ConfirmReservation)
+	router.POST("/reservations/:id/release", AuthRequired(), ReleaseReservation)
+
+	for _, path := range []string{"/reservations/1/confirm", "/reservations/1/release"} {
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer([]byte(`{"consumer_id": "user1"}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+
+	req, _ := http.NewRequest("POST", "/reservations/1/confirm", bytes.NewBuffer([]byte(`{"slot_id": "soon"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+signedTestToken(t, "user1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHoldExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC)
+
+	// TTL applies while the listing outlives it
+	assert.Equal(t, now.Add(10*time.Minute), holdExpiry(now, now.Add(time.Hour), 10*time.Minute))
+
+	// A hold never outlives the listing
+	assert.Equal(t, now.Add(5*time.Minute), holdExpiry(now, now.Add(5*time.Minute), 10*time.Minute))
+}
+
+func TestReservationTTLFromEnv(t *testing.T) {
+	t.Setenv("RESERVATION_TTL_MINUTES", "15")
+	assert.Equal(t, 15*time.Minute, reservationTTL())
+
+	t.Setenv("RESERVATION_TTL_MINUTES", "bogus")
+	assert.Equal(t, defaultReservationTTLMinutes*time.Minute, reservationTTL())
+}
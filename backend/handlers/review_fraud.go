@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The fraud scanner looks at recent reviews for signs of rating manipulation
+// and holds suspicious ones as flagged, out of the public listing and the
+// rating aggregates, until an admin restores or removes them. Reviews an
+// admin has already ruled on are never flagged again.
+
+// Flag rules, stored with the review's moderation flags.
+const (
+	fraudRuleBurst        = "fraud_burst"
+	fraudRuleSharedDevice = "fraud_shared_device"
+	fraudRulePhonePrefix  = "fraud_phone_prefix"
+	fraudRuleCopyPaste    = "fraud_copy_paste"
+)
+
+const (
+	fraudLookback = 30 * 24 * time.Hour
+
+	// A burst is fraudBurstMinReviews or more 5★ reviews of one merchant
+	// within fraudBurstWindow, each from an account younger than
+	// fraudNewAccountAge when it reviewed.
+	fraudBurstWindow     = 24 * time.Hour
+	fraudBurstMinReviews = 3
+	fraudNewAccountAge   = 7 * 24 * time.Hour
+
+	// Numbers sharing this many leading national digits come from the same
+	// block, as bulk-bought SIMs do.
+	fraudPhonePrefixDigits = 7
+
+	// Comments at least this long whose 3-character shingles overlap this
+	// much with another reviewer's comment count as copy-pasted.
+	fraudCopyPasteMinRunes   = 20
+	fraudCopyPasteSimilarity = 0.8
+)
+
+// fraudCandidate is a recent review with what the detectors need to know
+// about its author and merchant.
+type fraudCandidate struct {
+	ID               int
+	UserID           string
+	MerchantID       string
+	Rating           int
+	Comment          string
+	Status           string
+	Cleared          bool // An admin has ruled on it
+	CreatedAt        time.Time
+	AccountCreatedAt time.Time
+	ReviewerPhone    string
+	MerchantPhone    string
+	SharedDevice     bool
+}
+
+// recordDevice remembers the install ID the app sent with a request, so the
+// scanner can link reviewers to the merchants they review.
+func recordDevice(c *gin.Context, userID string) {
+	deviceID := strings.TrimSpace(c.GetHeader("X-Device-ID"))
+	if userID == "" || deviceID == "" || len(deviceID) > 200 {
+		return
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO user_devices (user_id, device_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, device_id) DO UPDATE SET last_seen_at = CURRENT_TIMESTAMP
+	`, userID, deviceID)
+	if err != nil {
+		log.Printf("Record device for %s: %v", userID, err)
+	}
+}
+
+// StartReviewFraudScanner periodically flags suspicious recent reviews.
+func StartReviewFraudScanner(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := scanReviewFraud(time.Now()); err != nil {
+				log.Println("Review fraud scanner:", err)
+			} else if n > 0 {
+				log.Printf("Review fraud scanner: flagged %d reviews", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func scanReviewFraud(now time.Time) (int, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.id, r.user_id, r.merchant_id, r.rating, COALESCE(r.comment, ''), r.status, r.moderated_at IS NOT NULL,
+		       r.created_at, COALESCE(u.created_at, r.created_at), COALESCE(u.phone, ''),
+		       COALESCE(NULLIF(m.phone, ''), mu.phone, ''),
+		       EXISTS (
+		           SELECT 1 FROM user_devices du
+		           JOIN user_devices dm ON dm.device_id = du.device_id
+		           WHERE du.user_id = r.user_id AND dm.user_id = r.merchant_id
+		       )
+		FROM reviews r
+		LEFT JOIN users u ON u.id = r.user_id
+		LEFT JOIN merchants m ON m.user_id = r.merchant_id
+		LEFT JOIN users mu ON mu.id = r.merchant_id
+		WHERE r.created_at >= $1 AND r.status IN ('published', 'flagged')
+	`, now.Add(-fraudLookback).UTC())
+	if err != nil {
+		return 0, err
+	}
+	var reviews []fraudCandidate
+	for rows.Next() {
+		var r fraudCandidate
+		if err := rows.Scan(&r.ID, &r.UserID, &r.MerchantID, &r.Rating, &r.Comment, &r.Status, &r.Cleared,
+			&r.CreatedAt, &r.AccountCreatedAt, &r.ReviewerPhone, &r.MerchantPhone, &r.SharedDevice); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		reviews = append(reviews, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	flagged := 0
+	for id, flags := range detectReviewFraud(reviews) {
+		ok, err := flagSuspectedReview(id, flags)
+		if err != nil {
+			log.Printf("Review fraud scanner: review %d: %v", id, err)
+			continue
+		}
+		if ok {
+			flagged++
+		}
+	}
+	return flagged, nil
+}
+
+// detectReviewFraud returns the flags for each review that should be held.
+// Reviews already flagged or cleared by an admin still count as context (a
+// burst stays a burst) but are not returned.
+func detectReviewFraud(reviews []fraudCandidate) map[int][]models.ReviewFlag {
+	all := map[int][]models.ReviewFlag{}
+	add := func(id int, rule, match string) {
+		all[id] = append(all[id], models.ReviewFlag{Rule: rule, Match: match})
+	}
+
+	for _, r := range reviews {
+		if r.SharedDevice {
+			add(r.ID, fraudRuleSharedDevice, "device also used by the merchant")
+		}
+		if prefix, ok := sharedPhonePrefix(r.ReviewerPhone, r.MerchantPhone); ok {
+			add(r.ID, fraudRulePhonePrefix, prefix+"…")
+		}
+	}
+	for id, match := range detectBursts(reviews) {
+		add(id, fraudRuleBurst, match)
+	}
+	for id, match := range detectCopyPaste(reviews) {
+		add(id, fraudRuleCopyPaste, match)
+	}
+
+	flags := map[int][]models.ReviewFlag{}
+	for _, r := range reviews {
+		if f := all[r.ID]; len(f) > 0 && r.Status == models.ReviewStatusPublished && !r.Cleared {
+			flags[r.ID] = f
+		}
+	}
+	return flags
+}
+
+// detectBursts finds new-account 5★ reviews that arrived in bursts.
+func detectBursts(reviews []fraudCandidate) map[int]string {
+	byMerchant := map[string][]fraudCandidate{}
+	for _, r := range reviews {
+		if r.Rating == 5 && r.CreatedAt.Sub(r.AccountCreatedAt) < fraudNewAccountAge {
+			byMerchant[r.MerchantID] = append(byMerchant[r.MerchantID], r)
+		}
+	}
+	found := map[int]string{}
+	for _, rs := range byMerchant {
+		sort.Slice(rs, func(i, j int) bool { return rs[i].CreatedAt.Before(rs[j].CreatedAt) })
+		end := 0
+		for start := range rs {
+			for end < len(rs) && rs[end].CreatedAt.Sub(rs[start].CreatedAt) <= fraudBurstWindow {
+				end++
+			}
+			if n := end - start; n >= fraudBurstMinReviews {
+				match := fmt.Sprintf("%d new-account 5★ reviews within %s", n, fraudBurstWindow)
+				for _, r := range rs[start:end] {
+					if _, seen := found[r.ID]; !seen {
+						found[r.ID] = match
+					}
+				}
+			}
+		}
+	}
+	return found
+}
+
+// detectCopyPaste finds comments on the same merchant repeated by different
+// reviewers, either exactly or nearly.
+func detectCopyPaste(reviews []fraudCandidate) map[int]string {
+	type text struct {
+		r        fraudCandidate
+		norm     string
+		shingles map[string]bool
+	}
+	byMerchant := map[string][]text{}
+	for _, r := range reviews {
+		norm := normalizeComment(r.Comment)
+		if len([]rune(norm)) < fraudCopyPasteMinRunes {
+			continue
+		}
+		byMerchant[r.MerchantID] = append(byMerchant[r.MerchantID], text{r: r, norm: norm, shingles: shingles(norm, 3)})
+	}
+
+	found := map[int]string{}
+	mark := func(a, b text) {
+		if _, seen := found[a.r.ID]; !seen {
+			found[a.r.ID] = fmt.Sprintf("same text as review %d", b.r.ID)
+		}
+		if _, seen := found[b.r.ID]; !seen {
+			found[b.r.ID] = fmt.Sprintf("same text as review %d", a.r.ID)
+		}
+	}
+	for _, texts := range byMerchant {
+		exact := map[string][]text{}
+		for _, t := range texts {
+			exact[t.norm] = append(exact[t.norm], t)
+		}
+		// Each copy is paired with the nearest one by another author, so a
+		// repeat by the first author does not hide the rest
+		for _, ts := range exact {
+			other := 1
+			for other < len(ts) && ts[other].r.UserID == ts[0].r.UserID {
+				other++
+			}
+			if other == len(ts) {
+				continue
+			}
+			for i := range ts[:other] {
+				mark(ts[i], ts[other])
+			}
+			for i := other + 1; i < len(ts); i++ {
+				for j := i - 1; j >= 0; j-- {
+					if ts[j].r.UserID != ts[i].r.UserID {
+						mark(ts[j], ts[i])
+						break
+					}
+				}
+			}
+		}
+		for i := range texts {
+			for j := i + 1; j < len(texts); j++ {
+				a, b := texts[i], texts[j]
+				if a.r.UserID != b.r.UserID && a.norm != b.norm && jaccard(a.shingles, b.shingles) >= fraudCopyPasteSimilarity {
+					mark(a, b)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// normalizeComment keeps only lowercased letters and digits, so spacing,
+// punctuation and emoji changes do not hide a copy.
+func normalizeComment(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func shingles(s string, k int) map[string]bool {
+	runes := []rune(s)
+	set := map[string]bool{}
+	for i := 0; i+k <= len(runes); i++ {
+		set[string(runes[i:i+k])] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// fraudCallingCodes are the country codes of the markets the platform
+// serves; numbers with them are compared in national (0-prefixed) form.
+var fraudCallingCodes = []string{"886", "84"}
+
+func nationalPhone(phone string) string {
+	international := strings.HasPrefix(strings.TrimSpace(phone), "+")
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if strings.HasPrefix(digits, "00") {
+		digits, international = digits[2:], true
+	}
+	if international {
+		for _, cc := range fraudCallingCodes {
+			if rest, ok := strings.CutPrefix(digits, cc); ok {
+				return "0" + strings.TrimPrefix(rest, "0")
+			}
+		}
+	}
+	return digits
+}
+
+// sharedPhonePrefix reports whether two numbers share their leading
+// fraudPhonePrefixDigits national digits, and returns that prefix.
+func sharedPhonePrefix(a, b string) (string, bool) {
+	na, nb := nationalPhone(a), nationalPhone(b)
+	if len(na) < fraudPhonePrefixDigits || len(nb) < fraudPhonePrefixDigits {
+		return "", false
+	}
+	if na[:fraudPhonePrefixDigits] != nb[:fraudPhonePrefixDigits] {
+		return "", false
+	}
+	return na[:fraudPhonePrefixDigits], true
+}
+
+// flagSuspectedReview holds a published review for an admin, taking it out
+// of the rating aggregates. It reports false if the review changed since the
+// scan.
+func flagSuspectedReview(reviewID int, flags []models.ReviewFlag) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var cleared bool
+	err = tx.QueryRow(`SELECT status, moderated_at IS NOT NULL FROM reviews WHERE id = $1 FOR UPDATE`, reviewID).Scan(&status, &cleared)
+	if err != nil {
+		return false, err
+	}
+	if status != models.ReviewStatusPublished || cleared {
+		return false, nil
+	}
+	flagsJSON, _ := json.Marshal(flags)
+	_, err = tx.Exec(`
+		UPDATE reviews SET status = 'flagged', moderation_flags = COALESCE(moderation_flags, '[]') || $2::jsonb WHERE id = $1
+	`, reviewID, string(flagsJSON))
+	if err == nil {
+		err = reviewStatusChanged(tx, reviewID, status, models.ReviewStatusFlagged)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"food-platform-backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// REVIEW FRAUD DETECTION TESTS
+// =========================================================================
+
+func fraudReview(id int, user, merchant string, rating int, at, accountAt time.Time) fraudCandidate {
+	return fraudCandidate{ID: id, UserID: user, MerchantID: merchant, Rating: rating, Status: models.ReviewStatusPublished,
+		CreatedAt: at, AccountCreatedAt: accountAt}
+}
+
+func TestDetectBursts(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	oldAccount := t0.AddDate(-1, 0, 0)
+	reviews := []fraudCandidate{
+		fraudReview(1, "u1", "m1", 5, t0, t0.Add(-time.Hour)),
+		fraudReview(2, "u2", "m1", 5, t0.Add(3*time.Hour), t0.Add(-2*time.Hour)),
+		fraudReview(3, "u3", "m1", 5, t0.Add(20*time.Hour), t0),
+		fraudReview(4, "u4", "m1", 5, t0.Add(5*time.Hour), oldAccount),            // Established account
+		fraudReview(5, "u5", "m1", 4, t0.Add(6*time.Hour), t0),                    // Not 5★
+		fraudReview(6, "u6", "m1", 5, t0.Add(72*time.Hour), t0.Add(70*time.Hour)), // Outside the window
+		fraudReview(7, "u7", "m2", 5, t0, t0),
+		fraudReview(8, "u8", "m2", 5, t0.Add(time.Hour), t0),
+	}
+	found := detectBursts(reviews)
+	assert.Len(t, found, 3)
+	for _, id := range []int{1, 2, 3} {
+		assert.Equal(t, "3 new-account 5★ reviews within 24h0m0s", found[id])
+	}
+}
+
+func TestDetectCopyPaste(t *testing.T) {
+	t0 := time.Now()
+	r := func(id int, user, merchant, comment string) fraudCandidate {
+		c := fraudReview(id, user, merchant, 5, t0, t0)
+		c.Comment = comment
+		return c
+	}
+	reviews := []fraudCandidate{
+		r(1, "u1", "m1", "Best bakery in town, the croissants are amazing!!"),
+		r(2, "u2", "m1", "best bakery in town — the croissants are AMAZING"),          // Exact copy
+		r(3, "u3", "m1", "Best bakery in town, the croissants are amazing and cheap"), // Near copy
+		r(4, "u4", "m2", "Best bakery in town, the croissants are amazing and cheap"), // Same text, another merchant
+		r(5, "u1", "m1", "Best bakery in town, the croissants are amazing!!"),         // Same author again
+		r(6, "u6", "m1", "Great"), // Too short
+		r(7, "u7", "m1", "麵包很新鮮，店員很親切，下次還會再來買喔"),
+	}
+	found := detectCopyPaste(reviews)
+	assert.Contains(t, found, 1)
+	assert.Contains(t, found, 2)
+	assert.Contains(t, found, 3)
+	assert.Contains(t, found, 5)
+	assert.NotContains(t, found, 4)
+	assert.NotContains(t, found, 6)
+	assert.NotContains(t, found, 7)
+}
+
+func TestDetectCopyPasteAfterRepeatedAuthor(t *testing.T) {
+	t0 := time.Now()
+	r := func(id int, user string) fraudCandidate {
+		c := fraudReview(id, user, "m1", 5, t0, t0)
+		c.Comment = "Friendly staff and the bento is always fresh"
+		return c
+	}
+	// The first author posting twice must not hide the later copies
+	found := detectCopyPaste([]fraudCandidate{r(1, "u1"), r(2, "u1"), r(3, "u2"), r(4, "u3")})
+	assert.Len(t, found, 4)
+
+	found = detectCopyPaste([]fraudCandidate{r(1, "u1"), r(2, "u1")})
+	assert.Empty(t, found)
+}
+
+func TestSharedPhonePrefix(t *testing.T) {
+	assert.Equal(t, "0912345678", nationalPhone("+886 912-345-678"))
+	assert.Equal(t, "0912345678", nationalPhone("00886912345678"))
+	assert.Equal(t, "0901234567", nationalPhone("+84 90 123 4567"))
+	assert.Equal(t, "0912345678", nationalPhone("0912-345-678"))
+
+	prefix, ok := sharedPhonePrefix("+886912345001", "0912-345-999")
+	assert.True(t, ok)
+	assert.Equal(t, "0912345", prefix)
+
+	_, ok = sharedPhonePrefix("0912345001", "0912346001")
+	assert.False(t, ok)
+	_, ok = sharedPhonePrefix("", "")
+	assert.False(t, ok)
+}
+
+func TestDetectReviewFraud(t *testing.T) {
+	t0 := time.Now()
+	old := t0.AddDate(-1, 0, 0)
+	device := fraudReview(1, "u1", "m1", 5, t0, old)
+	device.SharedDevice = true
+	phone := fraudReview(2, "u2", "m1", 5, t0, old)
+	phone.ReviewerPhone, phone.MerchantPhone = "0912345001", "0912345002"
+	cleared := fraudReview(3, "u3", "m1", 5, t0, old)
+	cleared.SharedDevice, cleared.Cleared = true, true
+	flagged := fraudReview(4, "u4", "m1", 5, t0, old)
+	flagged.SharedDevice, flagged.Status = true, models.ReviewStatusFlagged
+	clean := fraudReview(5, "u5", "m1", 5, t0, old)
+
+	flags := detectReviewFraud([]fraudCandidate{device, phone, cleared, flagged, clean})
+	assert.Equal(t, []models.ReviewFlag{{Rule: fraudRuleSharedDevice, Match: "device also used by the merchant"}}, flags[1])
+	assert.Equal(t, []models.ReviewFlag{{Rule: fraudRulePhonePrefix, Match: "0912345…"}}, flags[2])
+	assert.Len(t, flags, 2)
+}
+
+func TestFlaggedReviewsDoNotCount(t *testing.T) {
+	assert.False(t, reviewCounts(models.ReviewStatusFlagged))
+	next, ok := moderationTransition(models.ReviewStatusFlagged, "restore")
+	assert.True(t, ok)
+	assert.Equal(t, models.ReviewStatusPublished, next)
+}
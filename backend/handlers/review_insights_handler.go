@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"food-platform-backend/db"
+	"food-platform-backend/insights"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Review insights are computed offline: the job re-analyses a merchant's
+// comments once they have new or re-moderated reviews, and at least daily so
+// the monthly trend rolls over and status changes are picked up.
+
+const (
+	insightsLookback        = 365 * 24 * time.Hour
+	insightsMaxReviews      = 2000
+	insightsRefreshAfter    = 24 * time.Hour
+	insightsMerchantsPerRun = 50
+)
+
+// StartReviewInsightsJob periodically refreshes merchants' review insights.
+func StartReviewInsightsJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := refreshReviewInsights(time.Now()); err != nil {
+				log.Println("Review insights:", err)
+			} else if n > 0 {
+				log.Printf("Review insights: analysed %d merchants", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func refreshReviewInsights(now time.Time) (int, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.merchant_id
+		FROM reviews r
+		LEFT JOIN merchant_review_insights i ON i.merchant_id = r.merchant_id
+		GROUP BY r.merchant_id, i.computed_at
+		HAVING i.computed_at IS NULL
+		    OR i.computed_at < $1
+		    OR MAX(GREATEST(r.created_at, COALESCE(r.moderated_at, r.created_at))) > i.computed_at
+		ORDER BY i.computed_at NULLS FIRST
+		LIMIT $2
+	`, now.Add(-insightsRefreshAfter).UTC(), insightsMerchantsPerRun)
+	if err != nil {
+		return 0, err
+	}
+	var merchantIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			merchantIDs = append(merchantIDs, id)
+		}
+	}
+	rows.Close()
+
+	analysed := 0
+	for _, id := range merchantIDs {
+		if err := analyseMerchantReviews(id, now); err != nil {
+			log.Printf("Review insights: merchant %s: %v", id, err)
+			continue
+		}
+		analysed++
+	}
+	return analysed, nil
+}
+
+// analyseMerchantReviews runs the analysis over a merchant's published
+// comments from the last year and stores the report.
+func analyseMerchantReviews(merchantID string, now time.Time) error {
+	rows, err := db.DB.Query(`
+		SELECT id, comment, created_at FROM reviews
+		WHERE merchant_id = $1 AND status = 'published' AND NULLIF(BTRIM(comment), '') IS NOT NULL AND created_at >= $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, merchantID, now.Add(-insightsLookback).UTC(), insightsMaxReviews)
+	if err != nil {
+		return err
+	}
+	var reviews []insights.Review
+	for rows.Next() {
+		var r insights.Review
+		if err := rows.Scan(&r.ID, &r.Text, &r.CreatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		reviews = append(reviews, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	report, err := json.Marshal(insights.Analyze(reviews, merchantLocation(db.DB, merchantID), now))
+	if err != nil {
+		return err
+	}
+	_, err = db.DB.Exec(`
+		INSERT INTO merchant_review_insights (merchant_id, report, computed_at) VALUES ($1, $2, $3)
+		ON CONFLICT (merchant_id) DO UPDATE SET report = EXCLUDED.report, computed_at = EXCLUDED.computed_at
+	`, merchantID, report, now.UTC())
+	return err
+}
+
+// GetMerchantInsights - GET /merchant/insights
+// What the caller's customers mention in reviews, with example snippets,
+// and the monthly sentiment trend, as of the last analysis.
+func GetMerchantInsights(c *gin.Context) {
+	merchantID := c.GetString("user_id")
+
+	var raw []byte
+	var computedAt time.Time
+	err := db.DB.QueryRow(`SELECT report, computed_at FROM merchant_review_insights WHERE merchant_id = $1`, merchantID).
+		Scan(&raw, &computedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{
+			"insights":    insights.Analyze(nil, merchantLocation(db.DB, merchantID), time.Now()),
+			"computed_at": nil,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch insights"})
+		return
+	}
+	var report insights.Report
+	if err := json.Unmarshal(raw, &report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read insights"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"insights": report, "computed_at": computedAt})
+}
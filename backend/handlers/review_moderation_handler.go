@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"food-platform-backend/moderation"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contentFilter screens review comments before they are published.
+var contentFilter moderation.Filter = moderation.Default()
+
+// SetContentFilter replaces the filter reviews are screened with.
+func SetContentFilter(f moderation.Filter) {
+	contentFilter = f
+}
+
+// defaultReviewReportThreshold is how many open reports take a published
+// review down until an admin looks at it, overridable with
+// REVIEW_REPORT_THRESHOLD.
+const defaultReviewReportThreshold = 3
+
+func reviewReportThreshold() int {
+	if v, err := strconv.Atoi(os.Getenv("REVIEW_REPORT_THRESHOLD")); err == nil && v > 0 {
+		return v
+	}
+	return defaultReviewReportThreshold
+}
+
+var validReportReasons = map[string]bool{
+	models.ReportReasonSpam:         true,
+	models.ReportReasonOffensive:    true,
+	models.ReportReasonPersonalInfo: true,
+	models.ReportReasonFake:         true,
+	models.ReportReasonOffTopic:     true,
+	models.ReportReasonOther:        true,
+}
+
+// screenReview runs a comment through the content filter and returns the
+// status the review starts in and the flags to store with it.
+func screenReview(comment string) (string, []models.ReviewFlag) {
+	flags := []models.ReviewFlag{}
+	for _, f := range contentFilter.Check(comment) {
+		flags = append(flags, models.ReviewFlag{Rule: f.Rule, Lang: f.Lang, Match: f.Match})
+	}
+	if len(flags) > 0 {
+		return models.ReviewStatusPending, flags
+	}
+	return models.ReviewStatusPublished, flags
+}
+
+// moderationTransition returns the status an admin action moves a review
+// to, or false if the action does not apply to a review in status.
+func moderationTransition(status, action string) (string, bool) {
+	switch {
+	case status == models.ReviewStatusDeleted:
+		return "", false
+	case action == "hide" && status != models.ReviewStatusHidden:
+		return models.ReviewStatusHidden, true
+	case action == "restore" && status != models.ReviewStatusPublished:
+		return models.ReviewStatusPublished, true
+	case action == "delete":
+		return models.ReviewStatusDeleted, true
+	}
+	return "", false
+}
+
+// =========================================================================
+// REVIEW REPORTS
+// =========================================================================
+
+// ReportReview - POST /reviews/:id/report
+// Any signed-in user, including the reviewed merchant, can report a review
+// once. Enough open reports hold a published review for moderation.
+func ReportReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var input struct {
+		ReasonCode string `json:"reason_code" binding:"required"`
+		Details    string `json:"details" binding:"max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || !validReportReasons[input.ReasonCode] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valid reason_code required"})
+		return
+	}
+	reporterID := c.GetString("user_id")
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	var authorID, status string
+	err = tx.QueryRow(`SELECT user_id, COALESCE(status, 'published') FROM reviews WHERE id = $1 FOR UPDATE`, reviewID).
+		Scan(&authorID, &status)
+	if err == sql.ErrNoRows || (err == nil && status != models.ReviewStatusPublished && status != models.ReviewStatusPending) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if authorID == reporterID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report your own review"})
+		return
+	}
+
+	var report models.ReviewReport
+	err = tx.QueryRow(`
+		INSERT INTO review_reports (review_id, reporter_id, reason_code, details)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (review_id, reporter_id) DO NOTHING
+		RETURNING id, status, created_at
+	`, reviewID, reporterID, input.ReasonCode, strings.TrimSpace(input.Details)).Scan(&report.ID, &report.Status, &report.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already reported this review"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report review"})
+		return
+	}
+
+	if status == models.ReviewStatusPublished {
+		var open int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM review_reports WHERE review_id = $1 AND status = 'open'`, reviewID).Scan(&open); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if open >= reviewReportThreshold() {
+			_, err := tx.Exec(`UPDATE reviews SET status = 'pending' WHERE id = $1`, reviewID)
+			if err == nil {
+				err = reviewStatusChanged(tx, reviewID, status, models.ReviewStatusPending)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report review"})
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	report.ReviewID, report.ReporterID, report.ReasonCode, report.Details = reviewID, reporterID, input.ReasonCode, strings.TrimSpace(input.Details)
+	c.JSON(http.StatusCreated, report)
+}
+
+// =========================================================================
+// ADMIN MODERATION QUEUE
+// =========================================================================
+
+// GetModerationQueue - GET /admin/reviews/moderation?status=&page=&page_size=
+// Without a status, lists reviews awaiting moderation or flagged as possible
+// manipulation, with open reports or with photos awaiting moderation, oldest
+// first.
+func GetModerationQueue(c *gin.Context) {
+	where := `(r.status IN ('pending', 'flagged')
+		OR EXISTS (SELECT 1 FROM review_reports rr WHERE rr.review_id = r.id AND rr.status = 'open')
+		OR EXISTS (SELECT 1 FROM review_photos p WHERE p.review_id = r.id AND p.status = 'pending'))`
+	args := []interface{}{}
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case models.ReviewStatusPublished, models.ReviewStatusPending, models.ReviewStatusFlagged, models.ReviewStatusHidden, models.ReviewStatusDeleted:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+			return
+		}
+		where = "r.status = $1"
+		args = append(args, status)
+	}
+
+	page, pageSize := parsePagination(c)
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := db.DB.Query(`
+		SELECT r.id, r.order_id, r.user_id, r.merchant_id, r.rating, COALESCE(r.comment, ''), r.created_at,
+		       r.status, COALESCE(r.moderation_flags, '[]'), COALESCE(r.moderation_reason, ''), r.moderated_at,
+		       (SELECT COUNT(*) FROM review_reports rr WHERE rr.review_id = r.id AND rr.status = 'open'),
+		       (SELECT COUNT(*) FROM review_photos p WHERE p.review_id = r.id AND p.status = 'pending')
+		FROM reviews r
+		WHERE `+where+`
+		ORDER BY r.created_at, r.id
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch moderation queue"})
+		return
+	}
+	items := []models.ModerationItem{}
+	for rows.Next() {
+		var item models.ModerationItem
+		var flags []byte
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.UserID, &item.MerchantID, &item.Rating, &item.Comment, &item.CreatedAt,
+			&item.Status, &flags, &item.ModerationReason, &item.ModeratedAt, &item.OpenReports, &item.PendingPhotos); err != nil {
+			continue
+		}
+		item.Flags = []models.ReviewFlag{}
+		json.Unmarshal(flags, &item.Flags)
+		items = append(items, item)
+	}
+	rows.Close()
+
+	ids := make([]int, len(items))
+	for i := range items {
+		items[i].Reports = loadReviewReports(items[i].ID)
+		ids[i] = items[i].ID
+	}
+	photos, err := loadReviewPhotos(db.DB, ids, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review photos"})
+		return
+	}
+	for i := range items {
+		items[i].Photos = photos[items[i].ID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reviews": items, "page": page, "page_size": pageSize})
+}
+
+func loadReviewReports(reviewID int) []models.ReviewReport {
+	reports := []models.ReviewReport{}
+	rows, err := db.DB.Query(`
+		SELECT id, review_id, reporter_id, reason_code, COALESCE(details, ''), status, created_at
+		FROM review_reports WHERE review_id = $1 ORDER BY id
+	`, reviewID)
+	if err != nil {
+		return reports
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.ReviewReport
+		if err := rows.Scan(&r.ID, &r.ReviewID, &r.ReporterID, &r.ReasonCode, &r.Details, &r.Status, &r.CreatedAt); err == nil {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+// ModerateReview - PUT /admin/reviews/:id/moderation
+// Hides, restores or deletes a review. Open reports are resolved (or
+// dismissed on restore) and the author is told why.
+func ModerateReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=hide restore delete"`
+		Reason string `json:"reason" binding:"max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Action must be hide, restore or delete"})
+		return
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if input.Action != "restore" && reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to hide or delete a review"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	var authorID, status, shopName string
+	err = tx.QueryRow(`
+		SELECT r.user_id, COALESCE(r.status, 'published'), COALESCE(m.shop_name, '')
+		FROM reviews r
+		LEFT JOIN merchants m ON m.user_id = r.merchant_id
+		WHERE r.id = $1
+		FOR UPDATE OF r
+	`, reviewID).Scan(&authorID, &status, &shopName)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	next, ok := moderationTransition(status, input.Action)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot %s a %s review", input.Action, status)})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reviews SET status = $2, moderation_reason = NULLIF($3, ''), moderated_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, reviewID, next, reason)
+	if err == nil {
+		err = reviewStatusChanged(tx, reviewID, status, next)
+	}
+	if err == nil {
+		reportStatus := "resolved"
+		if next == models.ReviewStatusPublished {
+			reportStatus = "dismissed"
+		}
+		_, err = tx.Exec(`UPDATE review_reports SET status = $2 WHERE review_id = $1 AND status = 'open'`, reviewID, reportStatus)
+	}
+	var photoKeys []string
+	if err == nil && next == models.ReviewStatusDeleted {
+		photoKeys, err = deleteReviewPhotoRows(tx, reviewID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate review"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	deleteStoredImages(photoKeys...)
+
+	if shopName == "" {
+		shopName = "a shop"
+	}
+	switch next {
+	case models.ReviewStatusHidden:
+		notifyUser(authorID, "Review hidden", fmt.Sprintf("Your review of %s was hidden. Reason: %s", shopName, reason), "review_moderation")
+	case models.ReviewStatusDeleted:
+		notifyUser(authorID, "Review removed", fmt.Sprintf("Your review of %s was removed. Reason: %s", shopName, reason), "review_moderation")
+	case models.ReviewStatusPublished:
+		body := fmt.Sprintf("Your review of %s is visible again.", shopName)
+		if reason != "" {
+			body += " Note: " + reason
+		}
+		notifyUser(authorID, "Review published", body, "review_moderation")
+	}
+	c.JSON(http.StatusOK, gin.H{"review_id": reviewID, "status": next})
+}
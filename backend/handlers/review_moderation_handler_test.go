@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"food-platform-backend/models"
+	"food-platform-backend/moderation"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// REVIEW MODERATION TESTS
+// =========================================================================
+
+func TestScreenReview(t *testing.T) {
+	status, flags := screenReview("Fresh bread, friendly staff. 麵包很新鮮！")
+	assert.Equal(t, models.ReviewStatusPublished, status)
+	assert.Empty(t, flags)
+
+	status, flags = screenReview("Cheaper at www.example.com, call 0912-345-678")
+	assert.Equal(t, models.ReviewStatusPending, status)
+	assert.Equal(t, []models.ReviewFlag{
+		{Rule: moderation.RulePhone, Match: "0912-345-678"},
+		{Rule: moderation.RuleURL, Match: "www.example.com"},
+	}, flags)
+}
+
+func TestSetContentFilter(t *testing.T) {
+	defer SetContentFilter(contentFilter)
+	SetContentFilter(moderation.NewWordList("en", []string{"soggy"}))
+	status, flags := screenReview("Soggy rice")
+	assert.Equal(t, models.ReviewStatusPending, status)
+	assert.Equal(t, "en", flags[0].Lang)
+}
+
+func TestModerationTransition(t *testing.T) {
+	cases := []struct {
+		status, action, next string
+		ok                   bool
+	}{
+		{models.ReviewStatusPublished, "hide", models.ReviewStatusHidden, true},
+		{models.ReviewStatusPending, "hide", models.ReviewStatusHidden, true},
+		{models.ReviewStatusHidden, "hide", "", false},
+		{models.ReviewStatusPending, "restore", models.ReviewStatusPublished, true},
+		{models.ReviewStatusHidden, "restore", models.ReviewStatusPublished, true},
+		{models.ReviewStatusPublished, "restore", "", false},
+		{models.ReviewStatusHidden, "delete", models.ReviewStatusDeleted, true},
+		{models.ReviewStatusDeleted, "restore", "", false},
+		{models.ReviewStatusDeleted, "delete", "", false},
+	}
+	for _, tc := range cases {
+		next, ok := moderationTransition(tc.status, tc.action)
+		assert.Equal(t, tc.ok, ok, tc.status+" "+tc.action)
+		assert.Equal(t, tc.next, next, tc.status+" "+tc.action)
+	}
+}
+
+func TestReviewModerationValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/reviews/:id/report", ReportReview)
+	r.GET("/admin/reviews/moderation", GetModerationQueue)
+	r.PUT("/admin/reviews/:id/moderation", ModerateReview)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{"POST", "/reviews/abc/report", `{"reason_code":"spam"}`},
+		{"POST", "/reviews/1/report", `{}`},
+		{"POST", "/reviews/1/report", `{"reason_code":"boring"}`},
+		{"GET", "/admin/reviews/moderation?status=archived", ""},
+		{"PUT", "/admin/reviews/abc/moderation", `{"action":"hide","reason":"Abusive"}`},
+		{"PUT", "/admin/reviews/1/moderation", `{"action":"archive"}`},
+		{"PUT", "/admin/reviews/1/moderation", `{"action":"hide"}`},
+		{"PUT", "/admin/reviews/1/moderation", `{"action":"delete","reason":"  "}`},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.method+" "+tc.path+" "+tc.body)
+	}
+}
@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/media"
+	"food-platform-backend/models"
+	"food-platform-backend/moderation"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// imageStore keeps uploaded images; imageFilter screens them before they
+// are published.
+var (
+	imageStore  media.Store            = media.FromEnv()
+	imageFilter moderation.ImageFilter = moderation.AllowImages{}
+)
+
+// SetImageStore replaces where uploaded images are stored.
+func SetImageStore(s media.Store) {
+	imageStore = s
+}
+
+// SetImageFilter replaces the filter uploaded images are screened with.
+func SetImageFilter(f moderation.ImageFilter) {
+	imageFilter = f
+}
+
+// defaultReviewMaxPhotos is how many photos a review can carry, overridable
+// with REVIEW_MAX_PHOTOS.
+const defaultReviewMaxPhotos = 5
+
+func reviewMaxPhotos() int {
+	if v, err := strconv.Atoi(os.Getenv("REVIEW_MAX_PHOTOS")); err == nil && v > 0 {
+		return v
+	}
+	return defaultReviewMaxPhotos
+}
+
+// screenPhoto runs an image through the image filter and returns the status
+// the photo starts in and the flags to store with it.
+func screenPhoto(img []byte) (string, []models.ReviewFlag) {
+	flags := []models.ReviewFlag{}
+	for _, f := range imageFilter.CheckImage(img) {
+		flags = append(flags, models.ReviewFlag{Rule: f.Rule, Lang: f.Lang, Match: f.Match})
+	}
+	if len(flags) > 0 {
+		return models.ReviewStatusPending, flags
+	}
+	return models.ReviewStatusPublished, flags
+}
+
+// photoUploadError explains why an uploaded file was rejected.
+func photoUploadError(name string, err error) string {
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return fmt.Sprintf("%s is larger than %d MB", name, media.MaxUploadBytes>>20)
+	case errors.Is(err, media.ErrUnsupportedFormat):
+		return fmt.Sprintf("%s is not a JPEG, PNG or GIF image", name)
+	case errors.Is(err, media.ErrBadDimensions):
+		return fmt.Sprintf("%s must be at least %dx%d pixels and at most %d megapixels", name, media.MinDimension, media.MinDimension, media.MaxSourcePixels/1_000_000)
+	}
+	return fmt.Sprintf("%s could not be processed", name)
+}
+
+func newImageKey(reviewID int) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("reviews/%d/%s", reviewID, hex.EncodeToString(b))
+}
+
+// storedPhoto is an uploaded photo written to the image store but not yet
+// recorded.
+type storedPhoto struct {
+	models.ReviewPhoto
+	imageKey, thumbnailKey string
+}
+
+func deleteStoredImages(keys ...string) {
+	for _, key := range keys {
+		if err := imageStore.Delete(context.Background(), key); err != nil {
+			log.Printf("review photos: delete %s: %v", key, err)
+		}
+	}
+}
+
+func discardStoredPhotos(photos []storedPhoto) {
+	for _, p := range photos {
+		deleteStoredImages(p.imageKey, p.thumbnailKey)
+	}
+}
+
+// =========================================================================
+// UPLOAD & DELETE
+// =========================================================================
+
+// UploadReviewPhotos - POST /reviews/:id/photos (multipart, field "photos")
+// The author attaches photos to their review. Each is validated, re-encoded
+// and thumbnailed; photos the image filter flags wait for an admin.
+func UploadReviewPhotos(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	maxPhotos := reviewMaxPhotos()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxPhotos)*media.MaxUploadBytes+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multipart form with photos required"})
+		return
+	}
+	files := form.File["photos"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one photo required"})
+		return
+	}
+	if len(files) > maxPhotos {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A review can have at most %d photos", maxPhotos)})
+		return
+	}
+	userID := c.GetString("user_id")
+
+	// Check ownership before spending time on the images; the count is
+	// checked again under lock below
+	var authorID, status string
+	var existing int
+	err = db.DB.QueryRow(`
+		SELECT r.user_id, COALESCE(r.status, 'published'),
+		       (SELECT COUNT(*) FROM review_photos p WHERE p.review_id = r.id AND p.status <> 'deleted')
+		FROM reviews r WHERE r.id = $1
+	`, reviewID).Scan(&authorID, &status, &existing)
+	if err == sql.ErrNoRows || (err == nil && (authorID != userID || status == models.ReviewStatusDeleted)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if status == models.ReviewStatusHidden {
+		c.JSON(http.StatusConflict, gin.H{"error": "Photos cannot be added to a hidden review"})
+		return
+	}
+	if existing+len(files) > maxPhotos {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A review can have at most %d photos", maxPhotos)})
+		return
+	}
+
+	var stored []storedPhoto
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			discardStoredPhotos(stored)
+			c.JSON(http.StatusBadRequest, gin.H{"error": photoUploadError(fh.Filename, err)})
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(f, media.MaxUploadBytes+1))
+		f.Close()
+		if err != nil {
+			discardStoredPhotos(stored)
+			c.JSON(http.StatusBadRequest, gin.H{"error": photoUploadError(fh.Filename, err)})
+			return
+		}
+		img, err := media.Process(data)
+		if err != nil {
+			discardStoredPhotos(stored)
+			c.JSON(http.StatusBadRequest, gin.H{"error": photoUploadError(fh.Filename, err)})
+			return
+		}
+
+		p := storedPhoto{ReviewPhoto: models.ReviewPhoto{ReviewID: reviewID, Width: img.Width, Height: img.Height}}
+		key := newImageKey(reviewID)
+		p.imageKey, p.thumbnailKey = key+".jpg", key+"_thumb.jpg"
+		p.Status, p.Flags = screenPhoto(img.Full)
+		if p.URL, err = imageStore.Put(c.Request.Context(), p.imageKey, img.Full, img.ContentType); err == nil {
+			p.ThumbnailURL, err = imageStore.Put(c.Request.Context(), p.thumbnailKey, img.Thumb, img.ContentType)
+		}
+		stored = append(stored, p)
+		if err != nil {
+			discardStoredPhotos(stored)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store photo"})
+			return
+		}
+	}
+
+	photos, err := recordReviewPhotos(reviewID, userID, stored, maxPhotos)
+	if err != nil {
+		discardStoredPhotos(stored)
+		if err == errTooManyPhotos {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A review can have at most %d photos", maxPhotos)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photos"})
+		return
+	}
+
+	pending := 0
+	for _, p := range photos {
+		if p.Status == models.ReviewStatusPending {
+			pending++
+		}
+	}
+	resp := gin.H{"photos": photos}
+	if pending > 0 {
+		resp["message"] = fmt.Sprintf("%d photo(s) will appear after moderation", pending)
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+var errTooManyPhotos = errors.New("too many photos")
+
+// recordReviewPhotos inserts stored photos, locking the review so concurrent
+// uploads cannot exceed maxPhotos between them.
+func recordReviewPhotos(reviewID int, userID string, stored []storedPhoto, maxPhotos int) ([]models.ReviewPhoto, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	if _, err := tx.Exec(`SELECT 1 FROM reviews WHERE id = $1 FOR UPDATE`, reviewID); err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM review_photos WHERE review_id = $1 AND status <> 'deleted'`, reviewID).Scan(&existing); err != nil {
+		return nil, err
+	}
+	if existing+len(stored) > maxPhotos {
+		return nil, errTooManyPhotos
+	}
+
+	photos := make([]models.ReviewPhoto, 0, len(stored))
+	for _, p := range stored {
+		flags, _ := json.Marshal(p.Flags)
+		err := tx.QueryRow(`
+			INSERT INTO review_photos (review_id, user_id, image_key, thumbnail_key, url, thumbnail_url, width, height, status, moderation_flags)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id, created_at
+		`, reviewID, userID, p.imageKey, p.thumbnailKey, p.URL, p.ThumbnailURL, p.Width, p.Height, p.Status, flags).Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, p.ReviewPhoto)
+	}
+	return photos, tx.Commit()
+}
+
+// DeleteReviewPhoto - DELETE /reviews/:id/photos/:photo_id
+// The author removes one of their photos; its files are deleted.
+func DeleteReviewPhoto(c *gin.Context) {
+	reviewID, err1 := strconv.Atoi(c.Param("id"))
+	photoID, err2 := strconv.Atoi(c.Param("photo_id"))
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+	var imageKey, thumbnailKey string
+	err := db.DB.QueryRow(`
+		UPDATE review_photos SET status = 'deleted'
+		WHERE id = $1 AND review_id = $2 AND user_id = $3 AND status <> 'deleted'
+		RETURNING image_key, thumbnail_key
+	`, photoID, reviewID, c.GetString("user_id")).Scan(&imageKey, &thumbnailKey)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+		return
+	}
+	deleteStoredImages(imageKey, thumbnailKey)
+	c.JSON(http.StatusOK, gin.H{"message": "Photo deleted"})
+}
+
+// deleteReviewPhotoRows marks every photo of a deleted review deleted and
+// returns the keys of their files, to remove once tx commits.
+func deleteReviewPhotoRows(tx *sql.Tx, reviewID int) ([]string, error) {
+	rows, err := tx.Query(`
+		UPDATE review_photos SET status = 'deleted' WHERE review_id = $1 AND status <> 'deleted'
+		RETURNING image_key, thumbnail_key
+	`, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var imageKey, thumbnailKey string
+		if err := rows.Scan(&imageKey, &thumbnailKey); err != nil {
+			return nil, err
+		}
+		keys = append(keys, imageKey, thumbnailKey)
+	}
+	return keys, rows.Err()
+}
+
+// loadReviewPhotos returns the photos of reviews by review ID: published
+// ones only for public listings, otherwise everything but deleted ones.
+func loadReviewPhotos(q queryer, reviewIDs []int, publicOnly bool) (map[int][]models.ReviewPhoto, error) {
+	photos := map[int][]models.ReviewPhoto{}
+	if len(reviewIDs) == 0 {
+		return photos, nil
+	}
+	statuses := "status <> 'deleted'"
+	if publicOnly {
+		statuses = "status = 'published'"
+	}
+	rows, err := q.Query(`
+		SELECT id, review_id, url, thumbnail_url, width, height, status, COALESCE(moderation_flags, '[]'), created_at
+		FROM review_photos WHERE review_id = ANY($1) AND `+statuses+`
+		ORDER BY review_id, id
+	`, pq.Array(reviewIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.ReviewPhoto
+		var flags []byte
+		if err := rows.Scan(&p.ID, &p.ReviewID, &p.URL, &p.ThumbnailURL, &p.Width, &p.Height, &p.Status, &flags, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		if publicOnly {
+			p.Status = ""
+		} else {
+			json.Unmarshal(flags, &p.Flags)
+		}
+		photos[p.ReviewID] = append(photos[p.ReviewID], p)
+	}
+	return photos, rows.Err()
+}
+
+// =========================================================================
+// ADMIN PHOTO MODERATION
+// =========================================================================
+
+// ModerateReviewPhoto - PUT /admin/reviews/photos/:id/moderation
+// Hides, restores or deletes a single photo without touching its review.
+func ModerateReviewPhoto(c *gin.Context) {
+	photoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=hide restore delete"`
+		Reason string `json:"reason" binding:"max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Action must be hide, restore or delete"})
+		return
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if input.Action != "restore" && reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to hide or delete a photo"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	var authorID, status, imageKey, thumbnailKey string
+	err = tx.QueryRow(`
+		SELECT user_id, COALESCE(status, 'published'), image_key, thumbnail_key FROM review_photos WHERE id = $1 FOR UPDATE
+	`, photoID).Scan(&authorID, &status, &imageKey, &thumbnailKey)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	next, ok := moderationTransition(status, input.Action)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot %s a %s photo", input.Action, status)})
+		return
+	}
+	_, err = tx.Exec(`
+		UPDATE review_photos SET status = $2, moderation_reason = NULLIF($3, ''), moderated_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, photoID, next, reason)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate photo"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	switch next {
+	case models.ReviewStatusHidden:
+		notifyUser(authorID, "Review photo hidden", "A photo on your review was hidden. Reason: "+reason, "review_moderation")
+	case models.ReviewStatusDeleted:
+		deleteStoredImages(imageKey, thumbnailKey)
+		notifyUser(authorID, "Review photo removed", "A photo on your review was removed. Reason: "+reason, "review_moderation")
+	case models.ReviewStatusPublished:
+		notifyUser(authorID, "Review photo published", "A photo on your review is now visible.", "review_moderation")
+	}
+	c.JSON(http.StatusOK, gin.H{"photo_id": photoID, "status": next})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"food-platform-backend/media"
+	"food-platform-backend/models"
+	"food-platform-backend/moderation"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// REVIEW PHOTO TESTS
+// =========================================================================
+
+type flagAllImages struct{}
+
+func (flagAllImages) CheckImage([]byte) []moderation.Finding {
+	return []moderation.Finding{{Rule: "nudity", Match: "0.97"}}
+}
+
+func TestScreenPhoto(t *testing.T) {
+	status, flags := screenPhoto([]byte("jpeg"))
+	assert.Equal(t, models.ReviewStatusPublished, status)
+	assert.Empty(t, flags)
+
+	defer SetImageFilter(imageFilter)
+	SetImageFilter(flagAllImages{})
+	status, flags = screenPhoto([]byte("jpeg"))
+	assert.Equal(t, models.ReviewStatusPending, status)
+	assert.Equal(t, []models.ReviewFlag{{Rule: "nudity", Match: "0.97"}}, flags)
+}
+
+func TestReviewMaxPhotos(t *testing.T) {
+	os.Unsetenv("REVIEW_MAX_PHOTOS")
+	assert.Equal(t, defaultReviewMaxPhotos, reviewMaxPhotos())
+	t.Setenv("REVIEW_MAX_PHOTOS", "2")
+	assert.Equal(t, 2, reviewMaxPhotos())
+	t.Setenv("REVIEW_MAX_PHOTOS", "0")
+	assert.Equal(t, defaultReviewMaxPhotos, reviewMaxPhotos())
+}
+
+func TestPhotoUploadError(t *testing.T) {
+	assert.Equal(t, "a.heic is not a JPEG, PNG or GIF image", photoUploadError("a.heic", media.ErrUnsupportedFormat))
+	assert.Equal(t, "b.jpg is larger than 8 MB", photoUploadError("b.jpg", media.ErrTooLarge))
+	assert.Contains(t, photoUploadError("c.png", fmt.Errorf("%w: 10x10", media.ErrBadDimensions)), "at least 64x64")
+	assert.Equal(t, "d.jpg could not be processed", photoUploadError("d.jpg", errors.New("read")))
+}
+
+func photoUpload(t *testing.T, reviewID string, files int) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for i := 0; i < files; i++ {
+		part, _ := w.CreateFormFile("photos", fmt.Sprintf("%d.jpg", i))
+		part.Write([]byte("jpeg"))
+	}
+	w.Close()
+
+	r := gin.New()
+	r.POST("/reviews/:id/photos", UploadReviewPhotos)
+	req := httptest.NewRequest(http.MethodPost, "/reviews/"+reviewID+"/photos", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUploadReviewPhotosValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("REVIEW_MAX_PHOTOS", "2")
+
+	assert.Equal(t, http.StatusBadRequest, photoUpload(t, "abc", 1).Code)
+	assert.Equal(t, http.StatusBadRequest, photoUpload(t, "1", 0).Code)
+
+	rec := photoUpload(t, "1", 3)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "at most 2 photos")
+
+	r := gin.New()
+	r.POST("/reviews/:id/photos", UploadReviewPhotos)
+	req := httptest.NewRequest(http.MethodPost, "/reviews/1/photos", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestModerateReviewPhotoValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/admin/reviews/photos/:id/moderation", ModerateReviewPhoto)
+
+	cases := []struct {
+		id, body string
+	}{
+		{"x", `{"action":"hide","reason":"nudity"}`},
+		{"1", `{"action":"publish"}`},
+		{"1", `{"action":"delete","reason":"  "}`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/admin/reviews/photos/"+tc.id+"/moderation", bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.body)
+	}
+}
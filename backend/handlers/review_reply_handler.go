@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =========================================================================
+// REVIEW REPLIES
+// =========================================================================
+
+// ReplyToReview - PUT /merchant/reviews/:id/reply
+// Creates or edits the merchant's one public reply to a review of their
+// shop. The reviewer is notified of the first reply, not of edits.
+func ReplyToReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var input struct {
+		Body string `json:"body" binding:"required,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Body) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reply body required (max 1000 characters)"})
+		return
+	}
+
+	var userID, shopName string
+	reply := models.ReviewReply{Body: strings.TrimSpace(input.Body)}
+	err = db.DB.QueryRow(`
+		UPDATE reviews r SET
+			reply = $3,
+			replied_at = COALESCE(r.replied_at, CURRENT_TIMESTAMP),
+			reply_updated_at = CASE WHEN r.replied_at IS NULL THEN NULL ELSE CURRENT_TIMESTAMP END
+		WHERE r.id = $1 AND r.merchant_id = $2
+		RETURNING r.user_id, r.replied_at, r.reply_updated_at,
+		          COALESCE((SELECT shop_name FROM merchants WHERE user_id = r.merchant_id), '')
+	`, reviewID, c.GetString("user_id"), reply.Body).Scan(&userID, &reply.CreatedAt, &reply.UpdatedAt, &shopName)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reply"})
+		return
+	}
+
+	if reply.UpdatedAt == nil {
+		if shopName == "" {
+			shopName = "The shop"
+		}
+		notifyUser(userID, "The shop replied", fmt.Sprintf("%s replied to your review: %s", shopName, reply.Body), "review_reply")
+	}
+	c.JSON(http.StatusOK, gin.H{"review_id": reviewID, "reply": reply})
+}
+
+// DeleteReviewReply - DELETE /merchant/reviews/:id/reply
+func DeleteReviewReply(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	res, err := db.DB.Exec(`
+		UPDATE reviews SET reply = NULL, replied_at = NULL, reply_updated_at = NULL
+		WHERE id = $1 AND merchant_id = $2 AND reply IS NOT NULL
+	`, reviewID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reply"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reply not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Reply deleted"})
+}
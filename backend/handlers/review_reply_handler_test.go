@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// REVIEW REPLY TESTS
+// =========================================================================
+
+func TestReviewReplyValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/merchant/reviews/:id/reply", ReplyToReview)
+	r.DELETE("/merchant/reviews/:id/reply", DeleteReviewReply)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{"PUT", "/merchant/reviews/abc/reply", `{"body":"Thanks!"}`},
+		{"PUT", "/merchant/reviews/1/reply", `{}`},
+		{"PUT", "/merchant/reviews/1/reply", `{"body":"   "}`},
+		{"PUT", "/merchant/reviews/1/reply", `{"body":"` + string(bytes.Repeat([]byte("a"), 1001)) + `"}`},
+		{"DELETE", "/merchant/reviews/abc/reply", ""},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.method+" "+tc.path)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// helpfulnessZ is the z-score of the 95% confidence level used to rank
+// reviews by helpfulness.
+const helpfulnessZ = 1.96
+
+// helpfulnessScore is the lower bound of the Wilson score interval for the
+// share of helpful votes, so 40 of 50 outranks 2 of 2 and a review nobody
+// voted on scores 0.
+func helpfulnessScore(helpful, notHelpful int) float64 {
+	n := float64(helpful + notHelpful)
+	if n == 0 {
+		return 0
+	}
+	p := float64(helpful) / n
+	z2 := helpfulnessZ * helpfulnessZ
+	return (p + z2/(2*n) - helpfulnessZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
+
+// VoteReview - PUT /reviews/:id/vote
+// Records the caller's helpful/not-helpful vote, replacing any earlier one.
+// Authors and the reviewed merchant cannot vote.
+func VoteReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	var input struct {
+		Helpful *bool `json:"helpful" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "helpful (true or false) required"})
+		return
+	}
+	saveReviewVote(c, reviewID, input.Helpful)
+}
+
+// WithdrawReviewVote - DELETE /reviews/:id/vote
+func WithdrawReviewVote(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	saveReviewVote(c, reviewID, nil)
+}
+
+// saveReviewVote sets (helpful non-nil) or removes the caller's vote and
+// refreshes the review's counts under the review's row lock.
+func saveReviewVote(c *gin.Context, reviewID int, helpful *bool) {
+	userID := c.GetString("user_id")
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	var authorID, merchantID, status string
+	err = tx.QueryRow(`SELECT user_id, merchant_id, COALESCE(status, 'published') FROM reviews WHERE id = $1 FOR UPDATE`, reviewID).
+		Scan(&authorID, &merchantID, &status)
+	if err == sql.ErrNoRows || (err == nil && status != models.ReviewStatusPublished) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if userID == authorID || userID == merchantID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot vote on this review"})
+		return
+	}
+
+	if helpful != nil {
+		_, err = tx.Exec(`
+			INSERT INTO review_votes (review_id, user_id, helpful) VALUES ($1, $2, $3)
+			ON CONFLICT (review_id, user_id) DO UPDATE SET helpful = EXCLUDED.helpful, updated_at = CURRENT_TIMESTAMP
+		`, reviewID, userID, *helpful)
+	} else {
+		_, err = tx.Exec(`DELETE FROM review_votes WHERE review_id = $1 AND user_id = $2`, reviewID, userID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+
+	var up, down int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE helpful), COUNT(*) FILTER (WHERE NOT helpful) FROM review_votes WHERE review_id = $1
+	`, reviewID).Scan(&up, &down)
+	if err == nil {
+		_, err = tx.Exec(`UPDATE reviews SET helpful_count = $2, not_helpful_count = $3, helpful_score = $4 WHERE id = $1`,
+			reviewID, up, down, helpfulnessScore(up, down))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save vote"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"review_id":         reviewID,
+		"helpful":           helpful,
+		"helpful_count":     up,
+		"not_helpful_count": down,
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// REVIEW VOTE TESTS
+// =========================================================================
+
+func TestHelpfulnessScore(t *testing.T) {
+	assert.Equal(t, 0.0, helpfulnessScore(0, 0))
+	assert.Greater(t, helpfulnessScore(40, 10), helpfulnessScore(2, 0))
+	assert.Greater(t, helpfulnessScore(10, 0), helpfulnessScore(10, 5))
+	assert.Greater(t, helpfulnessScore(1, 0), helpfulnessScore(0, 1))
+	assert.InDelta(t, 0.207, helpfulnessScore(1, 0), 0.001)
+	assert.Less(t, helpfulnessScore(1000, 0), 1.0)
+}
+
+func TestVoteReviewValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/reviews/:id/vote", VoteReview)
+	r.DELETE("/reviews/:id/vote", WithdrawReviewVote)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{http.MethodPut, "/reviews/x/vote", `{"helpful":true}`},
+		{http.MethodPut, "/reviews/1/vote", `{}`},
+		{http.MethodPut, "/reviews/1/vote", `{"helpful":"yes"}`},
+		{http.MethodDelete, "/reviews/x/vote", ``},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.method+" "+tc.path+" "+tc.body)
+	}
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"fmt"
+	"food-platform-backend/db"
+	"food-platform-backend/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// =========================================================================
+// RECURRING LISTING SCHEDULES
+// =========================================================================
+
+// CreateListingSchedule - POST /merchant/schedules
+func CreateListingSchedule(c *gin.Context) {
+	var input struct {
+		Name          string  `json:"name" binding:"required"`
+		OriginalPrice float64 `json:"original_price" binding:"required"`
+		CurrentPrice  float64 `json:"current_price" binding:"required"`
+		Quantity      int     `json:"quantity" binding:"required,min=1,max=50"`
+		Weekdays      []int64 `json:"weekdays" binding:"required,min=1,dive,min=0,max=6"`
+		PublishTime   string  `json:"publish_time" binding:"required"`
+		ExpiryMinutes int     `json:"expiry_minutes" binding:"required"`
+		Latitude      float64 `json:"latitude" binding:"required"`
+		Longitude     float64 `json:"longitude" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hm, err := time.Parse("15:04", input.PublishTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publish_time must be HH:MM"})
+		return
+	}
+	if !requireMerchant(c) {
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	// Every occurrence is published through CreateProduct's rules, so reject
+	// schedules that could never produce a valid listing up front
+	v, err := newListingValidator(db.DB, merchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	today := time.Now().In(v.loc)
+	publishAt := time.Date(today.Year(), today.Month(), today.Day(), hm.Hour(), hm.Minute(), 0, 0, v.loc)
+	if fieldErrs := v.Validate(productInput{
+		MerchantID:    merchantID,
+		Name:          input.Name,
+		OriginalPrice: input.OriginalPrice,
+		CurrentPrice:  input.CurrentPrice,
+		ExpiryMinutes: input.ExpiryMinutes,
+		Latitude:      input.Latitude,
+		Longitude:     input.Longitude,
+	}, publishAt); len(fieldErrs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": fieldErrs})
+		return
+	}
+
+	var scheduleID int
+	err = db.DB.QueryRow(`
+		INSERT INTO listing_schedules (merchant_id, name, original_price, current_price, quantity, weekdays, publish_time, expiry_minutes, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, merchantID, input.Name, input.OriginalPrice, input.CurrentPrice, input.Quantity, pq.Array(input.Weekdays),
+		input.PublishTime, input.ExpiryMinutes, input.Latitude, input.Longitude).Scan(&scheduleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Schedule created", "id": scheduleID})
+}
+
+// GetListingSchedules - GET /merchant/schedules
+func GetListingSchedules(c *gin.Context) {
+	if !requireMerchant(c) {
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	rows, err := db.DB.Query(`
+		SELECT s.id, s.merchant_id, s.name, s.original_price, s.current_price, s.quantity, s.weekdays, s.publish_time,
+		       s.expiry_minutes, s.latitude, s.longitude, s.is_paused, s.created_at,
+		       ARRAY(SELECT to_char(k.skip_date, 'YYYY-MM-DD') FROM listing_schedule_skips k
+		             WHERE k.schedule_id = s.id AND k.skip_date >= CURRENT_DATE ORDER BY k.skip_date)
+		FROM listing_schedules s
+		WHERE s.merchant_id = $1
+		ORDER BY s.created_at DESC
+	`, merchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedules"})
+		return
+	}
+	defer rows.Close()
+
+	schedules := []models.ListingSchedule{}
+	for rows.Next() {
+		var s models.ListingSchedule
+		if err := rows.Scan(&s.ID, &s.MerchantID, &s.Name, &s.OriginalPrice, &s.CurrentPrice, &s.Quantity, pq.Array(&s.Weekdays),
+			&s.PublishTime, &s.ExpiryMinutes, &s.Latitude, &s.Longitude, &s.IsPaused, &s.CreatedAt, pq.Array(&s.SkipDates)); err != nil {
+			continue
+		}
+		schedules = append(schedules, s)
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// PauseListingSchedule - PUT /merchant/schedules/:id/pause
+func PauseListingSchedule(c *gin.Context) {
+	setSchedulePaused(c, true)
+}
+
+// ResumeListingSchedule - PUT /merchant/schedules/:id/resume
+func ResumeListingSchedule(c *gin.Context) {
+	setSchedulePaused(c, false)
+}
+
+func setSchedulePaused(c *gin.Context, paused bool) {
+	if !requireMerchant(c) {
+		return
+	}
+
+	res, err := db.DB.Exec(`
+		UPDATE listing_schedules SET is_paused = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND merchant_id = $3
+	`, paused, c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule updated", "is_paused": paused})
+}
+
+// SkipListingSchedule - POST /merchant/schedules/:id/skip
+// Skips a single date (merchant local, YYYY-MM-DD), e.g. for a holiday.
+func SkipListingSchedule(c *gin.Context) {
+	var input struct {
+		Date string `json:"date" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+	if !requireMerchant(c) {
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	res, err := db.DB.Exec(`
+		INSERT INTO listing_schedule_skips (schedule_id, skip_date)
+		SELECT id, $3 FROM listing_schedules WHERE id = $1 AND merchant_id = $2
+		ON CONFLICT DO NOTHING
+	`, c.Param("id"), merchantID, input.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to skip date"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Either the schedule is not this merchant's or the date is already skipped
+		var exists bool
+		db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM listing_schedules WHERE id = $1 AND merchant_id = $2)",
+			c.Param("id"), merchantID).Scan(&exists)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Date skipped", "date": input.Date})
+}
+
+// UnskipListingSchedule - DELETE /merchant/schedules/:id/skip/:date
+func UnskipListingSchedule(c *gin.Context) {
+	if !requireMerchant(c) {
+		return
+	}
+	merchantID := c.GetString("user_id")
+
+	_, err := db.DB.Exec(`
+		DELETE FROM listing_schedule_skips k USING listing_schedules s
+		WHERE k.schedule_id = s.id AND s.id = $1 AND s.merchant_id = $2 AND k.skip_date = $3
+	`, c.Param("id"), merchantID, c.Param("date"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove skip"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Skip removed"})
+}
+
+// =========================================================================
+// SCHEDULER
+// =========================================================================
+
+// dueOccurrence reports whether today's occurrence of s (in loc) should be
+// published at now, and returns its local date and publish instant. Only the
+// current day is considered: after downtime the scheduler publishes the
+// occurrence that is still live, never a backlog of stale ones.
+func dueOccurrence(s models.ListingSchedule, now time.Time, loc *time.Location) (runDate string, publishAt time.Time, due bool) {
+	local := now.In(loc)
+
+	onDay := false
+	for _, d := range s.Weekdays {
+		if time.Weekday(d) == local.Weekday() {
+			onDay = true
+			break
+		}
+	}
+	if !onDay {
+		return "", time.Time{}, false
+	}
+
+	hm, err := time.Parse("15:04", s.PublishTime)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	publishAt = time.Date(local.Year(), local.Month(), local.Day(), hm.Hour(), hm.Minute(), 0, 0, loc)
+
+	// Not yet time, or the listing would already have expired
+	if local.Before(publishAt) || !local.Before(publishAt.Add(time.Duration(s.ExpiryMinutes)*time.Minute)) {
+		return "", time.Time{}, false
+	}
+
+	return local.Format("2006-01-02"), publishAt, true
+}
+
+// StartListingScheduler publishes due recurring listings every interval.
+func StartListingScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			publishDueSchedules(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+func publishDueSchedules(now time.Time) {
+	rows, err := db.DB.Query(`
+		SELECT s.id, s.merchant_id, s.name, s.original_price, s.current_price, s.quantity, s.weekdays, s.publish_time,
+		       s.expiry_minutes, s.latitude, s.longitude, COALESCE(m.timezone, '')
+		FROM listing_schedules s
+		LEFT JOIN merchants m ON m.user_id = s.merchant_id
+		WHERE s.is_paused = FALSE
+	`)
+	if err != nil {
+		log.Println("Listing scheduler: failed to load schedules:", err)
+		return
+	}
+
+	type dueSchedule struct {
+		schedule  models.ListingSchedule
+		runDate   string
+		publishAt time.Time
+	}
+	var due []dueSchedule
+	for rows.Next() {
+		var s models.ListingSchedule
+		var tz string
+		if err := rows.Scan(&s.ID, &s.MerchantID, &s.Name, &s.OriginalPrice, &s.CurrentPrice, &s.Quantity, pq.Array(&s.Weekdays),
+			&s.PublishTime, &s.ExpiryMinutes, &s.Latitude, &s.Longitude, &tz); err != nil {
+			continue
+		}
+		if runDate, publishAt, ok := dueOccurrence(s, now, loadLocation(tz)); ok {
+			due = append(due, dueSchedule{s, runDate, publishAt})
+		}
+	}
+	rows.Close()
+
+	for _, d := range due {
+		if err := publishSchedule(d.schedule, d.runDate, d.publishAt); err != nil {
+			log.Printf("Listing scheduler: schedule %d on %s failed: %v", d.schedule.ID, d.runDate, err)
+		}
+	}
+}
+
+// publishSchedule claims the (schedule, date) run and creates the listings in
+// the same transaction, so concurrent schedulers cannot both publish it.
+// Schedules that no longer pass the listing rules are paused.
+func publishSchedule(s models.ListingSchedule, runDate string, publishAt time.Time) error {
+	scheduleID := s.ID
+	input := productInput{
+		MerchantID:    s.MerchantID,
+		Name:          s.Name,
+		OriginalPrice: s.OriginalPrice,
+		CurrentPrice:  s.CurrentPrice,
+		ExpiryMinutes: s.ExpiryMinutes,
+		Latitude:      s.Latitude,
+		Longitude:     s.Longitude,
+		ScheduleID:    &scheduleID,
+	}
+
+	v, err := newListingValidator(db.DB, s.MerchantID)
+	if err != nil {
+		return err
+	}
+	if fieldErrs := v.Validate(input, publishAt); len(fieldErrs) > 0 {
+		db.DB.Exec("UPDATE listing_schedules SET is_paused = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1", s.ID)
+		return fmt.Errorf("paused, listing rules not met: %s", summarizeFieldErrors(fieldErrs))
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO listing_schedule_runs (schedule_id, run_date)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM listing_schedule_skips WHERE schedule_id = $1 AND skip_date = $2)
+		ON CONFLICT DO NOTHING
+	`, s.ID, runDate)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Already published or skipped
+		return nil
+	}
+
+	for i := 0; i < s.Quantity; i++ {
+		if _, err := createListing(tx, input, publishAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
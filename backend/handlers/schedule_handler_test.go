@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"food-platform-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =========================================================================
+// LISTING SCHEDULE TESTS
+// =========================================================================
+
+func TestCreateListingScheduleEmptyBody(t *testing.T) {
+	router := gin.New()
+	router.POST("/merchant/schedules", CreateListingSchedule)
+
+	req, _ := http.NewRequest("POST", "/merchant/schedules", bytes.NewBuffer([]byte("{}")))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateListingScheduleInvalidWeekday(t *testing.T) {
+	router := gin.New()
+	router.POST("/merchant/schedules", CreateListingSchedule)
+
+	body := map[string]interface{}{
+		"name":           "Surprise Bag",
+		"original_price": 300,
+		"current_price":  120,
+		"quantity":       5,
+		"weekdays":       []int{1, 7}, // Invalid: 0-6 only
+		"publish_time":   "20:30",
+		"expiry_minutes": 90,
+		"latitude":       25.03,
+		"longitude":      121.56,
+	}
+	jsonBody, _ := json.Marshal(body)
+
+	req, _ := http.NewRequest("POST", "/merchant/schedules", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateListingScheduleInvalidPublishTime(t *testing.T) {
+	router := gin.New()
+	router.POST("/merchant/schedules", CreateListingSchedule)
+
+	body := map[string]interface{}{
+		"name":           "Surprise Bag",
+		"original_price": 300,
+		"current_price":  120,
+		"quantity":       5,
+		"weekdays":       []int{1, 2, 3},
+		"publish_time":   "8:30pm",
+		"expiry_minutes": 90,
+		"latitude":       25.03,
+		"longitude":      121.56,
+	}
+	jsonBody, _ := json.Marshal(body)
+
+	req, _ := http.NewRequest("POST", "/merchant/schedules", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListingSchedulesRequireToken(t *testing.T) {
+	router := gin.New()
+	router.GET("/merchant/schedules", AuthRequired(), GetListingSchedules)
+	router.PUT("/merchant/schedules/:id/pause", AuthRequired(), PauseListingSchedule)
+	router.DELETE("/merchant/schedules/:id/skip/:date", AuthRequired(), UnskipListingSchedule)
+
+	for _, tc := range []struct{ method, path string }{
+		{"GET", "/merchant/schedules?merchant_id=m1"},
+		{"PUT", "/merchant/schedules/1/pause"},
+		{"DELETE", "/merchant/schedules/1/skip/2026-03-02?merchant_id=m1"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(`{"merchant_id":"m1"}`))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, tc.path)
+	}
+}
+
+func TestDueOccurrence(t *testing.T) {
+	taipei := loadLocation("Asia/Taipei")
+	s := models.ListingSchedule{
+		Weekdays:      []int64{1, 2, 3, 4, 5}, // Mon-Fri
+		PublishTime:   "20:30",
+		ExpiryMinutes: 90,
+	}
+
+	// Monday 2025-01-06 20:45 in Taipei is 12:45 UTC
+	runDate, publishAt, due := dueOccurrence(s, time.Date(2025, 1, 6, 12, 45, 0, 0, time.UTC), taipei)
+	assert.True(t, due)
+	assert.Equal(t, "2025-01-06", runDate)
+	assert.Equal(t, time.Date(2025, 1, 6, 12, 30, 0, 0, time.UTC), publishAt.UTC())
+
+	// Before publish time
+	_, _, due = dueOccurrence(s, time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC), taipei)
+	assert.False(t, due)
+
+	// After the listing would have expired (missed during downtime)
+	_, _, due = dueOccurrence(s, time.Date(2025, 1, 6, 14, 30, 0, 0, time.UTC), taipei)
+	assert.False(t, due)
+
+	// Saturday is not scheduled
+	_, _, due = dueOccurrence(s, time.Date(2025, 1, 11, 12, 45, 0, 0, time.UTC), taipei)
+	assert.False(t, due)
+}
@@ -146,6 +146,7 @@ func VerifySMSCode(c *gin.Context) {
 	} else {
 		log.Printf("📱 [SMS] Existing user logged in: %s (phone: %s)", userID, input.Phone)
 	}
+	recordDevice(c, userID)
 
 	// Generate JWT
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
@@ -2,23 +2,66 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"food-platform-backend/db"
 	"food-platform-backend/models"
+	"log"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 // =========================================================================
 // REVIEWS
 // =========================================================================
 
+// defaultReviewWindowDays is how long after pickup an order can be reviewed,
+// overridable with REVIEW_WINDOW_DAYS.
+const defaultReviewWindowDays = 14
+
+var (
+	errReviewWrongMerchant = errors.New("order is not from this merchant")
+	errReviewNotPickedUp   = errors.New("only picked up orders can be reviewed")
+	errReviewWindowClosed  = errors.New("review window has closed")
+)
+
+// reviewWindow is how long after pickup a review is accepted.
+func reviewWindow() time.Duration {
+	days := defaultReviewWindowDays
+	if v, err := strconv.Atoi(os.Getenv("REVIEW_WINDOW_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// reviewEligibility checks that an order the caller owns may be reviewed
+// for merchantID at now.
+func reviewEligibility(orderMerchantID string, status models.OrderStatus, pickedUpAt *time.Time, merchantID string, now time.Time) error {
+	if orderMerchantID != merchantID {
+		return errReviewWrongMerchant
+	}
+	if status != models.OrderStatusPickedUp || pickedUpAt == nil {
+		return errReviewNotPickedUp
+	}
+	if now.After(pickedUpAt.Add(reviewWindow())) {
+		return errReviewWindowClosed
+	}
+	return nil
+}
+
 // CreateReview - POST /reviews
+// Only the consumer who picked up an order can review it, once, within the
+// review window. Comments the content filter flags wait for moderation.
 func CreateReview(c *gin.Context) {
 	var input struct {
 		OrderID    int    `json:"order_id" binding:"required"`
-		UserID     string `json:"user_id" binding:"required"`
 		MerchantID string `json:"merchant_id" binding:"required"`
 		Rating     int    `json:"rating" binding:"required,min=1,max=5"`
 		Comment    string `json:"comment"`
@@ -28,28 +71,148 @@ func CreateReview(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	userID := c.GetString("user_id")
 
-	_, err := db.DB.Exec(`
-		INSERT INTO reviews (order_id, user_id, merchant_id, rating, comment)
-		VALUES ($1, $2, $3, $4, $5)
-	`, input.OrderID, input.UserID, input.MerchantID, input.Rating, input.Comment)
+	var consumerID, orderMerchantID string
+	var status models.OrderStatus
+	var pickedUpAt *time.Time
+	err := db.DB.QueryRow(`
+		SELECT o.consumer_id, COALESCE(o.merchant_id, p.merchant_id, ''), COALESCE(o.status, 'pending'), o.picked_up_at
+		FROM orders o
+		JOIN products p ON p.id = o.product_id
+		WHERE o.id = $1
+	`, input.OrderID).Scan(&consumerID, &orderMerchantID, &status, &pickedUpAt)
+	if err == sql.ErrNoRows || (err == nil && consumerID != userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if err := reviewEligibility(orderMerchantID, status, pickedUpAt, input.MerchantID, time.Now()); err != nil {
+		code := http.StatusConflict
+		if errors.Is(err, errReviewWrongMerchant) {
+			code = http.StatusBadRequest
+		}
+		c.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
 
+	reviewStatus, flags := screenReview(input.Comment)
+	flagsJSON, _ := json.Marshal(flags)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction begin failed"})
+		return
+	}
+	defer tx.Rollback()
+
+	var reviewID int
+	var createdAt time.Time
+	err = tx.QueryRow(`
+		INSERT INTO reviews (order_id, user_id, merchant_id, rating, comment, status, moderation_flags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (order_id) DO NOTHING
+		RETURNING id, created_at
+	`, input.OrderID, userID, input.MerchantID, input.Rating, input.Comment, reviewStatus, flagsJSON).Scan(&reviewID, &createdAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order already reviewed"})
+		return
+	}
+	if err == nil && reviewCounts(reviewStatus) {
+		err = updateRatingAggregates(tx, input.MerchantID, input.Rating, createdAt, 1)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Commit failed"})
+		return
+	}
+	recordDevice(c, userID)
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Review created"})
+	if reviewStatus == models.ReviewStatusPending {
+		c.JSON(http.StatusCreated, gin.H{"message": "Review submitted, it will appear once approved", "review_id": reviewID, "status": reviewStatus})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Review created", "review_id": reviewID, "status": reviewStatus})
+}
+
+// reviewSortOrders maps ?sort= to ORDER BY clauses. Ties fall back to
+// newest first so pages are stable.
+var reviewSortOrders = map[string]string{
+	"newest":       "created_at DESC, id DESC",
+	"highest":      "rating DESC, created_at DESC, id DESC",
+	"lowest":       "rating ASC, created_at DESC, id DESC",
+	"most_helpful": "helpful_score DESC, helpful_count DESC, created_at DESC, id DESC",
+}
+
+// reviewListQuery turns the listing's query parameters into a WHERE clause
+// (after merchant_id = $1 and the published filter), its arguments and an
+// ORDER BY clause.
+func reviewListQuery(q url.Values) (where string, args []interface{}, order string, err error) {
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = "newest"
+	}
+	order, ok := reviewSortOrders[sort]
+	if !ok {
+		return "", nil, "", errors.New("sort must be newest, highest, lowest or most_helpful")
+	}
+
+	if v := q.Get("rating"); v != "" {
+		var stars []int64
+		for _, part := range strings.Split(v, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || n < 1 || n > 5 {
+				return "", nil, "", errors.New("rating must be star values from 1 to 5, e.g. rating=4,5")
+			}
+			stars = append(stars, int64(n))
+		}
+		args = append(args, pq.Array(stars))
+		where += " AND rating = ANY($" + strconv.Itoa(len(args)+1) + ")"
+	}
+	if withComment, _ := strconv.ParseBool(q.Get("with_comment")); withComment {
+		where += " AND NULLIF(BTRIM(comment), '') IS NOT NULL"
+	}
+	if hasPhotos, _ := strconv.ParseBool(q.Get("has_photos")); hasPhotos {
+		where += " AND EXISTS (SELECT 1 FROM review_photos p WHERE p.review_id = reviews.id AND p.status = 'published')"
+	}
+	return where, args, order, nil
 }
 
 // GetMerchantReviews - GET /reviews/merchant/:merchant_id
+// ?sort=newest|highest|lowest|most_helpful&rating=4,5&with_comment=true&has_photos=true&page=&page_size=
+// total is the number of reviews matching the filters; the rating summary
+// always covers every published review.
 func GetMerchantReviews(c *gin.Context) {
 	merchantID := c.Param("merchant_id")
 
+	filter, filterArgs, order, err := reviewListQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	where := "merchant_id = $1 AND status = 'published'" + filter
+	args := append([]interface{}{merchantID}, filterArgs...)
+
+	var total int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM reviews WHERE `+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	args = append(args, pageSize, (page-1)*pageSize)
 	rows, err := db.DB.Query(`
-		SELECT id, order_id, user_id, merchant_id, rating, comment, created_at
-		FROM reviews WHERE merchant_id = $1 ORDER BY created_at DESC
-	`, merchantID)
+		SELECT id, order_id, user_id, merchant_id, rating, comment, created_at, reply, replied_at, reply_updated_at,
+		       COALESCE(helpful_count, 0), COALESCE(not_helpful_count, 0)
+		FROM reviews WHERE `+where+`
+		ORDER BY `+order+`
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
@@ -57,21 +220,48 @@ func GetMerchantReviews(c *gin.Context) {
 	}
 	defer rows.Close()
 
-	var reviews []models.Review
+	reviews := []models.Review{}
 	for rows.Next() {
 		var r models.Review
-		rows.Scan(&r.ID, &r.OrderID, &r.UserID, &r.MerchantID, &r.Rating, &r.Comment, &r.CreatedAt)
+		var reply sql.NullString
+		var repliedAt sql.NullTime
+		var replyUpdatedAt *time.Time
+		rows.Scan(&r.ID, &r.OrderID, &r.UserID, &r.MerchantID, &r.Rating, &r.Comment, &r.CreatedAt, &reply, &repliedAt, &replyUpdatedAt,
+			&r.Helpful, &r.NotHelpful)
+		if reply.Valid {
+			r.Reply = &models.ReviewReply{Body: reply.String, CreatedAt: repliedAt.Time, UpdatedAt: replyUpdatedAt}
+		}
 		reviews = append(reviews, r)
 	}
+	rows.Close()
+
+	ids := make([]int, len(reviews))
+	for i, r := range reviews {
+		ids[i] = r.ID
+	}
+	photos, err := loadReviewPhotos(db.DB, ids, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review photos"})
+		return
+	}
+	for i := range reviews {
+		reviews[i].Photos = photos[reviews[i].ID]
+	}
 
-	// Calculate average rating
-	var avgRating float64
-	db.DB.QueryRow("SELECT COALESCE(AVG(rating), 0) FROM reviews WHERE merchant_id = $1", merchantID).Scan(&avgRating)
+	rating, err := loadRatingSummary(db.DB, merchantID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ratings"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"reviews":        reviews,
-		"average_rating": avgRating,
-		"total_reviews":  len(reviews),
+		"page":           page,
+		"page_size":      pageSize,
+		"total":          total,
+		"average_rating": rating.Average,
+		"total_reviews":  rating.Count,
+		"rating":         rating,
 	})
 }
 
@@ -230,6 +420,19 @@ func CreateNotification(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "Notification created"})
 }
 
+// notifyUser sends an in-app notification on behalf of the platform. It is
+// best effort: failures are logged and never fail the caller's request, so
+// call it after the triggering transaction has committed.
+func notifyUser(userID, title, body, notificationType string) {
+	_, err := db.DB.Exec(`
+		INSERT INTO notifications (user_id, title, body, type)
+		VALUES ($1, $2, $3, $4)
+	`, userID, title, body, notificationType)
+	if err != nil {
+		log.Printf("Failed to notify %s (%s): %v", userID, notificationType, err)
+	}
+}
+
 // =========================================================================
 // MERCHANT INFO (Enhanced)
 // =========================================================================
@@ -250,15 +453,16 @@ func GetMerchantDetails(c *gin.Context) {
 		BusinessHoursClose string  `json:"business_hours_close"`
 		Category           string  `json:"category"`
 		Description        string  `json:"description"`
+		Timezone           string  `json:"timezone"`
 	}
 
 	err := db.DB.QueryRow(`
 		SELECT user_id, COALESCE(shop_name,''), COALESCE(address,''), COALESCE(latitude,0), COALESCE(longitude,0),
 		       COALESCE(phone,''), COALESCE(email,''), COALESCE(business_hours_open,''), COALESCE(business_hours_close,''),
-		       COALESCE(category,''), COALESCE(description,'')
+		       COALESCE(category,''), COALESCE(description,''), COALESCE(timezone,'')
 		FROM merchants WHERE user_id = $1
 	`, merchantID).Scan(&m.UserID, &m.ShopName, &m.Address, &m.Latitude, &m.Longitude,
-		&m.Phone, &m.Email, &m.BusinessHoursOpen, &m.BusinessHoursClose, &m.Category, &m.Description)
+		&m.Phone, &m.Email, &m.BusinessHoursOpen, &m.BusinessHoursClose, &m.Category, &m.Description, &m.Timezone)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
@@ -269,10 +473,11 @@ func GetMerchantDetails(c *gin.Context) {
 		return
 	}
 
-	// Get average rating
-	var avgRating float64
-	var totalReviews int
-	db.DB.QueryRow("SELECT COALESCE(AVG(rating), 0), COUNT(*) FROM reviews WHERE merchant_id = $1", merchantID).Scan(&avgRating, &totalReviews)
+	rating, err := loadRatingSummary(db.DB, merchantID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
 
 	// Get product count
 	var productCount int
@@ -280,8 +485,9 @@ func GetMerchantDetails(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"merchant":       m,
-		"average_rating": avgRating,
-		"total_reviews":  totalReviews,
+		"average_rating": rating.Average,
+		"total_reviews":  rating.Count,
+		"rating":         rating,
 		"product_count":  productCount,
 	})
 }
@@ -299,29 +505,37 @@ func IsFavorite(c *gin.Context) {
 }
 
 // SearchMerchants - GET /merchants/search?q=xxx&category=xxx
+// Results are ranked by Bayesian rating score.
 func SearchMerchants(c *gin.Context) {
 	query := c.Query("q")
 	category := c.Query("category")
+	priorMean, priorWeight := ratingPriorMean(db.DB), ratingPriorWeight()
 
 	sqlQuery := `
-		SELECT user_id, COALESCE(shop_name,''), COALESCE(address,''), COALESCE(category,'')
-		FROM merchants WHERE 1=1
+		SELECT m.user_id, COALESCE(m.shop_name,''), COALESCE(m.address,''), COALESCE(m.category,''),
+		       COALESCE(s.review_count, 0), COALESCE(s.rating_sum, 0)
+		FROM merchants m
+		LEFT JOIN merchant_rating_stats s ON s.merchant_id = m.user_id
+		WHERE 1=1
 	`
-	args := []interface{}{}
-	argIndex := 1
+	args := []interface{}{priorMean, priorWeight}
+	argIndex := 3
 
 	if query != "" {
-		sqlQuery += " AND (shop_name ILIKE $" + strconv.Itoa(argIndex) + " OR address ILIKE $" + strconv.Itoa(argIndex) + ")"
+		sqlQuery += " AND (m.shop_name ILIKE $" + strconv.Itoa(argIndex) + " OR m.address ILIKE $" + strconv.Itoa(argIndex) + ")"
 		args = append(args, "%"+query+"%")
 		argIndex++
 	}
 
 	if category != "" {
-		sqlQuery += " AND category = $" + strconv.Itoa(argIndex)
+		sqlQuery += " AND m.category = $" + strconv.Itoa(argIndex)
 		args = append(args, category)
 	}
 
-	sqlQuery += " LIMIT 20"
+	sqlQuery += `
+		ORDER BY ($2::float8 * $1::float8 + COALESCE(s.rating_sum, 0)) / NULLIF($2::float8 + COALESCE(s.review_count, 0), 0) DESC NULLS LAST,
+		         COALESCE(s.review_count, 0) DESC, m.user_id
+		LIMIT 20`
 
 	rows, err := db.DB.Query(sqlQuery, args...)
 	if err != nil {
@@ -331,16 +545,24 @@ func SearchMerchants(c *gin.Context) {
 	defer rows.Close()
 
 	type MerchantSummary struct {
-		UserID   string `json:"user_id"`
-		ShopName string `json:"shop_name"`
-		Address  string `json:"address"`
-		Category string `json:"category"`
+		UserID        string  `json:"user_id"`
+		ShopName      string  `json:"shop_name"`
+		Address       string  `json:"address"`
+		Category      string  `json:"category"`
+		AverageRating float64 `json:"average_rating"`
+		TotalReviews  int     `json:"total_reviews"`
+		RatingScore   float64 `json:"rating_score"`
 	}
 
 	var merchants []MerchantSummary
 	for rows.Next() {
 		var m MerchantSummary
-		rows.Scan(&m.UserID, &m.ShopName, &m.Address, &m.Category)
+		var ratingSum int
+		rows.Scan(&m.UserID, &m.ShopName, &m.Address, &m.Category, &m.TotalReviews, &ratingSum)
+		if m.TotalReviews > 0 {
+			m.AverageRating = roundRating(float64(ratingSum) / float64(m.TotalReviews))
+		}
+		m.RatingScore = roundRating(bayesianScore(ratingSum, m.TotalReviews, priorMean, priorWeight))
 		merchants = append(merchants, m)
 	}
 
@@ -3,9 +3,12 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"food-platform-backend/models"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -50,6 +53,58 @@ func TestCreateReviewInvalidRating(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestReviewEligibility(t *testing.T) {
+	t.Setenv("REVIEW_WINDOW_DAYS", "")
+	pickedUp := time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)
+	now := pickedUp.Add(24 * time.Hour)
+
+	assert.NoError(t, reviewEligibility("m1", models.OrderStatusPickedUp, &pickedUp, "m1", now))
+	assert.ErrorIs(t, reviewEligibility("m1", models.OrderStatusPickedUp, &pickedUp, "m2", now), errReviewWrongMerchant)
+	assert.ErrorIs(t, reviewEligibility("m1", models.OrderStatusReadyForPickup, nil, "m1", now), errReviewNotPickedUp)
+	assert.ErrorIs(t, reviewEligibility("m1", models.OrderStatusNoShow, nil, "m1", now), errReviewNotPickedUp)
+
+	assert.NoError(t, reviewEligibility("m1", models.OrderStatusPickedUp, &pickedUp, "m1", pickedUp.AddDate(0, 0, 14)))
+	assert.ErrorIs(t, reviewEligibility("m1", models.OrderStatusPickedUp, &pickedUp, "m1", pickedUp.AddDate(0, 0, 15)), errReviewWindowClosed)
+
+	t.Setenv("REVIEW_WINDOW_DAYS", "30")
+	assert.NoError(t, reviewEligibility("m1", models.OrderStatusPickedUp, &pickedUp, "m1", pickedUp.AddDate(0, 0, 15)))
+}
+
+func TestReviewListQuery(t *testing.T) {
+	where, args, order, err := reviewListQuery(url.Values{})
+	assert.NoError(t, err)
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+	assert.Equal(t, "created_at DESC, id DESC", order)
+
+	where, args, order, err = reviewListQuery(url.Values{"sort": {"most_helpful"}, "rating": {"4, 5"}, "with_comment": {"true"}})
+	assert.NoError(t, err)
+	assert.Equal(t, " AND rating = ANY($2) AND NULLIF(BTRIM(comment), '') IS NOT NULL", where)
+	assert.Len(t, args, 1)
+	assert.Contains(t, order, "helpful_score DESC")
+
+	where, _, order, err = reviewListQuery(url.Values{"sort": {"lowest"}, "has_photos": {"1"}})
+	assert.NoError(t, err)
+	assert.Contains(t, where, "review_photos")
+	assert.Equal(t, "rating ASC, created_at DESC, id DESC", order)
+
+	for _, bad := range []url.Values{{"sort": {"oldest"}}, {"rating": {"6"}}, {"rating": {"4,x"}}} {
+		_, _, _, err := reviewListQuery(bad)
+		assert.Error(t, err, bad.Encode())
+	}
+}
+
+func TestGetMerchantReviewsInvalidSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/reviews/merchant/:merchant_id", GetMerchantReviews)
+
+	req := httptest.NewRequest(http.MethodGet, "/reviews/merchant/m1?sort=random", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestToggleFavoriteEmptyBody(t *testing.T) {
 	router := gin.New()
 	router.POST("/favorites/toggle", ToggleFavorite)
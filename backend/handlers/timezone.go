@@ -0,0 +1,26 @@
+package handlers
+
+import "time"
+
+// defaultMerchantTimezone applies to merchants that have not set one.
+const defaultMerchantTimezone = "Asia/Taipei"
+
+// loadLocation resolves an IANA timezone name, falling back to the platform
+// default for empty or unknown names.
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		name = defaultMerchantTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc, _ = time.LoadLocation(defaultMerchantTimezone)
+	}
+	return loc
+}
+
+// merchantLocation returns the merchant's configured timezone.
+func merchantLocation(q queryer, merchantID string) *time.Location {
+	var tz string
+	q.QueryRow("SELECT COALESCE(timezone, '') FROM merchants WHERE user_id = $1", merchantID).Scan(&tz)
+	return loadLocation(tz)
+}
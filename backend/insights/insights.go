@@ -0,0 +1,265 @@
+// Package insights summarises what customers write in reviews: the words
+// and phrases that keep coming up, with example snippets, and how positive
+// the comments are over time. Chinese is segmented against a dictionary,
+// English and Vietnamese are split on spaces with Vietnamese compounds
+// joined, and sentiment comes from a word lexicon with negation.
+package insights
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limits on what a Report lists.
+const (
+	MinMentions     = 2  // Reviews that must mention a term before it is reported
+	MaxKeywords     = 20 // Single words
+	MaxPhrases      = 10 // Two-word phrases
+	MaxSnippets     = 3
+	MaxSnippetRunes = 120
+	TrendMonths     = 12
+
+	// Sentiments beyond ±SentimentThreshold count as positive or negative.
+	SentimentThreshold = 0.05
+)
+
+// Review is a comment to analyse.
+type Review struct {
+	ID        int
+	Text      string
+	CreatedAt time.Time
+}
+
+// Report is the analysis of one merchant's reviews.
+type Report struct {
+	ReviewCount int          `json:"review_count"`
+	Keywords    []Mention    `json:"keywords"`
+	Phrases     []Mention    `json:"phrases"`
+	Trend       []TrendPoint `json:"trend"`
+}
+
+// Mention is a term customers keep using, with how they felt when they did.
+type Mention struct {
+	Term     string    `json:"term"`
+	Reviews  int       `json:"reviews"`
+	Positive int       `json:"positive"`
+	Negative int       `json:"negative"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// Snippet is the sentence a term was used in.
+type Snippet struct {
+	ReviewID  int       `json:"review_id"`
+	Text      string    `json:"text"`
+	Sentiment float64   `json:"sentiment"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TrendPoint is one month of review sentiment.
+type TrendPoint struct {
+	Month            string  `json:"month"` // YYYY-MM in the merchant's timezone
+	Reviews          int     `json:"reviews"`
+	Positive         int     `json:"positive"`
+	Negative         int     `json:"negative"`
+	Neutral          int     `json:"neutral"`
+	AverageSentiment float64 `json:"average_sentiment"`
+}
+
+// Sentiment scores a text from -1 (negative) to 1 (positive).
+func Sentiment(text string) float64 {
+	var raw float64
+	for _, s := range splitSentences(text) {
+		raw += sentenceScore(tokenize(s))
+	}
+	return normalizeScore(raw)
+}
+
+// sentenceScore sums the lexicon scores of a sentence's words. A negation
+// flips (and weakens) the next three words of its clause; an intensifier
+// strengthens the next two.
+func sentenceScore(tokens []token) float64 {
+	var score float64
+	negatedUntil, intensifiedUntil := -1, -1
+	for i, t := range tokens {
+		if t.Text == "" {
+			negatedUntil, intensifiedUntil = -1, -1
+			continue
+		}
+		if negations[t.Text] {
+			negatedUntil = i + 3
+			continue
+		}
+		if intensifiers[t.Text] {
+			intensifiedUntil = i + 2
+			continue
+		}
+		v := sentimentLexicon[t.Text]
+		if v == 0 {
+			continue
+		}
+		if i <= intensifiedUntil {
+			v *= 1.3
+		}
+		if i <= negatedUntil {
+			v *= -0.75
+		}
+		score += v
+	}
+	return score
+}
+
+// normalizeScore maps a raw lexicon sum onto (-1, 1).
+func normalizeScore(raw float64) float64 {
+	return raw / math.Sqrt(raw*raw+15)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+type termStats struct {
+	reviews  map[int]bool
+	positive int
+	negative int
+	snippets []Snippet
+}
+
+// Analyze builds a report from reviews; months are cut in loc and the trend
+// ends with the month of now.
+func Analyze(reviews []Review, loc *time.Location, now time.Time) Report {
+	// Newest first, so snippets are recent
+	reviews = append([]Review(nil), reviews...)
+	sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].CreatedAt.After(reviews[j].CreatedAt) })
+
+	words, phrases := map[string]*termStats{}, map[string]*termStats{}
+	months := map[string]*TrendPoint{}
+	report := Report{}
+	for _, r := range reviews {
+		sentences := splitSentences(r.Text)
+		if len(sentences) == 0 {
+			continue
+		}
+		report.ReviewCount++
+
+		var raw float64
+		for _, s := range sentences {
+			tokens := tokenize(s)
+			score := sentenceScore(tokens)
+			raw += score
+			snippet := Snippet{ReviewID: r.ID, Text: trimSnippet(s), Sentiment: round(normalizeScore(score)), CreatedAt: r.CreatedAt}
+			for i, t := range tokens {
+				if !isTerm(t) {
+					continue
+				}
+				countTerm(words, t.Text, snippet)
+				if i+1 < len(tokens) && isTerm(tokens[i+1]) {
+					countTerm(phrases, joinTokens(t, tokens[i+1]), snippet)
+				}
+			}
+		}
+
+		sentiment := normalizeScore(raw)
+		month := r.CreatedAt.In(loc).Format("2006-01")
+		p := months[month]
+		if p == nil {
+			p = &TrendPoint{Month: month}
+			months[month] = p
+		}
+		p.Reviews++
+		p.AverageSentiment += sentiment
+		switch {
+		case sentiment > SentimentThreshold:
+			p.Positive++
+		case sentiment < -SentimentThreshold:
+			p.Negative++
+		default:
+			p.Neutral++
+		}
+	}
+
+	report.Keywords = topMentions(words, MaxKeywords)
+	report.Phrases = topMentions(phrases, MaxPhrases)
+	report.Trend = trend(months, loc, now)
+	return report
+}
+
+// countTerm records one use of term. A review counts once per term, using
+// the first (newest-first, so most recent) sentence it appears in.
+func countTerm(terms map[string]*termStats, term string, s Snippet) {
+	st := terms[term]
+	if st == nil {
+		st = &termStats{reviews: map[int]bool{}}
+		terms[term] = st
+	}
+	if st.reviews[s.ReviewID] {
+		return
+	}
+	st.reviews[s.ReviewID] = true
+	switch {
+	case s.Sentiment > SentimentThreshold:
+		st.positive++
+	case s.Sentiment < -SentimentThreshold:
+		st.negative++
+	}
+	if len(st.snippets) < MaxSnippets {
+		st.snippets = append(st.snippets, s)
+	}
+}
+
+func joinTokens(a, b token) string {
+	if a.Han && b.Han {
+		return a.Text + b.Text
+	}
+	return a.Text + " " + b.Text
+}
+
+func topMentions(terms map[string]*termStats, limit int) []Mention {
+	mentions := []Mention{}
+	for term, st := range terms {
+		if len(st.reviews) < MinMentions {
+			continue
+		}
+		mentions = append(mentions, Mention{
+			Term: term, Reviews: len(st.reviews), Positive: st.positive, Negative: st.negative, Snippets: st.snippets,
+		})
+	}
+	sort.Slice(mentions, func(i, j int) bool {
+		if mentions[i].Reviews != mentions[j].Reviews {
+			return mentions[i].Reviews > mentions[j].Reviews
+		}
+		return mentions[i].Term < mentions[j].Term
+	})
+	if len(mentions) > limit {
+		mentions = mentions[:limit]
+	}
+	return mentions
+}
+
+// trend lists the last TrendMonths months, oldest first, including months
+// without reviews.
+func trend(months map[string]*TrendPoint, loc *time.Location, now time.Time) []TrendPoint {
+	local := now.In(loc)
+	first := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, -(TrendMonths - 1), 0)
+	points := make([]TrendPoint, 0, TrendMonths)
+	for i := 0; i < TrendMonths; i++ {
+		month := first.AddDate(0, i, 0).Format("2006-01")
+		p := TrendPoint{Month: month}
+		if m := months[month]; m != nil {
+			p = *m
+			p.AverageSentiment = round(p.AverageSentiment / float64(p.Reviews))
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// trimSnippet shortens a sentence to MaxSnippetRunes.
+func trimSnippet(s string) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= MaxSnippetRunes {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:MaxSnippetRunes-1])) + "…"
+}
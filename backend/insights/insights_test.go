@@ -0,0 +1,113 @@
+package insights
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func texts(tokens []token) []string {
+	var out []string
+	for _, t := range tokens {
+		out = append(out, t.Text)
+	}
+	return out
+}
+
+func TestTokenizeChinese(t *testing.T) {
+	assert.Equal(t, []string{"麵包", "很", "新鮮", "", "店員", "很", "親切"}, texts(tokenize("麵包很新鮮，店員很親切")))
+	assert.Equal(t, []string{"面包", "不新鲜", "", "等很久"}, texts(tokenize("面包不新鲜，等很久")))
+	// Unknown characters fall back to single characters
+	assert.Equal(t, []string{"鳳", "梨", "酥", "好吃"}, texts(tokenize("鳳梨酥好吃")))
+}
+
+func TestTokenizeLatin(t *testing.T) {
+	assert.Equal(t, []string{"bánh mì", "rất", "ngon", "", "nhân viên", "thân thiện"}, texts(tokenize("Bánh mì rất ngon, nhân viên thân thiện")))
+	assert.Equal(t, []string{"the", "croissant", "weren't", "fresh"}, texts(tokenize("The croissants weren’t fresh")))
+	assert.Equal(t, []string{"fresh", "bread", "", "cookie", "pastry"}, texts(tokenize("ＦＲＥＳＨ bread; cookies 5 pastries")))
+}
+
+func TestSentiment(t *testing.T) {
+	cases := []struct {
+		text string
+		sign int
+	}{
+		{"Very friendly staff and fresh bread!", 1},
+		{"The croissants were not fresh", -1},
+		{"Not bad, fresh and cheap", 1},
+		{"麵包很新鮮，店員很親切", 1},
+		{"麵包不好吃", -1},
+		{"不錯", 1},
+		{"Bánh mì rất ngon", 1},
+		{"Bánh mì không ngon, chờ lâu", -1},
+		{"Picked up at 6pm", 0},
+	}
+	for _, tc := range cases {
+		s := Sentiment(tc.text)
+		switch tc.sign {
+		case 1:
+			assert.Greater(t, s, SentimentThreshold, tc.text)
+		case -1:
+			assert.Less(t, s, -SentimentThreshold, tc.text)
+		default:
+			assert.Equal(t, 0.0, s, tc.text)
+		}
+		assert.True(t, s > -1 && s < 1, tc.text)
+	}
+	assert.Greater(t, Sentiment("very fresh"), Sentiment("fresh"))
+}
+
+func TestAnalyze(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Taipei")
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, loc)
+	reviews := []Review{
+		{ID: 1, Text: "Fresh bread, friendly staff.", CreatedAt: now.AddDate(0, -2, 0)},
+		{ID: 2, Text: "The fresh bread was gone by 8pm. Staff were rude!", CreatedAt: now.AddDate(0, -1, 0)},
+		{ID: 3, Text: "Stale bread. Fresh bread fresh bread!", CreatedAt: now.AddDate(0, 0, -1)},
+		{ID: 4, Text: "麵包很新鮮，店員很親切", CreatedAt: now.AddDate(0, 0, -2)},
+		{ID: 5, Text: "麵包不新鮮", CreatedAt: now.AddDate(0, 0, -3)},
+		{ID: 6, Text: "   ", CreatedAt: now},
+	}
+	r := Analyze(reviews, loc, now)
+	assert.Equal(t, 5, r.ReviewCount)
+
+	byTerm := map[string]Mention{}
+	for _, m := range r.Keywords {
+		byTerm[m.Term] = m
+	}
+	bread := byTerm["bread"]
+	assert.Equal(t, 3, bread.Reviews)
+	require.Len(t, bread.Snippets, 3)
+	assert.Equal(t, 3, bread.Snippets[0].ReviewID, "newest first")
+	assert.Equal(t, "Stale bread", bread.Snippets[0].Text)
+	assert.Equal(t, 1, bread.Negative)
+	assert.Equal(t, 2, bread.Positive)
+	assert.Equal(t, 2, byTerm["麵包"].Reviews)
+	assert.Equal(t, 2, byTerm["staff"].Reviews)
+	assert.NotContains(t, byTerm, "the")
+
+	require.NotEmpty(t, r.Phrases)
+	assert.Equal(t, "fresh bread", r.Phrases[0].Term)
+	assert.Equal(t, 3, r.Phrases[0].Reviews)
+
+	require.Len(t, r.Trend, TrendMonths)
+	assert.Equal(t, "2025-07", r.Trend[0].Month)
+	june := r.Trend[TrendMonths-1]
+	assert.Equal(t, "2026-06", june.Month)
+	assert.Equal(t, 3, june.Reviews)
+	assert.Equal(t, 2, june.Positive+june.Neutral)
+	assert.Equal(t, 1, june.Negative)
+	assert.Equal(t, 0, r.Trend[0].Reviews)
+}
+
+func TestTrimSnippet(t *testing.T) {
+	long := ""
+	for i := 0; i < 31; i++ {
+		long += "好吃好吃"
+	}
+	s := []rune(trimSnippet(long))
+	assert.Len(t, s, MaxSnippetRunes)
+	assert.Equal(t, '…', s[len(s)-1])
+}
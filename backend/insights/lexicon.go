@@ -0,0 +1,107 @@
+package insights
+
+// Word lists for the languages reviews are written in: Chinese (traditional
+// and simplified), English and Vietnamese. They lean towards what people
+// say about surplus food, pickup and shops.
+
+// hanDictionary holds multi-character Chinese words for segmentation,
+// besides those in the sentiment and function-word lists.
+var hanDictionary = []string{
+	// Food
+	"麵包", "面包", "吐司", "便當", "便当", "蛋糕", "飯糰", "饭团", "壽司", "寿司", "沙拉", "三明治",
+	"咖啡", "甜點", "甜点", "點心", "点心", "餅乾", "饼干", "水果", "蔬菜", "熟食", "炸雞", "炸鸡",
+	"蛋塔", "可頌", "可颂", "貝果", "贝果", "披薩", "披萨", "飲料", "饮料", "豆漿", "豆浆", "牛奶",
+	"食物", "餐點", "餐点", "口味", "味道", "口感", "份量", "分量", "種類", "种类", "菜色",
+	// Price and value
+	"價格", "价格", "價錢", "价钱", "折扣", "優惠", "优惠", "性價比", "性价比", "原價", "原价",
+	// Service and shop
+	"服務", "服务", "店員", "店员", "老闆", "老板", "態度", "态度", "環境", "环境", "店家", "店面",
+	"包裝", "包装", "取餐", "排隊", "排队", "等待", "時間", "时间", "地點", "地点", "停車", "停车",
+	"營業", "营业", "惜食", "剩食", "盲盒", "驚喜包", "惊喜包",
+	// Other common words
+	"下次", "再來", "再来", "還會", "还会", "回購", "回购", "每次", "第一次", "晚上", "早上",
+}
+
+// stopwords are words too common to be worth reporting.
+var stopwords = map[string]bool{
+	// English
+	"a": true, "an": true, "the": true, "and": true, "or": true, "but": true, "if": true, "so": true,
+	"of": true, "to": true, "in": true, "on": true, "at": true, "for": true, "with": true, "from": true,
+	"by": true, "as": true, "is": true, "are": true, "was": true, "were": true, "be": true, "been": true,
+	"am": true, "it": true, "its": true, "it's": true, "this": true, "that": true, "these": true, "those": true,
+	"i": true, "i'm": true, "me": true, "my": true, "we": true, "our": true, "you": true, "your": true,
+	"they": true, "them": true, "their": true, "he": true, "she": true, "his": true, "her": true,
+	"have": true, "has": true, "had": true, "do": true, "does": true, "did": true, "will": true,
+	"would": true, "can": true, "could": true, "just": true, "also": true, "there": true, "here": true,
+	"what": true, "which": true, "who": true, "when": true, "then": true, "than": true, "get": true,
+	"got": true, "one": true, "all": true, "some": true, "any": true, "more": true, "much": true,
+	"again": true, "about": true, "out": true, "up": true, "time": true, "today": true, "lot": true,
+	"thing": true, "things": true, "even": true, "only": true, "still": true, "because": true,
+	// Vietnamese
+	"và": true, "là": true, "có": true, "của": true, "cho": true, "thì": true, "mà": true, "này": true,
+	"được": true, "với": true, "các": true, "những": true, "một": true, "đã": true, "lại": true,
+	"nên": true, "cũng": true, "khi": true, "ở": true, "tôi": true, "mình": true, "em": true,
+	"anh": true, "chị": true, "bạn": true, "nó": true, "họ": true, "ạ": true, "nha": true, "nhé": true,
+	"hơn": true, "vẫn": true, "đi": true, "ra": true, "vào": true, "về": true, "như": true, "để": true,
+	"từ": true, "trong": true, "hôm nay": true, "lần": true, "đây": true, "đó": true, "nhưng": true,
+	// Chinese
+	"我們": true, "我们": true, "這個": true, "这个": true, "那個": true, "那个": true, "就是": true,
+	"還是": true, "还是": true, "因為": true, "因为": true, "所以": true, "但是": true, "可以": true,
+	"真的": true, "覺得": true, "觉得": true, "一個": true, "一个": true, "有點": true, "有点": true,
+	"今天": true, "這次": true, "这次": true, "而且": true, "然後": true, "然后": true, "東西": true,
+	"东西": true, "什麼": true, "什么": true, "已經": true, "已经": true, "比較": true, "比较": true,
+}
+
+// negations flip the sentiment of the next few words.
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "don't": true, "doesn't": true, "didn't": true,
+	"isn't": true, "wasn't": true, "aren't": true, "weren't": true, "won't": true, "can't": true,
+	"couldn't": true, "wouldn't": true, "hardly": true,
+	"không": true, "chẳng": true, "chưa": true, "đừng": true,
+	"不": true, "沒": true, "没": true, "沒有": true, "没有": true, "別": true, "别": true,
+	"不太": true, "不會": true, "不会": true, "不夠": true, "不够": true,
+}
+
+// intensifiers strengthen the next sentiment word.
+var intensifiers = map[string]bool{
+	"very": true, "really": true, "so": true, "super": true, "extremely": true, "too": true,
+	"rất": true, "quá": true, "lắm": true, "cực": true, "siêu": true,
+	"很": true, "非常": true, "超": true, "真": true, "太": true, "特別": true, "特别": true, "超級": true, "超级": true,
+}
+
+// sentimentLexicon scores words from -3 (very negative) to 3.
+var sentimentLexicon = map[string]float64{
+	// English
+	"good": 2, "great": 3, "excellent": 3, "amazing": 3, "awesome": 3, "delicious": 3, "tasty": 2,
+	"fresh": 2, "friendly": 2, "nice": 2, "love": 3, "loved": 3, "recommend": 2, "clean": 2,
+	"cheap": 1, "fast": 1, "quick": 1, "helpful": 2, "kind": 2, "perfect": 3, "happy": 2,
+	"satisfied": 2, "generous": 2, "worth": 1, "yummy": 2, "polite": 2,
+	"bad": -2, "terrible": -3, "awful": -3, "horrible": -3, "stale": -2, "rude": -3, "dirty": -2,
+	"expensive": -1, "slow": -1, "late": -2, "cold": -1, "soggy": -2, "disappointed": -2,
+	"disappointing": -2, "worst": -3, "poor": -2, "wrong": -1, "missing": -2, "expired": -3,
+	"spoiled": -3, "salty": -1, "bland": -1, "waste": -2, "overpriced": -2, "unfriendly": -2,
+	"moldy": -3, "mouldy": -3, "sick": -3,
+	// Vietnamese
+	"ngon": 2, "tươi": 2, "tươi ngon": 3, "tuyệt vời": 3, "thân thiện": 2, "nhiệt tình": 2,
+	"sạch sẽ": 2, "rẻ": 1, "hài lòng": 2, "tốt": 2, "thích": 2, "ủng hộ": 2, "dễ thương": 2,
+	"dở": -2, "tệ": -3, "thất vọng": -2, "đắt": -1, "chậm": -1, "bẩn": -2, "quá hạn": -3,
+	"hỏng": -3, "chờ lâu": -2, "nguội": -1, "thiu": -3, "mặn": -1,
+	// Chinese
+	"好吃": 2, "美味": 3, "新鮮": 2, "新鲜": 2, "不錯": 2, "不错": 2, "很棒": 3, "超棒": 3, "棒": 2,
+	"讚": 2, "赞": 2, "親切": 2, "亲切": 2, "熱情": 2, "热情": 2, "乾淨": 2, "干净": 2, "划算": 2,
+	"便宜": 1, "滿意": 2, "满意": 2, "喜歡": 2, "喜欢": 2, "推薦": 2, "推荐": 2, "驚喜": 2, "惊喜": 2,
+	"準時": 1, "准时": 1, "實惠": 2, "实惠": 2, "貼心": 2, "贴心": 2, "用心": 2,
+	"難吃": -3, "难吃": -3, "不新鮮": -3, "不新鲜": -3, "過期": -3, "过期": -3, "失望": -2,
+	"糟糕": -3, "冷淡": -2, "髒": -2, "脏": -2, "遲到": -2, "迟到": -2, "太甜": -1, "太鹹": -1,
+	"太咸": -1, "浪費": -2, "浪费": -2, "等很久": -2, "貴": -1, "贵": -1, "差": -2, "爛": -3,
+	"烂": -3, "發霉": -3, "发霉": -3, "壞掉": -3, "坏掉": -3, "酸掉": -3, "不耐煩": -2, "不耐烦": -2,
+}
+
+// viCompounds are Vietnamese words written as several syllables, joined
+// before counting so "bánh mì" is not counted as "bánh" and "mì".
+var viCompounds = []string{
+	"bánh mì", "bánh ngọt", "bánh bao", "nhân viên", "phục vụ", "giá cả", "đồ ăn", "thức ăn",
+	"món ăn", "ngon miệng", "chất lượng", "đóng gói", "cửa hàng", "giảm giá", "lần sau", "cà phê",
+	"rau củ", "trái cây", "hoa quả", "đúng giờ", "hôm nay", "khẩu phần", "hương vị", "thái độ",
+	"chủ quán", "quán ăn", "giờ lấy", "xôi gà", "cơm tấm",
+}
@@ -0,0 +1,209 @@
+package insights
+
+import (
+	"strings"
+	"unicode"
+)
+
+// token is a word of a review: a segmented Chinese word or a Latin-script
+// word, with Vietnamese compounds already joined. Punctuation inside a
+// sentence becomes an empty token, ending the clause.
+type token struct {
+	Text string
+	Han  bool
+}
+
+const maxHanWordRunes = 4
+
+var hanWords, viCompoundSet = buildDictionaries()
+
+func buildDictionaries() (map[string]bool, map[string]bool) {
+	han := map[string]bool{}
+	addHan := func(w string) {
+		for _, r := range w {
+			if !unicode.Is(unicode.Han, r) {
+				return
+			}
+		}
+		han[w] = true
+	}
+	for _, w := range hanDictionary {
+		addHan(w)
+	}
+	for _, set := range []map[string]bool{stopwords, negations, intensifiers} {
+		for w := range set {
+			addHan(w)
+		}
+	}
+	for w := range sentimentLexicon {
+		addHan(w)
+	}
+
+	vi := map[string]bool{}
+	for _, w := range viCompounds {
+		vi[w] = true
+	}
+	for w := range sentimentLexicon {
+		if strings.Contains(w, " ") {
+			vi[w] = true
+		}
+	}
+	return han, vi
+}
+
+// segmentHan splits a run of Chinese characters into words by forward
+// maximum matching against the dictionary; characters that start no known
+// word become single-character tokens.
+func segmentHan(run []rune) []string {
+	var words []string
+	for i := 0; i < len(run); {
+		n := min(maxHanWordRunes, len(run)-i)
+		for ; n > 1; n-- {
+			if hanWords[string(run[i:i+n])] {
+				break
+			}
+		}
+		words = append(words, string(run[i:i+n]))
+		i += n
+	}
+	return words
+}
+
+// tokenize splits a sentence into tokens, lowercased with full-width
+// characters folded.
+func tokenize(text string) []token {
+	var tokens []token
+	var han, latin []rune
+	flushHan := func() {
+		for _, w := range segmentHan(han) {
+			tokens = append(tokens, token{Text: w, Han: true})
+		}
+		han = han[:0]
+	}
+	var words []string
+	flushLatin := func() {
+		if w := strings.Trim(string(latin), "'"); w != "" && strings.IndexFunc(w, unicode.IsLetter) >= 0 {
+			words = append(words, w)
+		}
+		latin = latin[:0]
+	}
+	flushWords := func() {
+		for _, w := range joinCompounds(words) {
+			tokens = append(tokens, token{Text: singular(w)})
+		}
+		words = words[:0]
+	}
+
+	for _, r := range foldWidth(strings.ToLower(text)) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushLatin()
+			flushWords()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			flushHan()
+			latin = append(latin, r)
+		case r == '\'' || r == '’':
+			latin = append(latin, '\'')
+		case unicode.IsSpace(r):
+			flushHan()
+			flushLatin() // Spaces separate words but not compounds
+		default:
+			flushHan()
+			flushLatin()
+			flushWords()
+			if n := len(tokens); n > 0 && tokens[n-1].Text != "" {
+				tokens = append(tokens, token{})
+			}
+		}
+	}
+	flushHan()
+	flushLatin()
+	flushWords()
+	return tokens
+}
+
+// joinCompounds joins runs of space-separated Vietnamese syllables that form
+// a known word, longest first.
+func joinCompounds(words []string) []string {
+	var out []string
+	for i := 0; i < len(words); {
+		n := 1
+		for k := min(3, len(words)-i); k > 1; k-- {
+			if viCompoundSet[strings.Join(words[i:i+k], " ")] {
+				n = k
+				break
+			}
+		}
+		out = append(out, strings.Join(words[i:i+n], " "))
+		i += n
+	}
+	return out
+}
+
+// ieNouns are foods whose singular ends in -ie, so their -ies plural is not
+// folded to -y like "pastries".
+var ieNouns = map[string]bool{"cookie": true, "pie": true, "brownie": true, "smoothie": true, "veggie": true}
+
+// singular folds simple English plurals so "croissants" counts with
+// "croissant". Only plain ASCII words are touched.
+func singular(w string) string {
+	for _, r := range w {
+		if r > unicode.MaxASCII || r == '\'' || r == ' ' {
+			return w
+		}
+	}
+	switch {
+	case ieNouns[strings.TrimSuffix(w, "s")]:
+		return w[:len(w)-1]
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") &&
+		!strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "is"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// foldWidth folds full-width ASCII, which CJK input methods produce, to its
+// half-width form.
+func foldWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			return r - 0xFEE0
+		case r == 0x3000:
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// isTerm reports whether a token is worth counting as a keyword.
+func isTerm(t token) bool {
+	if stopwords[t.Text] || negations[t.Text] || intensifiers[t.Text] {
+		return false
+	}
+	n := len([]rune(t.Text))
+	if t.Han {
+		return n >= 2
+	}
+	if strings.IndexFunc(t.Text, unicode.IsLetter) < 0 {
+		return false
+	}
+	return n >= 3 || strings.Contains(t.Text, " ") || sentimentLexicon[t.Text] != 0
+}
+
+// splitSentences breaks a review into sentences at end punctuation and
+// line breaks, in either script.
+func splitSentences(text string) []string {
+	var out []string
+	for _, s := range strings.FieldsFunc(text, func(r rune) bool {
+		return strings.ContainsRune(".!?;\n。！？；…", r)
+	}) {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
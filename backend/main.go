@@ -2,14 +2,42 @@ package main
 
 import (
 	"food-platform-backend/db"
+	"food-platform-backend/einvoice"
 	"food-platform-backend/handlers"
+	"food-platform-backend/media"
+	"food-platform-backend/moderation"
+	"food-platform-backend/payments"
+	"log"
 	"os"
+	"strings"
+	"time"
+	_ "time/tzdata" // Merchant timezones on minimal container images
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	db.InitDB()
+	log.Println("Payment providers:", payments.RegisterFromEnv())
+	handlers.TrackPaymentTransfers()
+	contentFilter, err := moderation.FromEnv()
+	if err != nil {
+		log.Fatal("Content filter word lists: ", err)
+	}
+	handlers.SetContentFilter(contentFilter)
+	imageStore := media.FromEnv()
+	handlers.SetImageStore(imageStore)
+
+	// Background jobs
+	handlers.StartListingScheduler(time.Minute)
+	handlers.StartReservationReaper(30 * time.Second)
+	handlers.StartPickupReminders(time.Minute)
+	handlers.StartIdempotencySweeper(time.Hour)
+	handlers.StartPaymentReconciler(time.Minute)
+	handlers.StartInvoiceSubmitter(einvoice.FromEnv(), time.Minute)
+	handlers.StartNoShowSweeper(5 * time.Minute)
+	handlers.StartReviewFraudScanner(15 * time.Minute)
+	handlers.StartReviewInsightsJob(time.Hour)
 
 	r := gin.Default()
 
@@ -31,13 +59,90 @@ func main() {
 	})
 	// =========================================================================
 
+	// Uploaded images, when stored on local disk for development
+	if local, ok := imageStore.(*media.LocalStore); ok {
+		if os.Getenv("K_SERVICE") != "" {
+			log.Println("Warning: uploads are on local disk; set UPLOAD_BUCKET on Cloud Run")
+		}
+		if strings.HasPrefix(local.BaseURL, "/") {
+			r.Static(local.BaseURL, local.Dir)
+		}
+	}
+
 	// Helper to seed data easily
 	r.POST("/seed", handlers.SeedData)
 
 	// Products
 	r.GET("/products", handlers.GetProducts)
-	r.POST("/products", handlers.CreateProduct)
-	r.POST("/purchase/:id", handlers.PurchaseProduct)
+	r.POST("/products", handlers.Idempotent(), handlers.CreateProduct)
+	r.POST("/purchase/:id", handlers.AuthRequired(), handlers.Idempotent(), handlers.PurchaseProduct)
+	r.POST("/products/:id/reserve", handlers.AuthRequired(), handlers.ReserveProduct)
+	r.GET("/products/:id/pickup-slots", handlers.GetProductPickupSlots)
+	r.POST("/checkout", handlers.AuthRequired(), handlers.Idempotent(), handlers.Checkout)
+	r.POST("/reservations/:id/confirm", handlers.AuthRequired(), handlers.ConfirmReservation)
+	r.POST("/reservations/:id/release", handlers.AuthRequired(), handlers.ReleaseReservation)
+	r.POST("/products/import", handlers.AuthRequired(), handlers.ImportProducts)
+	r.GET("/products/export", handlers.AuthRequired(), handlers.ExportProducts)
+	r.GET("/listing-rules", handlers.GetListingRules)
+
+	// Order Lifecycle (Bearer token)
+	r.POST("/orders/:id/cancel", handlers.AuthRequired(), handlers.CancelOrder)
+	r.PUT("/orders/:id/pickup-slot", handlers.AuthRequired(), handlers.ChangePickupSlot)
+	r.POST("/merchant/orders/:id/confirm", handlers.AuthRequired(), handlers.ConfirmOrder)
+	r.POST("/merchant/orders/:id/ready", handlers.AuthRequired(), handlers.MarkOrderReady)
+	r.POST("/merchant/orders/:id/picked-up", handlers.AuthRequired(), handlers.MarkOrderPickedUp)
+	r.POST("/merchant/orders/:id/cancel", handlers.AuthRequired(), handlers.MerchantCancelOrder)
+	r.POST("/merchant/orders/:id/no-show", handlers.AuthRequired(), handlers.MarkOrderNoShow)
+
+	// Payments
+	r.POST("/orders/:id/pay", handlers.AuthRequired(), handlers.Idempotent(), handlers.PayOrder)
+	r.POST("/payments/webhook/:provider", handlers.PaymentWebhook)
+	r.GET("/payments/:provider/return", handlers.PaymentReturn)
+
+	// Order History (Bearer token)
+	r.GET("/me/orders", handlers.AuthRequired(), handlers.GetMyOrders)
+	r.GET("/merchant/orders", handlers.AuthRequired(), handlers.GetMerchantOrders)
+
+	// Receipts & E-Invoices (Bearer token)
+	r.GET("/me/orders/:id/receipt", handlers.AuthRequired(), handlers.GetMyReceipt)
+	r.GET("/merchant/orders/:id/receipt", handlers.AuthRequired(), handlers.GetMerchantReceipt)
+	r.GET("/me/invoice-carrier", handlers.AuthRequired(), handlers.GetInvoiceCarrier)
+	r.PUT("/me/invoice-carrier", handlers.AuthRequired(), handlers.UpdateInvoiceCarrier)
+	r.PUT("/merchant/invoice-settings", handlers.AuthRequired(), handlers.UpdateInvoiceSettings)
+
+	// No-Shows & Reliability (Bearer token)
+	r.GET("/me/reliability", handlers.AuthRequired(), handlers.GetMyReliability)
+	r.POST("/me/orders/:id/no-show-dispute", handlers.AuthRequired(), handlers.DisputeNoShow)
+	r.GET("/merchant/no-show-disputes", handlers.AuthRequired(), handlers.GetMerchantNoShowDisputes)
+	r.POST("/merchant/no-show-disputes/:id/respond", handlers.AuthRequired(), handlers.RespondNoShowDispute)
+
+	// Cancellation Policy & Reporting (Bearer token for the merchant's own)
+	r.GET("/merchant/:merchant_id/cancellation-policy", handlers.GetCancellationPolicy)
+	r.PUT("/merchant/cancellation-policy", handlers.AuthRequired(), handlers.UpdateCancellationPolicy)
+	r.GET("/merchant/cancellations/report", handlers.AuthRequired(), handlers.GetCancellationReport)
+
+	// Purchase Limits (Bearer token for the merchant's own)
+	r.GET("/merchant/:merchant_id/purchase-limits", handlers.GetPurchaseLimits)
+	r.PUT("/merchant/purchase-limits", handlers.AuthRequired(), handlers.UpdatePurchaseLimits)
+
+	// Pickup Verification (Bearer token)
+	r.GET("/me/orders/:id/pickup-pass", handlers.AuthRequired(), handlers.GetPickupPass)
+	r.GET("/pickup/public-key", handlers.GetPickupPublicKey)
+	r.POST("/merchant/pickup/lookup", handlers.AuthRequired(), handlers.LookupPickupPass)
+	r.POST("/merchant/pickup/redeem", handlers.AuthRequired(), handlers.RedeemPickup)
+
+	// Pickup Slots (Bearer token)
+	r.POST("/merchant/pickup-slots", handlers.AuthRequired(), handlers.GeneratePickupSlots)
+	r.GET("/merchant/pickup-slots", handlers.AuthRequired(), handlers.GetMerchantPickupSlots)
+	r.GET("/merchant/pickup-slots/:id/manifest", handlers.AuthRequired(), handlers.GetPickupSlotManifest)
+
+	// Recurring Listing Schedules (Bearer token)
+	r.POST("/merchant/schedules", handlers.AuthRequired(), handlers.CreateListingSchedule)
+	r.GET("/merchant/schedules", handlers.AuthRequired(), handlers.GetListingSchedules)
+	r.PUT("/merchant/schedules/:id/pause", handlers.AuthRequired(), handlers.PauseListingSchedule)
+	r.PUT("/merchant/schedules/:id/resume", handlers.AuthRequired(), handlers.ResumeListingSchedule)
+	r.POST("/merchant/schedules/:id/skip", handlers.AuthRequired(), handlers.SkipListingSchedule)
+	r.DELETE("/merchant/schedules/:id/skip/:date", handlers.AuthRequired(), handlers.UnskipListingSchedule)
 
 	// Auth Routes
 	r.POST("/login", handlers.Login)
@@ -51,9 +156,17 @@ func main() {
 	// NEW ROUTES: Social Features
 	// =========================================================================
 
-	// Reviews
-	r.POST("/reviews", handlers.CreateReview)
+	// Reviews (Bearer token to post)
+	r.POST("/reviews", handlers.AuthRequired(), handlers.Idempotent(), handlers.CreateReview)
 	r.GET("/reviews/merchant/:merchant_id", handlers.GetMerchantReviews)
+	r.PUT("/merchant/reviews/:id/reply", handlers.AuthRequired(), handlers.ReplyToReview)
+	r.DELETE("/merchant/reviews/:id/reply", handlers.AuthRequired(), handlers.DeleteReviewReply)
+	r.GET("/merchant/insights", handlers.AuthRequired(), handlers.GetMerchantInsights)
+	r.POST("/reviews/:id/report", handlers.AuthRequired(), handlers.ReportReview)
+	r.POST("/reviews/:id/photos", handlers.AuthRequired(), handlers.UploadReviewPhotos)
+	r.DELETE("/reviews/:id/photos/:photo_id", handlers.AuthRequired(), handlers.DeleteReviewPhoto)
+	r.PUT("/reviews/:id/vote", handlers.AuthRequired(), handlers.VoteReview)
+	r.DELETE("/reviews/:id/vote", handlers.AuthRequired(), handlers.WithdrawReviewVote)
 
 	// Favorites
 	r.POST("/favorites/toggle", handlers.ToggleFavorite)
@@ -62,13 +175,24 @@ func main() {
 
 	// Notifications
 	r.GET("/notifications/:user_id", handlers.GetNotifications)
-	r.PUT("/notifications/:id/read", handlers.MarkNotificationRead)
-	r.POST("/notifications", handlers.CreateNotification)
+	r.PUT("/notifications/:id/read", handlers.Idempotent(), handlers.MarkNotificationRead)
+	r.POST("/notifications", handlers.Idempotent(), handlers.CreateNotification)
 
 	// Merchant Details & Search
 	r.GET("/merchant/:merchant_id", handlers.GetMerchantDetails)
 	r.GET("/merchants/search", handlers.SearchMerchants)
 
+	// =========================================================================
+	// ADMIN ROUTES (X-Admin-Key)
+	// =========================================================================
+	admin := r.Group("/admin", handlers.AdminRequired())
+	admin.PUT("/listing-rules/:category", handlers.UpdateListingRules)
+	admin.GET("/no-show-disputes", handlers.GetNoShowDisputes)
+	admin.PUT("/no-show-disputes/:id", handlers.ResolveNoShowDispute)
+	admin.GET("/reviews/moderation", handlers.GetModerationQueue)
+	admin.PUT("/reviews/:id/moderation", handlers.ModerateReview)
+	admin.PUT("/reviews/photos/:id/moderation", handlers.ModerateReviewPhoto)
+
 	// Listen on PORT provided by Cloud Run, or default to 8080
 	port := os.Getenv("PORT")
 	if port == "" {
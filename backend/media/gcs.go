@@ -0,0 +1,143 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GCSStore keeps images in a Google Cloud Storage bucket through the JSON
+// API. It authenticates as the service account of the Cloud Run service, so
+// the bucket must grant it object create and delete. Objects are served from
+// BaseURL, the bucket's public URL unless a CDN fronts it.
+type GCSStore struct {
+	Bucket  string
+	BaseURL string
+
+	apiURL   string
+	tokenURL string
+	client   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+const (
+	gcsAPIURL   = "https://storage.googleapis.com"
+	gcsTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// NewGCSStore returns a store for bucket. baseURL defaults to the bucket's
+// public URL.
+func NewGCSStore(bucket, baseURL string) *GCSStore {
+	if baseURL == "" {
+		baseURL = gcsAPIURL + "/" + bucket
+	}
+	return &GCSStore{
+		Bucket:   bucket,
+		BaseURL:  baseURL,
+		apiURL:   gcsAPIURL,
+		tokenURL: gcsTokenURL,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// objectName cleans key the way LocalStore does, so both stores use the
+// same names.
+func objectName(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+func (s *GCSStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	name := objectName(key)
+	uri := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		s.apiURL, url.PathEscape(s.Bucket), url.QueryEscape(name))
+	resp, err := s.do(ctx, http.MethodPost, uri, bytes.NewReader(data), contentType)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("gcs upload %s: %d", name, resp.StatusCode)
+	}
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + name, nil
+}
+
+func (s *GCSStore) Delete(ctx context.Context, key string) error {
+	name := objectName(key)
+	uri := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.apiURL, url.PathEscape(s.Bucket), url.PathEscape(name))
+	resp, err := s.do(ctx, http.MethodDelete, uri, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gcs delete %s: %d", name, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *GCSStore) do(ctx context.Context, method, uri string, body io.Reader, contentType string) (*http.Response, error) {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return s.client.Do(req)
+}
+
+// accessToken returns the service account's token from the metadata server,
+// cached until shortly before it expires.
+func (s *GCSStore) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("gcs access token: %d", resp.StatusCode)
+	}
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.AccessToken == "" {
+		return "", fmt.Errorf("gcs access token: empty")
+	}
+	s.token = t.AccessToken
+	s.expires = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
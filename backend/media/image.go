@@ -0,0 +1,128 @@
+// Package media validates, normalises and stores user-uploaded images.
+// Every upload is decoded and re-encoded, which drops metadata such as GPS
+// coordinates, and gets a thumbnail.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	_ "image/gif" // Decoders for accepted formats
+	_ "image/png"
+)
+
+var (
+	ErrTooLarge          = errors.New("image is too large")
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrBadDimensions     = errors.New("image dimensions out of range")
+)
+
+// Limits for accepted uploads and the sizes images are stored at.
+const (
+	MaxUploadBytes  = 8 << 20
+	MaxSourcePixels = 40_000_000 // Checked before decoding, against decompression bombs
+	MinDimension    = 64
+	FullDimension   = 1600
+	ThumbDimension  = 320
+	jpegQuality     = 85
+)
+
+var acceptedTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true}
+
+// Processed is an upload re-encoded as JPEG at full and thumbnail size.
+type Processed struct {
+	Full        []byte
+	Thumb       []byte
+	Width       int
+	Height      int
+	ContentType string
+}
+
+// Process validates an uploaded image and produces its stored versions.
+func Process(data []byte) (Processed, error) {
+	if len(data) > MaxUploadBytes {
+		return Processed{}, ErrTooLarge
+	}
+	if !acceptedTypes[http.DetectContentType(data)] {
+		return Processed{}, ErrUnsupportedFormat
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedFormat
+	}
+	if cfg.Width < MinDimension || cfg.Height < MinDimension || cfg.Width*cfg.Height > MaxSourcePixels {
+		return Processed{}, fmt.Errorf("%w: %dx%d", ErrBadDimensions, cfg.Width, cfg.Height)
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedFormat
+	}
+	if format == "jpeg" {
+		src = applyOrientation(src, jpegOrientation(data))
+	}
+
+	full := Resize(src, FullDimension)
+	p := Processed{Width: full.Bounds().Dx(), Height: full.Bounds().Dy(), ContentType: "image/jpeg"}
+	if p.Full, err = encodeJPEG(full); err != nil {
+		return Processed{}, err
+	}
+	if p.Thumb, err = encodeJPEG(Resize(full, ThumbDimension)); err != nil {
+		return Processed{}, err
+	}
+	return p, nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	// Flatten transparency onto white; JPEG has no alpha
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Resize scales img down so neither side exceeds maxDim, averaging the
+// source pixels under each output pixel. Smaller images are returned as is.
+func Resize(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxDim && h <= maxDim {
+		return img
+	}
+	dw, dh := maxDim, h*maxDim/w
+	if h > w {
+		dw, dh = w*maxDim/h, maxDim
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
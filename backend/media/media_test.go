@@ -0,0 +1,202 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF APP1 segment carrying orientation after
+// the JPEG's SOI marker.
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	payload := append(append([]byte("Exif\x00\x00"), tiff...), append(entry, 0, 0, 0, 0)...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	out := append([]byte{}, jpg[:2]...)
+	out = append(append(out, seg...), payload...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcessResizesAndThumbnails(t *testing.T) {
+	p, err := Process(encodePNG(t, 2000, 1000))
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", p.ContentType)
+	assert.Equal(t, FullDimension, p.Width)
+	assert.Equal(t, FullDimension/2, p.Height)
+
+	thumb, format, err := image.DecodeConfig(bytes.NewReader(p.Thumb))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, ThumbDimension, thumb.Width)
+	assert.Equal(t, ThumbDimension/2, thumb.Height)
+}
+
+func TestProcessKeepsSmallImages(t *testing.T) {
+	p, err := Process(encodePNG(t, 300, 200))
+	require.NoError(t, err)
+	assert.Equal(t, 300, p.Width)
+	assert.Equal(t, 200, p.Height)
+}
+
+func TestProcessRejects(t *testing.T) {
+	_, err := Process([]byte("<html>not an image</html>"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, err = Process(encodePNG(t, 32, 32))
+	assert.ErrorIs(t, err, ErrBadDimensions)
+
+	_, err = Process(make([]byte, MaxUploadBytes+1))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// Claims to be a PNG but is cut short
+	_, err = Process(encodePNG(t, 100, 100)[:60])
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestProcessAppliesOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 200, 100)), nil))
+	assert.Equal(t, 1, jpegOrientation(buf.Bytes()))
+
+	rotated := withOrientation(buf.Bytes(), 6)
+	assert.Equal(t, 6, jpegOrientation(rotated))
+	p, err := Process(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, 100, p.Width)
+	assert.Equal(t, 200, p.Height)
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 2x1: red then blue
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	cw := applyOrientation(src, 6)
+	assert.Equal(t, image.Rect(0, 0, 1, 2), cw.Bounds())
+	assert.Equal(t, red, cw.At(0, 0))
+	assert.Equal(t, blue, cw.At(0, 1))
+
+	ccw := applyOrientation(src, 8)
+	assert.Equal(t, blue, ccw.At(0, 0))
+
+	flipped := applyOrientation(src, 3)
+	assert.Equal(t, blue, flipped.At(0, 0))
+}
+
+func TestResizeAveragesPixels(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.RGBA{A: 255})
+	src.Set(1, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	src.Set(0, 1, color.RGBA{A: 255})
+	src.Set(1, 1, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	dst := Resize(src, 1)
+	r, _, _, _ := dst.At(0, 0).RGBA()
+	assert.InDelta(t, 127, r>>8, 1)
+}
+
+func TestLocalStore(t *testing.T) {
+	s := &LocalStore{Dir: t.TempDir(), BaseURL: "/uploads/"}
+	url, err := s.Put(context.Background(), "reviews/1/abc.jpg", []byte("x"), "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, "/uploads/reviews/1/abc.jpg", url)
+	data, err := os.ReadFile(filepath.Join(s.Dir, "reviews", "1", "abc.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(data))
+
+	require.NoError(t, s.Delete(context.Background(), "reviews/1/abc.jpg"))
+	require.NoError(t, s.Delete(context.Background(), "reviews/1/abc.jpg"))
+
+	// Keys cannot escape the directory
+	assert.Equal(t, filepath.Join(s.Dir, "etc", "passwd"), s.path("../../etc/passwd"))
+}
+
+func TestGCSStore(t *testing.T) {
+	objects := map[string][]byte{}
+	tokens := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			tokens++
+			w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+			return
+		case r.Header.Get("Authorization") != "Bearer tok":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/photos/o":
+			assert.Equal(t, "media", r.URL.Query().Get("uploadType"))
+			assert.Equal(t, "image/jpeg", r.Header.Get("Content-Type"))
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Query().Get("name")] = data
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/storage/v1/b/photos/o/"):
+			name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/photos/o/")
+			if _, ok := objects[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(objects, name)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewGCSStore("photos", "")
+	s.apiURL, s.tokenURL = srv.URL, srv.URL+"/token"
+
+	url, err := s.Put(context.Background(), "/reviews/1/abc.jpg", []byte("x"), "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, "https://storage.googleapis.com/photos/reviews/1/abc.jpg", url)
+	assert.Equal(t, "x", string(objects["reviews/1/abc.jpg"]))
+
+	require.NoError(t, s.Delete(context.Background(), "reviews/1/abc.jpg"))
+	require.NoError(t, s.Delete(context.Background(), "reviews/1/abc.jpg"))
+	assert.Empty(t, objects)
+	assert.Equal(t, 1, tokens, "the access token is cached")
+}
+
+func TestStoreFromEnv(t *testing.T) {
+	t.Setenv("UPLOAD_BUCKET", "")
+	t.Setenv("UPLOAD_BASE_URL", "")
+	assert.IsType(t, &LocalStore{}, FromEnv())
+
+	t.Setenv("UPLOAD_BUCKET", "photos")
+	t.Setenv("UPLOAD_BASE_URL", "https://cdn.example.com")
+	s, ok := FromEnv().(*GCSStore)
+	require.True(t, ok)
+	assert.Equal(t, "photos", s.Bucket)
+	assert.Equal(t, "https://cdn.example.com", s.BaseURL)
+}
@@ -0,0 +1,69 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store keeps image files and returns the URLs they are served from.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) (url string, err error)
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalStore writes files under Dir and serves them from BaseURL, which the
+// HTTP server maps to Dir when it is a path.
+type LocalStore struct {
+	Dir     string
+	BaseURL string
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + strings.TrimPrefix(key, "/"), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// FromEnv returns the configured store: a Cloud Storage bucket when
+// UPLOAD_BUCKET is set, otherwise the local disk, which only suits development
+// since Cloud Run instances do not share or keep their files.
+//
+//	UPLOAD_BUCKET, UPLOAD_BASE_URL (default the bucket's public URL)
+//	UPLOAD_DIR (default uploads), UPLOAD_BASE_URL (default /uploads)
+func FromEnv() Store {
+	if bucket := os.Getenv("UPLOAD_BUCKET"); bucket != "" {
+		return NewGCSStore(bucket, os.Getenv("UPLOAD_BASE_URL"))
+	}
+	s := &LocalStore{Dir: os.Getenv("UPLOAD_DIR"), BaseURL: os.Getenv("UPLOAD_BASE_URL")}
+	if s.Dir == "" {
+		s.Dir = "uploads"
+	}
+	if s.BaseURL == "" {
+		s.BaseURL = "/uploads"
+	}
+	return s
+}
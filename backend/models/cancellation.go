@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Cancellation reason codes recorded for reporting.
+const (
+	CancelReasonChangedMind      = "changed_mind"
+	CancelReasonCantMakePickup   = "cant_make_pickup"
+	CancelReasonOrderedByMistake = "ordered_by_mistake"
+	CancelReasonDamaged          = "damaged"
+	CancelReasonOutOfStock       = "out_of_stock"
+	CancelReasonShopClosed       = "shop_closed"
+	CancelReasonOther            = "other"
+)
+
+// CancellationPolicy is a merchant's refund policy. Consumers cancelling
+// less than CutoffMinutes before pickup starts are late and get
+// LateRefundPercent instead of EarlyRefundPercent.
+type CancellationPolicy struct {
+	MerchantID         string  `json:"merchant_id"`
+	CutoffMinutes      int     `json:"cutoff_minutes"`
+	EarlyRefundPercent float64 `json:"early_refund_percent"`
+	LateRefundPercent  float64 `json:"late_refund_percent"`
+}
+
+// OrderCancellation records who cancelled an order, why, and the refund due.
+type OrderCancellation struct {
+	ID            int       `json:"id"`
+	OrderID       int       `json:"order_id"`
+	CancelledBy   string    `json:"cancelled_by"` // consumer, merchant
+	ReasonCode    string    `json:"reason_code"`
+	Reason        string    `json:"reason,omitempty"`
+	IsLate        bool      `json:"is_late"`
+	RefundPercent float64   `json:"refund_percent"`
+	RefundAmount  float64   `json:"refund_amount"`
+	RefundStatus  string    `json:"refund_status"`
+	CreatedAt     time.Time `json:"created_at"`
+}
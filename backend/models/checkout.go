@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Checkout is a single purchase of several products from one merchant.
+// Each product becomes its own order linked by CheckoutID.
+type Checkout struct {
+	ID         int       `json:"id"`
+	ConsumerID string    `json:"consumer_id"`
+	MerchantID string    `json:"merchant_id"`
+	Total      float64   `json:"total"`
+	OrderIDs   []int     `json:"order_ids"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CheckoutItemError explains why one cart line could not be bought.
+type CheckoutItemError struct {
+	ProductID int    `json:"product_id"`
+	Error     string `json:"error"`
+}
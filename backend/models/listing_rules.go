@@ -0,0 +1,20 @@
+package models
+
+// ListingRules are the platform constraints a listing must satisfy. The
+// "*" category holds the platform defaults; other categories override them
+// field by field. A zero value disables the corresponding check.
+type ListingRules struct {
+	Category           string  `json:"category"`
+	MinDiscountPercent float64 `json:"min_discount_percent"`
+	MaxLifetimeMinutes int     `json:"max_lifetime_minutes"`
+	PriceFloor         float64 `json:"price_floor"`
+	MaxDistanceMeters  float64 `json:"max_distance_meters"`
+}
+
+// FieldError describes a single invalid request field in a form the
+// frontend can render next to the input.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
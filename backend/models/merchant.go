@@ -14,5 +14,6 @@ type Merchant struct {
 	BusinessHoursClose string    `json:"business_hours_close"`
 	Category           string    `json:"category"`
 	Description        string    `json:"description"`
+	Timezone           string    `json:"timezone"`
 	CreatedAt          time.Time `json:"created_at"`
 }
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Review statuses. Only published reviews are public; pending ones wait for
+// an admin because the content filter or enough reports flagged them, and
+// flagged ones because they look like rating manipulation.
+const (
+	ReviewStatusPublished = "published"
+	ReviewStatusPending   = "pending"
+	ReviewStatusFlagged   = "flagged"
+	ReviewStatusHidden    = "hidden"
+	ReviewStatusDeleted   = "deleted"
+)
+
+// Report reason codes.
+const (
+	ReportReasonSpam         = "spam"
+	ReportReasonOffensive    = "offensive"
+	ReportReasonPersonalInfo = "personal_info"
+	ReportReasonFake         = "fake"
+	ReportReasonOffTopic     = "off_topic"
+	ReportReasonOther        = "other"
+)
+
+// ReviewFlag is something the content filter found in a review.
+type ReviewFlag struct {
+	Rule  string `json:"rule"` // profanity, phone, url, fraud_*
+	Lang  string `json:"lang,omitempty"`
+	Match string `json:"match"`
+}
+
+// ReviewReport is a user's or merchant's complaint about a review.
+type ReviewReport struct {
+	ID         int       `json:"id"`
+	ReviewID   int       `json:"review_id"`
+	ReporterID string    `json:"reporter_id"`
+	ReasonCode string    `json:"reason_code"`
+	Details    string    `json:"details,omitempty"`
+	Status     string    `json:"status"` // open, resolved, dismissed
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ModerationItem is a review in the admin moderation queue.
+type ModerationItem struct {
+	Review
+	Status           string         `json:"status"`
+	Flags            []ReviewFlag   `json:"flags"`
+	OpenReports      int            `json:"open_reports"`
+	Reports          []ReviewReport `json:"reports"`
+	PendingPhotos    int            `json:"pending_photos"`
+	ModerationReason string         `json:"moderation_reason,omitempty"`
+	ModeratedAt      *time.Time     `json:"moderated_at,omitempty"`
+}
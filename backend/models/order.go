@@ -2,9 +2,89 @@ package models
 
 import "time"
 
+type OrderStatus string
+
+const (
+	OrderStatusPending             OrderStatus = "pending"
+	OrderStatusConfirmed           OrderStatus = "confirmed"
+	OrderStatusReadyForPickup      OrderStatus = "ready_for_pickup"
+	OrderStatusPickedUp            OrderStatus = "picked_up"
+	OrderStatusCancelledByConsumer OrderStatus = "cancelled_by_consumer"
+	OrderStatusCancelledByMerchant OrderStatus = "cancelled_by_merchant"
+	OrderStatusNoShow              OrderStatus = "no_show"
+	OrderStatusPaymentFailed       OrderStatus = "payment_failed"
+)
+
+// orderTransitions lists the statuses each status may move to. Statuses
+// without an entry are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending: {
+		OrderStatusConfirmed, OrderStatusCancelledByConsumer, OrderStatusCancelledByMerchant,
+		OrderStatusPaymentFailed,
+	},
+	OrderStatusConfirmed: {
+		OrderStatusReadyForPickup, OrderStatusPickedUp, OrderStatusCancelledByConsumer,
+		OrderStatusCancelledByMerchant, OrderStatusNoShow,
+	},
+	OrderStatusReadyForPickup: {
+		OrderStatusPickedUp, OrderStatusCancelledByConsumer, OrderStatusCancelledByMerchant, OrderStatusNoShow,
+	},
+}
+
+// CanTransitionTo reports whether an order may move from s to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions are possible.
+func (s OrderStatus) IsFinal() bool {
+	return len(orderTransitions[s]) == 0
+}
+
 type Order struct {
-	ID         int       `json:"id"`
-	ProductID  int       `json:"product_id"`
-	ConsumerID string    `json:"consumer_id"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID            int         `json:"id"`
+	ProductID     int         `json:"product_id"`
+	ConsumerID    string      `json:"consumer_id"`
+	MerchantID    string      `json:"merchant_id"`
+	ProductName   string      `json:"product_name"` // Snapshot at purchase time
+	PricePaid     float64     `json:"price_paid"`   // Snapshot at purchase time
+	CheckoutID    *int        `json:"checkout_id,omitempty"`
+	PaymentStatus string      `json:"payment_status"`
+	Status        OrderStatus `json:"status"`
+	ConfirmedAt   *time.Time  `json:"confirmed_at,omitempty"`
+	ReadyAt       *time.Time  `json:"ready_at,omitempty"`
+	PickedUpAt    *time.Time  `json:"picked_up_at,omitempty"`
+	CancelledAt   *time.Time  `json:"cancelled_at,omitempty"`
+	NoShowAt      *time.Time  `json:"no_show_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// OrderHistoryItem is an order as shown in consumer and merchant order
+// history, with the pickup window in the merchant's timezone.
+type OrderHistoryItem struct {
+	Order
+	ShopName    string    `json:"shop_name"`
+	PickupStart time.Time `json:"pickup_start"`
+	PickupEnd   time.Time `json:"pickup_end"`
+	Timezone    string    `json:"timezone"`
+	Reviewed    bool      `json:"reviewed"`
+	ReviewID    *int      `json:"review_id,omitempty"`
+}
+
+// PickupPass is what the consumer shows at the counter. Payload is the
+// signed QR content; staff can verify it offline with the platform's
+// public key.
+type PickupPass struct {
+	OrderID     int       `json:"order_id"`
+	MerchantID  string    `json:"merchant_id"`
+	ProductName string    `json:"product_name"`
+	Code        string    `json:"code"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Payload     string    `json:"payload,omitempty"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Order payment statuses. Orders placed without a payment method are
+// not_required and are settled at pickup.
+const (
+	PaymentStatusNotRequired = "not_required"
+	PaymentStatusPending     = "pending"
+	PaymentStatusPaid        = "paid"
+	PaymentStatusFailed      = "failed"
+	PaymentStatusRefunded    = "refunded"
+)
+
+// Payment is one attempt to pay for an order through a provider.
+type Payment struct {
+	ID             int       `json:"id"`
+	OrderID        int       `json:"order_id"`
+	Provider       string    `json:"provider"`
+	IntentID       string    `json:"intent_id"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"`
+	RefundedAmount float64   `json:"refunded_amount"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
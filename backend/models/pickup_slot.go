@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PickupSlot is a window in which up to Capacity orders can be collected.
+type PickupSlot struct {
+	ID         int       `json:"id"`
+	MerchantID string    `json:"merchant_id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	Capacity   int       `json:"capacity"`
+	Booked     int       `json:"booked"`
+	Remaining  int       `json:"remaining"`
+}
+
+// PickupManifestEntry is one order expected during a slot.
+type PickupManifestEntry struct {
+	OrderID     int         `json:"order_id"`
+	ConsumerID  string      `json:"consumer_id"`
+	ProductName string      `json:"product_name"`
+	Status      OrderStatus `json:"status"`
+}
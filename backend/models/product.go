@@ -6,6 +6,7 @@ type ProductStatus string
 
 const (
 	ProductStatusAvailable ProductStatus = "AVAILABLE"
+	ProductStatusReserved  ProductStatus = "RESERVED"
 	ProductStatusSold      ProductStatus = "SOLD"
 )
 
@@ -20,4 +21,7 @@ type Product struct {
 	Longitude     float64       `json:"longitude"`
 	IsListed      bool          `json:"is_listed"`
 	Status        ProductStatus `json:"status"`
+	PickupStart   time.Time     `json:"pickup_start"`
+	PickupEnd     time.Time     `json:"pickup_end"`
+	Timezone      string        `json:"timezone"` // Merchant's timezone; times above use its offset
 }
@@ -0,0 +1,12 @@
+package models
+
+// PurchaseLimits caps how much one consumer may buy, counting live orders
+// and active reservations. Days are platform local days; a listing is every
+// unit a schedule published in one run, or a single manual product. Zero
+// means no limit.
+type PurchaseLimits struct {
+	MerchantID   string `json:"merchant_id,omitempty"`
+	DailyTotal   int    `json:"daily_total"`    // Across all shops
+	PerShopDaily int    `json:"per_shop_daily"` // From one shop
+	PerListing   int    `json:"per_listing"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// E-invoice statuses of a receipt. Merchants without a tax ID are
+// not_required.
+const (
+	InvoiceStatusNotRequired = "not_required"
+	InvoiceStatusPending     = "pending"
+	InvoiceStatusIssued      = "issued"
+	InvoiceStatusFailed      = "failed"
+)
+
+// ReceiptItem is one line of a receipt.
+type ReceiptItem struct {
+	Name      string  `json:"name"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"` // Before the surplus discount
+	Discount  float64 `json:"discount"`
+	Amount    float64 `json:"amount"`
+}
+
+// EInvoice is the Taiwan e-invoice (電子發票) issued for a receipt.
+type EInvoice struct {
+	Status     string     `json:"status"`
+	Number     string     `json:"number,omitempty"`      // e.g. AB12345678
+	RandomCode string     `json:"random_code,omitempty"` // 4 digits
+	Carrier    string     `json:"carrier,omitempty"`     // Mobile barcode, e.g. /ABC+123
+	IssuedAt   *time.Time `json:"issued_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Receipt is issued when an order is picked up. Prices include tax.
+type Receipt struct {
+	ID              int           `json:"id"`
+	ReceiptNumber   string        `json:"receipt_number"`
+	OrderID         int           `json:"order_id"`
+	ConsumerID      string        `json:"consumer_id"`
+	MerchantID      string        `json:"merchant_id"`
+	ShopName        string        `json:"shop_name"`
+	MerchantAddress string        `json:"merchant_address"`
+	MerchantTaxID   string        `json:"merchant_tax_id,omitempty"`
+	Items           []ReceiptItem `json:"items"`
+	Subtotal        float64       `json:"subtotal"`
+	Discount        float64       `json:"discount"`
+	Total           float64       `json:"total"`
+	TaxRate         float64       `json:"tax_rate"`
+	TaxAmount       float64       `json:"tax_amount"`
+	Currency        string        `json:"currency"`
+	PaymentMethod   string        `json:"payment_method,omitempty"`
+	Invoice         EInvoice      `json:"invoice"`
+	IssuedAt        time.Time     `json:"issued_at"`
+}
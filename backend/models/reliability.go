@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Reliability tiers, from no restrictions to suspended. Each tier is
+// reached by a number of no-shows in the scoring window.
+const (
+	ReliabilityTierGood      = "good"
+	ReliabilityTierWarned    = "warned"
+	ReliabilityTierPrepay    = "prepay"
+	ReliabilityTierSuspended = "suspended"
+)
+
+// Statuses of a consumer's dispute of a no-show. An overturned no-show no
+// longer counts against the consumer.
+const (
+	NoShowDisputeOpen       = "open"
+	NoShowDisputeUpheld     = "upheld"
+	NoShowDisputeOverturned = "overturned"
+)
+
+// ConsumerReliability summarises how reliably a consumer picks up orders
+// and the restrictions that follow from it.
+type ConsumerReliability struct {
+	ConsumerID         string     `json:"consumer_id"`
+	Score              int        `json:"score"` // 0-100
+	PickedUp           int        `json:"picked_up"`
+	NoShows            int        `json:"no_shows"`
+	WindowDays         int        `json:"window_days"`
+	Tier               string     `json:"tier"`
+	MaxOpenOrders      int        `json:"max_open_orders,omitempty"` // 0 = unlimited
+	PrepaymentRequired bool       `json:"prepayment_required"`
+	SuspendedUntil     *time.Time `json:"suspended_until,omitempty"`
+}
+
+// NoShowDispute is a consumer's claim that a no-show was recorded wrongly.
+type NoShowDispute struct {
+	ID               int        `json:"id"`
+	OrderID          int        `json:"order_id"`
+	ConsumerID       string     `json:"consumer_id"`
+	MerchantID       string     `json:"merchant_id"`
+	ProductName      string     `json:"product_name"`
+	Reason           string     `json:"reason"`
+	Status           string     `json:"status"`
+	MerchantResponse string     `json:"merchant_response,omitempty"`
+	ResolutionNote   string     `json:"resolution_note,omitempty"`
+	NoShowAt         *time.Time `json:"no_show_at,omitempty"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
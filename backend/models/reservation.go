@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type ReservationStatus string
+
+const (
+	ReservationStatusActive    ReservationStatus = "active"
+	ReservationStatusConfirmed ReservationStatus = "confirmed"
+	ReservationStatusReleased  ReservationStatus = "released"
+	ReservationStatusExpired   ReservationStatus = "expired"
+)
+
+// Reservation holds a product for a consumer until ExpiresAt.
+type Reservation struct {
+	ID         int               `json:"id"`
+	ProductID  int               `json:"product_id"`
+	ConsumerID string            `json:"consumer_id"`
+	Status     ReservationStatus `json:"status"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	OrderID    *int              `json:"order_id,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ListingSchedule is a listing a merchant publishes automatically on the
+// given weekdays (0 = Sunday) at PublishTime ("15:04") in the merchant's
+// timezone.
+type ListingSchedule struct {
+	ID            int       `json:"id"`
+	MerchantID    string    `json:"merchant_id"`
+	Name          string    `json:"name"`
+	OriginalPrice float64   `json:"original_price"`
+	CurrentPrice  float64   `json:"current_price"`
+	Quantity      int       `json:"quantity"`
+	Weekdays      []int64   `json:"weekdays"`
+	PublishTime   string    `json:"publish_time"`
+	ExpiryMinutes int       `json:"expiry_minutes"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	IsPaused      bool      `json:"is_paused"`
+	SkipDates     []string  `json:"skip_dates"`
+	CreatedAt     time.Time `json:"created_at"`
+}
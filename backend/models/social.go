@@ -3,13 +3,38 @@ package models
 import "time"
 
 type Review struct {
-	ID         int       `json:"id"`
-	OrderID    int       `json:"order_id"`
-	UserID     string    `json:"user_id"`
-	MerchantID string    `json:"merchant_id"`
-	Rating     int       `json:"rating"`
-	Comment    string    `json:"comment"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID         int           `json:"id"`
+	OrderID    int           `json:"order_id"`
+	UserID     string        `json:"user_id"`
+	MerchantID string        `json:"merchant_id"`
+	Rating     int           `json:"rating"`
+	Comment    string        `json:"comment"`
+	Reply      *ReviewReply  `json:"reply,omitempty"`
+	Photos     []ReviewPhoto `json:"photos,omitempty"`
+	Helpful    int           `json:"helpful_count"`
+	NotHelpful int           `json:"not_helpful_count"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// ReviewPhoto is an image attached to a review. Status follows the review
+// statuses; only published photos of published reviews are public.
+type ReviewPhoto struct {
+	ID           int          `json:"id"`
+	ReviewID     int          `json:"review_id"`
+	URL          string       `json:"url"`
+	ThumbnailURL string       `json:"thumbnail_url"`
+	Width        int          `json:"width"`
+	Height       int          `json:"height"`
+	Status       string       `json:"status,omitempty"`
+	Flags        []ReviewFlag `json:"flags,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// ReviewReply is the reviewed merchant's public answer to a review.
+type ReviewReply struct {
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // Set once edited
 }
 
 type Favorite struct {
@@ -32,6 +57,7 @@ type Notification struct {
 type PickupSchedule struct {
 	ID            int       `json:"id"`
 	OrderID       int       `json:"order_id"`
+	SlotID        *int      `json:"slot_id"`
 	ScheduledTime time.Time `json:"scheduled_time"`
 	Status        string    `json:"status"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -62,3 +88,14 @@ type PointHistory struct {
 	Reason       string    `json:"reason"`
 	CreatedAt    time.Time `json:"created_at"`
 }
+
+// RatingSummary is a merchant's maintained rating aggregates. Score is the
+// Bayesian-weighted average used to rank search results.
+type RatingSummary struct {
+	Count         int         `json:"count"`
+	Average       float64     `json:"average"`
+	Histogram     map[int]int `json:"histogram"` // Stars (1-5) to review count
+	RecentCount   int         `json:"recent_count"`
+	RecentAverage float64     `json:"recent_average"` // Last 90 days
+	Score         float64     `json:"score"`
+}
@@ -0,0 +1,48 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Contact details in reviews are usually spam or someone's personal data.
+
+var (
+	phonePattern = regexp.MustCompile(`\+?\d[\d \-().]{5,20}\d`)
+	datePattern  = regexp.MustCompile(`^\d{4}[-/.]\d{1,2}[-/.]\d{1,2}$`)
+	urlPattern   = regexp.MustCompile(`(?:https?://|www\.)\S+|\b[a-z0-9][a-z0-9-]*(?:\.[a-z0-9-]+)*\.(?:com|net|org|info|biz|io|co|me|app|shop|tw|vn|cn|hk|jp|ly|gl|cc)\b(?:/\S*)?`)
+)
+
+// PhoneFilter flags phone numbers: runs of 8 to 15 digits, optionally with
+// a leading + and spaces, dashes, dots or brackets between them. Dates are
+// not phone numbers.
+type PhoneFilter struct{}
+
+func (PhoneFilter) Check(text string) []Finding {
+	var findings []Finding
+	for _, m := range phonePattern.FindAllString(normalize(text), -1) {
+		m = strings.TrimSpace(m)
+		digits := 0
+		for _, r := range m {
+			if r >= '0' && r <= '9' {
+				digits++
+			}
+		}
+		if digits >= 8 && digits <= 15 && !datePattern.MatchString(m) {
+			findings = append(findings, Finding{Rule: RulePhone, Match: m})
+		}
+	}
+	return findings
+}
+
+// URLFilter flags links, with or without a scheme, and so also email
+// addresses.
+type URLFilter struct{}
+
+func (URLFilter) Check(text string) []Finding {
+	var findings []Finding
+	for _, m := range urlPattern.FindAllString(normalize(text), -1) {
+		findings = append(findings, Finding{Rule: RuleURL, Match: strings.TrimRight(m, ".,;:!?)]}'\"")})
+	}
+	return findings
+}
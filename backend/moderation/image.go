@@ -0,0 +1,14 @@
+package moderation
+
+// ImageFilter inspects an uploaded image and returns what it found, or
+// nothing if the image may be published. Classifiers (nudity, gore, text in
+// images) plug in here; by default every image is accepted and left to user
+// reports and admins.
+type ImageFilter interface {
+	CheckImage(img []byte) []Finding
+}
+
+// AllowImages is the ImageFilter that accepts everything.
+type AllowImages struct{}
+
+func (AllowImages) CheckImage([]byte) []Finding { return nil }
@@ -0,0 +1,84 @@
+// Package moderation screens user-written text before it is published.
+// Filters are pluggable: each reports what it found and the caller decides
+// what to do with the text.
+package moderation
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Rules a Finding can come from.
+const (
+	RuleProfanity = "profanity"
+	RulePhone     = "phone"
+	RuleURL       = "url"
+)
+
+// Finding is one problem a filter found in a text.
+type Finding struct {
+	Rule  string `json:"rule"`
+	Lang  string `json:"lang,omitempty"` // Word list language, for profanity
+	Match string `json:"match"`
+}
+
+// Filter inspects a text and returns what it found, or nothing if the text
+// is clean.
+type Filter interface {
+	Check(text string) []Finding
+}
+
+// Chain runs several filters and returns all of their findings.
+type Chain []Filter
+
+func (c Chain) Check(text string) []Finding {
+	var findings []Finding
+	for _, f := range c {
+		findings = append(findings, f.Check(text)...)
+	}
+	return findings
+}
+
+// Default screens for profanity in every supported language plus phone
+// numbers and URLs.
+func Default() Filter {
+	chain := Chain{}
+	for _, lang := range Languages {
+		chain = append(chain, NewWordList(lang, builtinWords[lang]))
+	}
+	return append(chain, PhoneFilter{}, URLFilter{})
+}
+
+// FromEnv returns Default plus any extra word lists found in
+// MODERATION_WORDLIST_DIR, one <lang>.txt file per language.
+func FromEnv() (Filter, error) {
+	f := Default()
+	dir := os.Getenv("MODERATION_WORDLIST_DIR")
+	if dir == "" {
+		return f, nil
+	}
+	lists, err := LoadWordLists(dir)
+	if err != nil {
+		return nil, err
+	}
+	chain := f.(Chain)
+	for _, l := range lists {
+		chain = append(chain, l)
+	}
+	return chain, nil
+}
+
+// normalize lowercases text and folds full-width ASCII, which CJK input
+// methods produce, to its half-width form.
+func normalize(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			r -= 0xFEE0
+		case r == 0x3000:
+			r = ' '
+		}
+		return unicode.ToLower(r)
+	}, text)
+}
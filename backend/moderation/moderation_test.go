@@ -0,0 +1,82 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rules(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.Rule+":"+f.Match)
+	}
+	return out
+}
+
+func TestWordListWholeWords(t *testing.T) {
+	l := NewWordList("en", []string{"shit", "Dick Head"})
+	assert.Equal(t, []string{"profanity:shit"}, rules(l.Check("This was SHIT!")))
+	assert.Empty(t, l.Check("Shiitake mushrooms, a shitake typo"))
+	assert.Equal(t, []string{"profanity:dick head"}, rules(l.Check("the owner is a dick  head")))
+	assert.Empty(t, l.Check("Dick was at the head of the queue"))
+}
+
+func TestWordListDenseScripts(t *testing.T) {
+	l := NewWordList("zh-TW", []string{"王八蛋"})
+	assert.Equal(t, []string{"profanity:王八蛋"}, rules(l.Check("老闆是王八蛋")))
+	assert.Len(t, l.Check("老闆是 王 八-蛋"), 1, "spacing between characters does not evade the list")
+	assert.Empty(t, l.Check("麵包很好吃"))
+}
+
+func TestWordListVietnamese(t *testing.T) {
+	l := NewWordList("vi", builtinWords["vi"])
+	assert.Equal(t, []string{"profanity:đồ chó"}, rules(l.Check("Chủ quán là Đồ chó")))
+	assert.Empty(t, l.Check("Bánh mì rất ngon, giá rẻ"))
+}
+
+func TestPhoneFilter(t *testing.T) {
+	for _, text := range []string{
+		"call 0912-345-678",
+		"LINE me +886 912 345 678",
+		"電話０２２３４５６７８９",
+		"(02) 2345-6789",
+	} {
+		assert.Len(t, PhoneFilter{}.Check(text), 1, text)
+	}
+	for _, text := range []string{
+		"picked up 2026-03-02 at 18:30",
+		"paid 120 for 3 items",
+		"order #1234567",
+	} {
+		assert.Empty(t, PhoneFilter{}.Check(text), text)
+	}
+}
+
+func TestURLFilter(t *testing.T) {
+	assert.Equal(t, []string{"url:https://spam.example/deal"}, rules(URLFilter{}.Check("See https://spam.example/deal now")))
+	assert.Equal(t, []string{"url:www.cheapfood.tw"}, rules(URLFilter{}.Check("Better at www.cheapfood.tw")))
+	assert.Equal(t, []string{"url:line.me/ti/p/abc"}, rules(URLFilter{}.Check("add line.me/ti/p/abc")))
+	assert.Equal(t, []string{"url:www.cheapfood.tw"}, rules(URLFilter{}.Check("(www.cheapfood.tw).")))
+	assert.Len(t, URLFilter{}.Check("mail me at joe@mail.com"), 1)
+	assert.Empty(t, URLFilter{}.Check("Great value. Will come again."))
+}
+
+func TestDefaultChain(t *testing.T) {
+	findings := Default().Check("幹你娘 call 0912345678")
+	assert.Equal(t, []string{"profanity:幹你娘", "phone:0912345678"}, rules(findings))
+	assert.Empty(t, Default().Check("The bread was fresh and the staff were friendly. 麵包很新鮮！"))
+}
+
+func TestFromEnvLoadsWordLists(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "en.txt"), []byte("# extra words\nrotten\n\n"), 0o644))
+	t.Setenv("MODERATION_WORDLIST_DIR", dir)
+
+	f, err := FromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"profanity:rotten"}, rules(f.Check("Rotten service")))
+}
@@ -0,0 +1,121 @@
+package moderation
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Languages are the app's supported languages, each with a built-in word
+// list.
+var Languages = []string{"en", "zh-TW", "zh-CN", "vi"}
+
+// builtinWords are short starter lists; deployments extend them with
+// MODERATION_WORDLIST_DIR.
+var builtinWords = map[string][]string{
+	"en":    {"fuck", "fucking", "shit", "bitch", "asshole", "bastard", "cunt", "dickhead", "motherfucker"},
+	"zh-TW": {"幹你娘", "他媽的", "王八蛋", "靠北", "機掰", "雞掰", "白癡", "賤人", "去死"},
+	"zh-CN": {"他妈的", "王八蛋", "傻逼", "操你妈", "妈的", "贱人", "去死"},
+	"vi":    {"địt", "đụ", "đéo", "lồn", "cặc", "đồ chó", "chó chết", "vãi lồn"},
+}
+
+// WordList flags words from one language's list. Words written in a script
+// without spaces (Chinese) match anywhere, even with spacing or punctuation
+// inserted between characters; others match whole words only.
+type WordList struct {
+	lang   string
+	spaced []string // " word " for whole-word matching
+	dense  []string
+}
+
+// NewWordList builds a list for lang. Words are matched case-insensitively.
+func NewWordList(lang string, words []string) *WordList {
+	l := &WordList{lang: lang}
+	for _, w := range words {
+		w = strings.Join(tokens(normalize(w)), " ")
+		switch {
+		case w == "":
+		case isDense(w):
+			l.dense = append(l.dense, strings.ReplaceAll(w, " ", ""))
+		default:
+			l.spaced = append(l.spaced, " "+w+" ")
+		}
+	}
+	return l
+}
+
+// Lang is the list's language code.
+func (l *WordList) Lang() string { return l.lang }
+
+func (l *WordList) Check(text string) []Finding {
+	toks := tokens(normalize(text))
+	spaced := " " + strings.Join(toks, " ") + " "
+	dense := strings.Join(toks, "")
+
+	var findings []Finding
+	for _, w := range l.spaced {
+		if strings.Contains(spaced, w) {
+			findings = append(findings, Finding{Rule: RuleProfanity, Lang: l.lang, Match: strings.TrimSpace(w)})
+		}
+	}
+	for _, w := range l.dense {
+		if strings.Contains(dense, w) {
+			findings = append(findings, Finding{Rule: RuleProfanity, Lang: l.lang, Match: w})
+		}
+	}
+	return findings
+}
+
+// tokens splits text into runs of letters and digits.
+func tokens(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+}
+
+// isDense reports whether w is written without spaces between words.
+func isDense(w string) bool {
+	for _, r := range w {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadWordLists reads <lang>.txt files from dir: one word or phrase per
+// line, with blank lines and lines starting with # ignored.
+func LoadWordLists(dir string) ([]*WordList, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	var lists []*WordList
+	for _, path := range paths {
+		words, err := readWords(path)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, NewWordList(strings.TrimSuffix(filepath.Base(path), ".txt"), words))
+	}
+	return lists, nil
+}
+
+func readWords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,264 @@
+package payments
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ecpayZone is the timezone ECPay expects in MerchantTradeDate.
+var ecpayZone = time.FixedZone("Asia/Taipei", 8*60*60)
+
+// ECPay charges through ECPay's all-in-one (AIO) checkout. CreateIntent
+// makes no network call: it returns the signed form the customer's browser
+// posts to ECPay. Credit payments are captured automatically, and the
+// result arrives as a server-to-server notification on NotifyURL.
+type ECPay struct {
+	baseURL    string
+	merchantID string
+	hashKey    string
+	hashIV     string
+	client     *http.Client
+	now        func() time.Time
+}
+
+// NewECPay returns an ECPay adapter. baseURL is normally
+// https://payment.ecpay.com.tw (or payment-stage for testing).
+func NewECPay(baseURL, merchantID, hashKey, hashIV string) *ECPay {
+	return &ECPay{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		merchantID: merchantID,
+		hashKey:    hashKey,
+		hashIV:     hashIV,
+		client:     &http.Client{Timeout: 20 * time.Second},
+		now:        time.Now,
+	}
+}
+
+func (e *ECPay) Name() string { return "ecpay" }
+
+// ECPayCheckMacValue signs params the way ECPay does: sort by key, wrap in
+// HashKey/HashIV, URL-encode .NET style, lower-case, SHA256, upper-case hex.
+func ECPayCheckMacValue(params map[string]string, hashKey, hashIV string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "CheckMacValue" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return strings.ToLower(keys[i]) < strings.ToLower(keys[j]) })
+
+	var b strings.Builder
+	b.WriteString("HashKey=" + hashKey)
+	for _, k := range keys {
+		b.WriteString("&" + k + "=" + params[k])
+	}
+	b.WriteString("&HashIV=" + hashIV)
+
+	encoded := strings.ToLower(url.QueryEscape(b.String()))
+	// .NET's UrlEncode leaves these unescaped and escapes '~'
+	encoded = strings.NewReplacer("%21", "!", "%2a", "*", "%28", "(", "%29", ")", "~", "%7e").Replace(encoded)
+
+	sum := sha256.Sum256([]byte(encoded))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// ecpayTradeNo builds a MerchantTradeNo (max 20 alphanumerics) for an order.
+func ecpayTradeNo(orderRef string, at time.Time) string {
+	var b strings.Builder
+	for _, r := range orderRef {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	ref := b.String()
+	if len(ref) > 10 {
+		ref = ref[len(ref)-10:]
+	}
+	return ref + strconv.FormatInt(at.Unix(), 36)
+}
+
+func (e *ECPay) CreateIntent(ctx context.Context, req CreateIntentRequest) (Intent, error) {
+	now := e.now()
+	tradeNo := ecpayTradeNo(req.OrderRef, now)
+	amount := int64(math.Round(req.Amount))
+	fields := map[string]string{
+		"MerchantID":        e.merchantID,
+		"MerchantTradeNo":   tradeNo,
+		"MerchantTradeDate": now.In(ecpayZone).Format("2006/01/02 15:04:05"),
+		"PaymentType":       "aio",
+		"TotalAmount":       strconv.FormatInt(amount, 10),
+		"TradeDesc":         "Food Platform Order",
+		"ItemName":          req.Description,
+		"ReturnURL":         req.NotifyURL,
+		"ClientBackURL":     req.ReturnURL,
+		"ChoosePayment":     "Credit",
+		"EncryptType":       "1",
+		"CustomField1":      req.OrderRef,
+	}
+	fields["CheckMacValue"] = ECPayCheckMacValue(fields, e.hashKey, e.hashIV)
+
+	return Intent{
+		ID:          tradeNo,
+		Provider:    e.Name(),
+		OrderRef:    req.OrderRef,
+		Amount:      float64(amount),
+		Currency:    "TWD",
+		Status:      StatusPending,
+		RedirectURL: e.baseURL + "/Cashier/AioCheckOut/V5",
+		FormFields:  fields,
+	}, nil
+}
+
+func (e *ECPay) post(ctx context.Context, path string, params map[string]string) (url.Values, error) {
+	params["CheckMacValue"] = ECPayCheckMacValue(params, e.hashKey, e.hashIV)
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("ecpay %s: %d", path, resp.StatusCode)
+	}
+	return url.ParseQuery(string(body))
+}
+
+// query fetches the trade and returns it with ECPay's TradeNo.
+func (e *ECPay) query(ctx context.Context, tradeNo string) (Intent, string, error) {
+	values, err := e.post(ctx, "/Cashier/QueryTradeInfo/V5", map[string]string{
+		"MerchantID":      e.merchantID,
+		"MerchantTradeNo": tradeNo,
+		"TimeStamp":       strconv.FormatInt(e.now().Unix(), 10),
+	})
+	if err != nil {
+		return Intent{}, "", err
+	}
+	if err := e.verifyValues(values); err != nil {
+		return Intent{}, "", err
+	}
+
+	amount, _ := strconv.ParseFloat(values.Get("TradeAmt"), 64)
+	intent := Intent{
+		ID:       tradeNo,
+		Provider: e.Name(),
+		OrderRef: values.Get("CustomField1"),
+		Amount:   amount,
+		Currency: "TWD",
+	}
+	switch values.Get("TradeStatus") {
+	case "0":
+		intent.Status = StatusPending
+	case "1":
+		intent.Status = StatusCaptured
+	default:
+		intent.Status = StatusFailed
+	}
+	return intent, values.Get("TradeNo"), nil
+}
+
+func (e *ECPay) GetIntent(ctx context.Context, intentID string) (Intent, error) {
+	intent, _, err := e.query(ctx, intentID)
+	return intent, err
+}
+
+// Capture only confirms the payment went through; ECPay credit payments are
+// captured by ECPay itself.
+func (e *ECPay) Capture(ctx context.Context, intentID string, amount float64) (Intent, error) {
+	intent, err := e.GetIntent(ctx, intentID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if intent.Status != StatusCaptured {
+		return intent, fmt.Errorf("ecpay trade %s is %s", intentID, intent.Status)
+	}
+	return intent, nil
+}
+
+func (e *ECPay) Refund(ctx context.Context, intentID string, amount float64) (Refund, error) {
+	_, ecpayTradeNo, err := e.query(ctx, intentID)
+	if err != nil {
+		return Refund{}, err
+	}
+	values, err := e.post(ctx, "/CreditDetail/DoAction", map[string]string{
+		"MerchantID":      e.merchantID,
+		"MerchantTradeNo": intentID,
+		"TradeNo":         ecpayTradeNo,
+		"Action":          "R",
+		"TotalAmount":     strconv.FormatInt(int64(math.Round(amount)), 10),
+	})
+	if err != nil {
+		return Refund{}, err
+	}
+	if values.Get("RtnCode") != "1" {
+		return Refund{}, fmt.Errorf("ecpay refund: %s", values.Get("RtnMsg"))
+	}
+	return Refund{ID: ecpayTradeNo, IntentID: intentID, Amount: math.Round(amount), Status: "succeeded"}, nil
+}
+
+func (e *ECPay) verifyValues(values url.Values) error {
+	if e.hashKey == "" || e.hashIV == "" {
+		return ErrInvalidSignature
+	}
+	params := map[string]string{}
+	for k := range values {
+		params[k] = values.Get(k)
+	}
+	if params["CheckMacValue"] == "" || ECPayCheckMacValue(params, e.hashKey, e.hashIV) != strings.ToUpper(params["CheckMacValue"]) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyWebhook checks the CheckMacValue of ECPay's payment result
+// notification.
+func (e *ECPay) VerifyWebhook(header http.Header, body []byte) (Event, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return Event{}, err
+	}
+	if err := e.verifyValues(values); err != nil {
+		return Event{}, err
+	}
+	if values.Get("MerchantID") != e.merchantID {
+		return Event{}, ErrInvalidSignature
+	}
+
+	amount, _ := strconv.ParseFloat(values.Get("TradeAmt"), 64)
+	evt := Event{
+		Type:     EventFailed,
+		IntentID: values.Get("MerchantTradeNo"),
+		OrderRef: values.Get("CustomField1"),
+		Amount:   amount,
+	}
+	if values.Get("RtnCode") == "1" {
+		evt.Type = EventCaptured
+	}
+	return evt, nil
+}
+
+// WebhookAck is the reply ECPay requires, or it retries the notification.
+func (e *ECPay) WebhookAck() (string, []byte) {
+	return "text/plain", []byte("1|OK")
+}
@@ -0,0 +1,154 @@
+package payments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ECPay's published sandbox credentials.
+const (
+	testECPayMerchantID = "3002607"
+	testECPayHashKey    = "pwFHCqoQZGmho4w6"
+	testECPayHashIV     = "EkRm7iFT261dpevs"
+)
+
+func signedECPayForm(params map[string]string) url.Values {
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	form.Set("CheckMacValue", ECPayCheckMacValue(params, testECPayHashKey, testECPayHashIV))
+	return form
+}
+
+func TestECPayCheckMacValue(t *testing.T) {
+	params := map[string]string{
+		"MerchantID":      testECPayMerchantID,
+		"MerchantTradeNo": "order7abc",
+		"TotalAmount":     "120",
+		"ItemName":        "Bento (large) ~ 2 pcs!",
+	}
+	mac := ECPayCheckMacValue(params, testECPayHashKey, testECPayHashIV)
+	assert.Len(t, mac, 64)
+	assert.Equal(t, mac, ECPayCheckMacValue(params, testECPayHashKey, testECPayHashIV))
+
+	// An existing CheckMacValue is not part of what is signed
+	params["CheckMacValue"] = mac
+	assert.Equal(t, mac, ECPayCheckMacValue(params, testECPayHashKey, testECPayHashIV))
+
+	params["TotalAmount"] = "1"
+	assert.NotEqual(t, mac, ECPayCheckMacValue(params, testECPayHashKey, testECPayHashIV))
+}
+
+func TestECPayCreateIntent(t *testing.T) {
+	e := NewECPay("https://payment-stage.ecpay.com.tw/", testECPayMerchantID, testECPayHashKey, testECPayHashIV)
+	e.now = func() time.Time { return time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC) }
+
+	intent, err := e.CreateIntent(context.Background(), CreateIntentRequest{
+		OrderRef: "order-7", Amount: 120.4, Description: "Bento",
+		ReturnURL: "https://app.example/paid", NotifyURL: "https://api.example/payments/webhook/ecpay",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://payment-stage.ecpay.com.tw/Cashier/AioCheckOut/V5", intent.RedirectURL)
+	assert.Equal(t, intent.ID, intent.FormFields["MerchantTradeNo"])
+	assert.LessOrEqual(t, len(intent.ID), 20)
+	assert.Equal(t, "120", intent.FormFields["TotalAmount"])
+	assert.Equal(t, "2026/03/02 18:00:00", intent.FormFields["MerchantTradeDate"])
+	assert.Equal(t, "https://api.example/payments/webhook/ecpay", intent.FormFields["ReturnURL"])
+	assert.Equal(t, ECPayCheckMacValue(intent.FormFields, testECPayHashKey, testECPayHashIV), intent.FormFields["CheckMacValue"])
+}
+
+func TestECPayVerifyWebhook(t *testing.T) {
+	e := NewECPay("http://unused", testECPayMerchantID, testECPayHashKey, testECPayHashIV)
+	params := map[string]string{
+		"MerchantID":      testECPayMerchantID,
+		"MerchantTradeNo": "order7abc",
+		"RtnCode":         "1",
+		"RtnMsg":          "交易成功",
+		"TradeAmt":        "120",
+		"CustomField1":    "order-7",
+	}
+
+	evt, err := e.VerifyWebhook(nil, []byte(signedECPayForm(params).Encode()))
+	require.NoError(t, err)
+	assert.Equal(t, Event{Type: EventCaptured, IntentID: "order7abc", OrderRef: "order-7", Amount: 120}, evt)
+
+	form := signedECPayForm(params)
+	form.Set("TradeAmt", "1")
+	_, err = e.VerifyWebhook(nil, []byte(form.Encode()))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	params["RtnCode"] = "10100058"
+	evt, err = e.VerifyWebhook(nil, []byte(signedECPayForm(params).Encode()))
+	require.NoError(t, err)
+	assert.Equal(t, EventFailed, evt.Type)
+
+	params["MerchantID"] = "2000132"
+	_, err = e.VerifyWebhook(nil, []byte(signedECPayForm(params).Encode()))
+	assert.ErrorIs(t, err, ErrInvalidSignature, "notifications for another merchant")
+
+	unsigned := NewECPay("http://unused", testECPayMerchantID, "", "")
+	params["MerchantID"] = testECPayMerchantID
+	form = url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	form.Set("CheckMacValue", ECPayCheckMacValue(params, "", ""))
+	_, err = unsigned.VerifyWebhook(nil, []byte(form.Encode()))
+	assert.ErrorIs(t, err, ErrInvalidSignature, "no hash key configured")
+
+	contentType, body := e.WebhookAck()
+	assert.Equal(t, "text/plain", contentType)
+	assert.Equal(t, "1|OK", string(body))
+}
+
+func TestECPayQueryAndRefund(t *testing.T) {
+	var refundForm url.Values
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		params := map[string]string{}
+		for k := range r.PostForm {
+			params[k] = r.PostForm.Get(k)
+		}
+		if ECPayCheckMacValue(params, testECPayHashKey, testECPayHashIV) != params["CheckMacValue"] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/Cashier/QueryTradeInfo/V5":
+			w.Write([]byte(signedECPayForm(map[string]string{
+				"MerchantID":      testECPayMerchantID,
+				"MerchantTradeNo": params["MerchantTradeNo"],
+				"TradeNo":         "2603021800001",
+				"TradeAmt":        "120",
+				"TradeStatus":     "1",
+				"CustomField1":    "order-7",
+			}).Encode()))
+		case "/CreditDetail/DoAction":
+			refundForm = r.PostForm
+			w.Write([]byte("MerchantID=" + testECPayMerchantID + "&RtnCode=1&RtnMsg=OK"))
+		}
+	}))
+	defer stub.Close()
+	e := NewECPay(stub.URL, testECPayMerchantID, testECPayHashKey, testECPayHashIV)
+	ctx := context.Background()
+
+	intent, err := e.GetIntent(ctx, "order7abc")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, intent.Status)
+	assert.Equal(t, "order-7", intent.OrderRef)
+
+	refund, err := e.Refund(ctx, "order7abc", 60)
+	require.NoError(t, err)
+	assert.Equal(t, 60.0, refund.Amount)
+	assert.Equal(t, "2603021800001", refundForm.Get("TradeNo"))
+	assert.Equal(t, "R", refundForm.Get("Action"))
+	assert.Equal(t, "60", refundForm.Get("TotalAmount"))
+}
@@ -0,0 +1,331 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRefundUnsupported is returned by providers that cannot refund on their
+// own; such refunds are settled by hand.
+var ErrRefundUnsupported = errors.New("provider cannot refund automatically")
+
+// transferTopic is keccak256("Transfer(address,address,uint256)"), the
+// first topic of every ERC-20 Transfer log.
+const transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// quoteTailUnits bounds the random tail added to each quote so concurrent
+// payments of the same price can be told apart on chain.
+const quoteTailUnits = 9999
+
+var evmAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// IsEVMAddress reports whether s is a 0x-prefixed 20-byte hex address.
+func IsEVMAddress(s string) bool {
+	return evmAddressPattern.MatchString(s)
+}
+
+// EVMStablecoinConfig configures an ERC-20 stablecoin payment method.
+type EVMStablecoinConfig struct {
+	Name           string  // payment method name, e.g. "usdc"
+	Symbol         string  // e.g. "USDC"
+	RPCURL         string  // JSON-RPC endpoint; a local dev chain in tests
+	ChainID        int64   // for the wallet payment link
+	TokenAddress   string  // ERC-20 contract
+	Decimals       int     // token decimals, 6 for USDC
+	DepositAddress string  // where customers send the tokens
+	Confirmations  int     // blocks on top of the transfer before it counts
+	FiatRate       float64 // order currency per token, e.g. 32.5 TWD per USDC
+}
+
+// EVMStablecoin takes payment as an ERC-20 transfer to a deposit address.
+// Each quote is the order price converted to the token plus a random tail
+// in the smallest units, so the exact amount identifies the payment. The
+// payment is confirmed by polling eth_getLogs for a matching Transfer from
+// the customer's wallet that has enough confirmations and has not settled
+// another payment; there are no webhooks, so the payment reconciler does
+// the watching.
+type EVMStablecoin struct {
+	cfg     EVMStablecoinConfig
+	client  *http.Client
+	nextID  atomic.Int64
+	claimed ClaimCheck
+}
+
+// NewEVMStablecoin returns a stablecoin payment method.
+func NewEVMStablecoin(cfg EVMStablecoinConfig) *EVMStablecoin {
+	if cfg.Name == "" {
+		cfg.Name = strings.ToLower(cfg.Symbol)
+	}
+	if cfg.Decimals <= 0 {
+		cfg.Decimals = 6
+	}
+	if cfg.Confirmations <= 0 {
+		cfg.Confirmations = 1
+	}
+	if cfg.FiatRate <= 0 {
+		cfg.FiatRate = 1
+	}
+	cfg.TokenAddress = strings.ToLower(cfg.TokenAddress)
+	cfg.DepositAddress = strings.ToLower(cfg.DepositAddress)
+	return &EVMStablecoin{cfg: cfg, client: &http.Client{Timeout: 20 * time.Second}}
+}
+
+func (e *EVMStablecoin) Name() string { return e.cfg.Name }
+
+// SetClaimCheck sets how transfers that settled other payments are found.
+// Without one, every matching transfer counts.
+func (e *EVMStablecoin) SetClaimCheck(check ClaimCheck) { e.claimed = check }
+
+// rpc calls a JSON-RPC method and decodes its result into out.
+func (e *EVMStablecoin) rpc(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      e.nextID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.RPCURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("%s: %d %w", method, resp.StatusCode, err)
+	}
+	if r.Error != nil {
+		return fmt.Errorf("%s: %d %s", method, r.Error.Code, r.Error.Message)
+	}
+	return json.Unmarshal(r.Result, out)
+}
+
+func (e *EVMStablecoin) blockNumber(ctx context.Context) (uint64, error) {
+	var hex string
+	if err := e.rpc(ctx, "eth_blockNumber", []interface{}{}, &hex); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(hex, "0x"), 16, 64)
+}
+
+// topicAddress left-pads an address to a 32-byte log topic.
+func topicAddress(addr string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(strings.ToLower(addr), "0x")
+}
+
+// evmQuote is what an intent ID encodes: who pays, how much in the token's
+// smallest unit, and the block to start looking from.
+type evmQuote struct {
+	payer     string
+	units     *big.Int
+	fromBlock uint64
+}
+
+func (q evmQuote) id() string {
+	return fmt.Sprintf("%s:%s:%d", q.payer, q.units, q.fromBlock)
+}
+
+// parseEVMQuote decodes an intent ID. Quotes without a payer, which any
+// sender's transfer could settle, are rejected.
+func parseEVMQuote(id string) (evmQuote, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 3 || !IsEVMAddress(parts[0]) {
+		return evmQuote{}, fmt.Errorf("malformed intent id %q", id)
+	}
+	units, ok := new(big.Int).SetString(parts[1], 10)
+	from, err := strconv.ParseUint(parts[2], 10, 64)
+	if !ok || err != nil {
+		return evmQuote{}, fmt.Errorf("malformed intent id %q", id)
+	}
+	return evmQuote{payer: strings.ToLower(parts[0]), units: units, fromBlock: from}, nil
+}
+
+// quoteUnits converts a fiat amount to token units: the price in whole
+// token cents, rounded up, plus tail units at micro-token precision.
+func (e *EVMStablecoin) quoteUnits(amount float64, tail int64) *big.Int {
+	cents := int64(math.Ceil(math.Round(amount/e.cfg.FiatRate*1e6) / 1e4))
+	units := new(big.Int).Mul(big.NewInt(cents), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(e.cfg.Decimals-2)), nil))
+	if e.cfg.Decimals > 6 {
+		tail *= new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(e.cfg.Decimals-6)), nil).Int64()
+	}
+	return units.Add(units, big.NewInt(tail))
+}
+
+// tokenAmount converts token units to a float for display and records.
+func (e *EVMStablecoin) tokenAmount(units *big.Int) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(units), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(e.cfg.Decimals)), nil))).Float64()
+	return f
+}
+
+// CreateIntent quotes the order in the token. The intent's form fields
+// carry the deposit address and exact amount, and ClientToken is an
+// EIP-681 link wallets can open to prefill the transfer. The transfer must
+// come from req.Payer, so payments need the customer's wallet address.
+func (e *EVMStablecoin) CreateIntent(ctx context.Context, req CreateIntentRequest) (Intent, error) {
+	if req.Payer == "" {
+		return Intent{}, ErrPayerRequired
+	}
+	if !IsEVMAddress(req.Payer) {
+		return Intent{}, fmt.Errorf("invalid payer address %q", req.Payer)
+	}
+	head, err := e.blockNumber(ctx)
+	if err != nil {
+		return Intent{}, err
+	}
+	tail, err := rand.Int(rand.Reader, big.NewInt(quoteTailUnits))
+	if err != nil {
+		return Intent{}, err
+	}
+	q := evmQuote{
+		payer:     strings.ToLower(req.Payer),
+		units:     e.quoteUnits(req.Amount, tail.Int64()+1),
+		fromBlock: head,
+	}
+	amount := strconv.FormatFloat(e.tokenAmount(q.units), 'f', -1, 64)
+
+	return Intent{
+		ID:       q.id(),
+		Provider: e.Name(),
+		OrderRef: req.OrderRef,
+		Amount:   e.tokenAmount(q.units),
+		Currency: e.cfg.Symbol,
+		Status:   StatusPending,
+		FormFields: map[string]string{
+			"chain_id":        strconv.FormatInt(e.cfg.ChainID, 10),
+			"token_address":   e.cfg.TokenAddress,
+			"deposit_address": e.cfg.DepositAddress,
+			"amount":          amount,
+			"amount_units":    q.units.String(),
+			"payer":           q.payer,
+			"reference":       req.OrderRef,
+		},
+		ClientToken: fmt.Sprintf("ethereum:%s@%d/transfer?address=%s&uint256=%s",
+			e.cfg.TokenAddress, e.cfg.ChainID, e.cfg.DepositAddress, q.units),
+	}, nil
+}
+
+type evmLog struct {
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	Removed         bool     `json:"removed"`
+}
+
+// findTransfer returns the block and transaction of the first unclaimed
+// transfer matching q, if any.
+func (e *EVMStablecoin) findTransfer(ctx context.Context, q evmQuote, head uint64) (uint64, string, bool, error) {
+	var logs []evmLog
+	err := e.rpc(ctx, "eth_getLogs", []interface{}{map[string]interface{}{
+		"fromBlock": "0x" + strconv.FormatUint(q.fromBlock, 16),
+		"toBlock":   "0x" + strconv.FormatUint(head, 16),
+		"address":   e.cfg.TokenAddress,
+		"topics":    []interface{}{transferTopic, topicAddress(q.payer), topicAddress(e.cfg.DepositAddress)},
+	}}, &logs)
+	if err != nil {
+		return 0, "", false, err
+	}
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) != 3 || l.TransactionHash == "" {
+			continue
+		}
+		value, ok := new(big.Int).SetString(strings.TrimPrefix(l.Data, "0x"), 16)
+		if !ok || value.Cmp(q.units) != 0 {
+			continue
+		}
+		block, err := strconv.ParseUint(strings.TrimPrefix(l.BlockNumber, "0x"), 16, 64)
+		if err != nil {
+			continue
+		}
+		txHash := strings.ToLower(l.TransactionHash)
+		if e.claimed != nil {
+			claimed, err := e.claimed(ctx, q.id(), txHash)
+			if err != nil {
+				return 0, "", false, err
+			}
+			if claimed {
+				continue
+			}
+		}
+		return block, txHash, true, nil
+	}
+	return 0, "", false, nil
+}
+
+// GetIntent reports the payment captured once a matching transfer has the
+// configured number of confirmations (the block it is in counts as one).
+func (e *EVMStablecoin) GetIntent(ctx context.Context, intentID string) (Intent, error) {
+	q, err := parseEVMQuote(intentID)
+	if err != nil {
+		return Intent{}, err
+	}
+	head, err := e.blockNumber(ctx)
+	if err != nil {
+		return Intent{}, err
+	}
+	intent := Intent{
+		ID:       intentID,
+		Provider: e.Name(),
+		Amount:   e.tokenAmount(q.units),
+		Currency: e.cfg.Symbol,
+		Status:   StatusPending,
+	}
+	block, txHash, found, err := e.findTransfer(ctx, q, head)
+	if err != nil {
+		return Intent{}, err
+	}
+	if found && head >= block && head-block+1 >= uint64(e.cfg.Confirmations) {
+		intent.Status = StatusCaptured
+		intent.TransactionID = txHash
+	}
+	return intent, nil
+}
+
+// Capture succeeds once the transfer is confirmed; there is nothing to
+// capture on chain.
+func (e *EVMStablecoin) Capture(ctx context.Context, intentID string, amount float64) (Intent, error) {
+	intent, err := e.GetIntent(ctx, intentID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if intent.Status != StatusCaptured {
+		return intent, fmt.Errorf("transfer for %s is not confirmed", intentID)
+	}
+	return intent, nil
+}
+
+// Refund is not automatic: sending tokens back needs the deposit wallet's
+// key, which the API server does not hold.
+func (e *EVMStablecoin) Refund(ctx context.Context, intentID string, amount float64) (Refund, error) {
+	return Refund{}, ErrRefundUnsupported
+}
+
+func (e *EVMStablecoin) VerifyWebhook(http.Header, []byte) (Event, error) {
+	return Event{}, ErrWebhookUnsupported
+}
@@ -0,0 +1,253 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken   = "0x1c7d4b196cb0c7b01d743fbc6116a902379c7238"
+	testDeposit = "0x00000000000000000000000000000000000d3b05"
+	testPayer   = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+)
+
+// devChain is a JSON-RPC stub standing in for a local dev chain with a
+// single ERC-20 token.
+type devChain struct {
+	mu   sync.Mutex
+	head uint64
+	logs []evmLog
+}
+
+// transfer mines a block with one Transfer and returns its transaction hash.
+func (d *devChain) transfer(from, to string, units int64) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.head++
+	txHash := fmt.Sprintf("0x%064x", len(d.logs)+1)
+	d.logs = append(d.logs, evmLog{
+		Topics:          []string{transferTopic, topicAddress(from), topicAddress(to)},
+		Data:            "0x" + strings.Repeat("0", 48) + strconv.FormatInt(units, 16),
+		BlockNumber:     "0x" + strconv.FormatUint(d.head, 16),
+		TransactionHash: txHash,
+	})
+	return txHash
+}
+
+func (d *devChain) mine(n uint64) {
+	d.mu.Lock()
+	d.head += n
+	d.mu.Unlock()
+}
+
+func (d *devChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int64             `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var result interface{}
+	switch req.Method {
+	case "eth_blockNumber":
+		result = "0x" + strconv.FormatUint(d.head, 16)
+	case "eth_getLogs":
+		var f struct {
+			FromBlock string        `json:"fromBlock"`
+			ToBlock   string        `json:"toBlock"`
+			Address   string        `json:"address"`
+			Topics    []interface{} `json:"topics"`
+		}
+		json.Unmarshal(req.Params[0], &f)
+		from, _ := strconv.ParseUint(strings.TrimPrefix(f.FromBlock, "0x"), 16, 64)
+		to, _ := strconv.ParseUint(strings.TrimPrefix(f.ToBlock, "0x"), 16, 64)
+		matched := []evmLog{}
+		for _, l := range d.logs {
+			block, _ := strconv.ParseUint(strings.TrimPrefix(l.BlockNumber, "0x"), 16, 64)
+			if block < from || block > to || f.Address != testToken {
+				continue
+			}
+			ok := true
+			for i, topic := range f.Topics {
+				if topic != nil && topic != l.Topics[i] {
+					ok = false
+				}
+			}
+			if ok {
+				matched = append(matched, l)
+			}
+		}
+		result = matched
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "error": map[string]interface{}{"code": -32601, "message": "method not found"}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func newTestStablecoin(rpcURL string) *EVMStablecoin {
+	return NewEVMStablecoin(EVMStablecoinConfig{
+		Symbol:         "USDC",
+		RPCURL:         rpcURL,
+		ChainID:        11155111,
+		TokenAddress:   testToken,
+		DepositAddress: testDeposit,
+		Confirmations:  3,
+		FiatRate:       32,
+	})
+}
+
+func TestEVMStablecoinConfirmsTransfer(t *testing.T) {
+	chain := &devChain{head: 100}
+	rpc := httptest.NewServer(chain)
+	defer rpc.Close()
+	usdc := newTestStablecoin(rpc.URL)
+	ctx := context.Background()
+
+	intent, err := usdc.CreateIntent(ctx, CreateIntentRequest{OrderRef: "order-7", Amount: 120, Payer: testPayer})
+	require.NoError(t, err)
+	assert.Equal(t, "usdc", intent.Provider)
+	assert.Equal(t, "USDC", intent.Currency)
+	assert.Equal(t, testDeposit, intent.FormFields["deposit_address"])
+	assert.Equal(t, "order-7", intent.FormFields["reference"])
+	// 120 / 32 = 3.75 USDC plus a tail below one cent
+	assert.InDelta(t, 3.755, intent.Amount, 0.005)
+	assert.Greater(t, intent.Amount, 3.75)
+	assert.True(t, strings.HasPrefix(intent.ClientToken, "ethereum:"+testToken+"@11155111/transfer?address="+testDeposit))
+
+	units, err := strconv.ParseInt(intent.FormFields["amount_units"], 10, 64)
+	require.NoError(t, err)
+
+	// Wrong amount, wrong sender and a transfer elsewhere do not count
+	chain.transfer(testPayer, testDeposit, units-1)
+	chain.transfer("0x0000000000000000000000000000000000000bad", testDeposit, units)
+	chain.transfer(testPayer, "0x0000000000000000000000000000000000000bad", units)
+	chain.mine(10)
+	got, err := usdc.GetIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, got.Status)
+
+	txHash := chain.transfer(testPayer, testDeposit, units)
+	chain.mine(1)
+	got, err = usdc.GetIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, got.Status, "two confirmations of three")
+	_, err = usdc.Capture(ctx, intent.ID, 0)
+	assert.Error(t, err)
+
+	chain.mine(1)
+	got, err = usdc.GetIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, got.Status)
+	assert.Equal(t, intent.Amount, got.Amount)
+	assert.Equal(t, txHash, got.TransactionID)
+
+	_, err = usdc.Refund(ctx, intent.ID, 3.75)
+	assert.ErrorIs(t, err, ErrRefundUnsupported)
+}
+
+func TestEVMStablecoinIgnoresEarlierTransfers(t *testing.T) {
+	chain := &devChain{head: 100}
+	rpc := httptest.NewServer(chain)
+	defer rpc.Close()
+	usdc := newTestStablecoin(rpc.URL)
+
+	q := evmQuote{payer: strings.ToLower(testPayer), units: big.NewInt(3750042), fromBlock: 200}
+	chain.transfer(testPayer, testDeposit, 3750042)
+	chain.head = 300
+
+	got, err := usdc.GetIntent(context.Background(), q.id())
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, got.Status)
+}
+
+func TestEVMStablecoinSkipsClaimedTransfers(t *testing.T) {
+	chain := &devChain{head: 100}
+	rpc := httptest.NewServer(chain)
+	defer rpc.Close()
+	usdc := newTestStablecoin(rpc.URL)
+	ctx := context.Background()
+
+	// Two open quotes for the same amount from the same wallet
+	first := evmQuote{payer: strings.ToLower(testPayer), units: big.NewInt(3750042), fromBlock: 100}
+	second := evmQuote{payer: strings.ToLower(testPayer), units: big.NewInt(3750042), fromBlock: 101}
+	paid := chain.transfer(testPayer, testDeposit, 3750042)
+	chain.mine(5)
+
+	claims := map[string]string{}
+	usdc.SetClaimCheck(func(_ context.Context, intentID, txHash string) (bool, error) {
+		owner, ok := claims[txHash]
+		return ok && owner != intentID, nil
+	})
+
+	got, err := usdc.GetIntent(ctx, first.id())
+	require.NoError(t, err)
+	require.Equal(t, StatusCaptured, got.Status)
+	claims[got.TransactionID] = first.id()
+
+	got, err = usdc.GetIntent(ctx, second.id())
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, got.Status, "one transfer settles one payment")
+
+	// The payment that claimed the transfer still sees it
+	got, err = usdc.GetIntent(ctx, first.id())
+	require.NoError(t, err)
+	assert.Equal(t, paid, got.TransactionID)
+
+	again := chain.transfer(testPayer, testDeposit, 3750042)
+	chain.mine(5)
+	got, err = usdc.GetIntent(ctx, second.id())
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, got.Status)
+	assert.Equal(t, again, got.TransactionID)
+}
+
+func TestEVMStablecoinRequiresPayer(t *testing.T) {
+	usdc := newTestStablecoin("http://unused")
+	_, err := usdc.CreateIntent(context.Background(), CreateIntentRequest{OrderRef: "order-7", Amount: 120})
+	assert.ErrorIs(t, err, ErrPayerRequired)
+
+	// Quotes from before payers were required cannot be confirmed
+	_, err = usdc.GetIntent(context.Background(), "any:3750042:19000000")
+	assert.Error(t, err)
+}
+
+func TestEVMQuoteID(t *testing.T) {
+	q := evmQuote{payer: strings.ToLower(testPayer), units: big.NewInt(3750042), fromBlock: 19000000}
+	parsed, err := parseEVMQuote(q.id())
+	require.NoError(t, err)
+	assert.Equal(t, strings.ToLower(testPayer)+":3750042:19000000", q.id())
+	assert.Equal(t, q.payer, parsed.payer)
+	assert.Equal(t, 0, parsed.units.Cmp(q.units))
+
+	for _, id := range []string{"", "a:b", "any:3750042:1", testPayer + ":x:1", testPayer + ":1:-1"} {
+		_, err := parseEVMQuote(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestEVMStablecoinQuoteUnits(t *testing.T) {
+	usdc := NewEVMStablecoin(EVMStablecoinConfig{Symbol: "USDC", FiatRate: 3})
+	assert.Equal(t, "3340001", usdc.quoteUnits(10, 1).String(), "rounded up to the cent")
+	dai := NewEVMStablecoin(EVMStablecoinConfig{Symbol: "DAI", Decimals: 18})
+	assert.Equal(t, "1000001000000000000", dai.quoteUnits(1, 1).String(), "tail stays at micro-token precision")
+
+	_, err := usdc.CreateIntent(context.Background(), CreateIntentRequest{Amount: 10, Payer: "not-an-address"})
+	assert.Error(t, err)
+	assert.True(t, IsEVMAddress(testPayer))
+	assert.False(t, IsEVMAddress("0x123"))
+}
@@ -0,0 +1,256 @@
+package payments
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewFakeProvider returns a provider named "fake" that talks to a
+// FakeServer. It is the Stripe adapter pointed at the fake server, so tests
+// exercise the same request and webhook code as production.
+func NewFakeProvider(baseURL, webhookSecret string) *Stripe {
+	s := NewStripe(baseURL, "sk_test_fake", webhookSecret)
+	s.name = "fake"
+	return s
+}
+
+type fakeIntent struct {
+	ID             string            `json:"id"`
+	Object         string            `json:"object"`
+	Amount         int64             `json:"amount"`
+	AmountReceived int64             `json:"amount_received"`
+	AmountRefunded int64             `json:"-"`
+	Currency       string            `json:"currency"`
+	Description    string            `json:"description"`
+	Status         string            `json:"status"`
+	CaptureMethod  string            `json:"capture_method"`
+	ClientSecret   string            `json:"client_secret"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+// FakeServer is an in-memory payment provider speaking the subset of the
+// Stripe API the Stripe adapter uses. Customer payment is simulated with
+// POST /_fake/payment_intents/{id}/pay?outcome=succeeded|failed, which
+// updates the intent and delivers a signed webhook to WebhookURL.
+type FakeServer struct {
+	WebhookURL    string
+	WebhookSecret string
+
+	mu      sync.Mutex
+	seq     int
+	intents map[string]*fakeIntent
+	mux     *http.ServeMux
+	client  *http.Client
+}
+
+// NewFakeServer returns a fake provider that delivers webhooks to
+// webhookURL signed with webhookSecret. An empty webhookURL disables
+// delivery.
+func NewFakeServer(webhookURL, webhookSecret string) *FakeServer {
+	f := &FakeServer{
+		WebhookURL:    webhookURL,
+		WebhookSecret: webhookSecret,
+		intents:       map[string]*fakeIntent{},
+		mux:           http.NewServeMux(),
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+	f.mux.HandleFunc("POST /v1/payment_intents", f.createIntent)
+	f.mux.HandleFunc("GET /v1/payment_intents/{id}", f.getIntent)
+	f.mux.HandleFunc("POST /v1/payment_intents/{id}/capture", f.captureIntent)
+	f.mux.HandleFunc("POST /v1/refunds", f.createRefund)
+	f.mux.HandleFunc("POST /_fake/payment_intents/{id}/pay", f.pay)
+	return f
+}
+
+func (f *FakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, _, ok := r.BasicAuth(); !strings.HasPrefix(r.URL.Path, "/_fake/") && (!ok || user == "") {
+		fakeError(w, http.StatusUnauthorized, "missing API key")
+		return
+	}
+	f.mux.ServeHTTP(w, r)
+}
+
+func fakeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": message}})
+}
+
+func fakeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *FakeServer) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s_fake_%d", prefix, f.seq)
+}
+
+func (f *FakeServer) createIntent(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	amount, err := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		fakeError(w, http.StatusBadRequest, "amount must be a positive integer")
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pi := &fakeIntent{
+		ID:            f.nextID("pi"),
+		Object:        "payment_intent",
+		Amount:        amount,
+		Currency:      strings.ToLower(r.PostForm.Get("currency")),
+		Description:   r.PostForm.Get("description"),
+		Status:        "requires_payment_method",
+		CaptureMethod: r.PostForm.Get("capture_method"),
+		Metadata:      map[string]string{"order_ref": r.PostForm.Get("metadata[order_ref]")},
+	}
+	pi.ClientSecret = pi.ID + "_secret"
+	f.intents[pi.ID] = pi
+	fakeJSON(w, pi)
+}
+
+func (f *FakeServer) getIntent(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pi, ok := f.intents[r.PathValue("id")]
+	if !ok {
+		fakeError(w, http.StatusNotFound, "no such payment_intent")
+		return
+	}
+	fakeJSON(w, pi)
+}
+
+func (f *FakeServer) captureIntent(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pi, ok := f.intents[r.PathValue("id")]
+	if !ok {
+		fakeError(w, http.StatusNotFound, "no such payment_intent")
+		return
+	}
+	if pi.Status != "requires_capture" {
+		fakeError(w, http.StatusBadRequest, "payment_intent is "+pi.Status)
+		return
+	}
+	pi.Status = "succeeded"
+	pi.AmountReceived = pi.Amount
+	if v, err := strconv.ParseInt(r.PostForm.Get("amount_to_capture"), 10, 64); err == nil && v > 0 && v < pi.Amount {
+		pi.AmountReceived = v
+	}
+	fakeJSON(w, pi)
+}
+
+func (f *FakeServer) createRefund(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pi, ok := f.intents[r.PostForm.Get("payment_intent")]
+	if !ok {
+		fakeError(w, http.StatusNotFound, "no such payment_intent")
+		return
+	}
+	if pi.Status != "succeeded" {
+		fakeError(w, http.StatusBadRequest, "payment_intent has not been captured")
+		return
+	}
+	amount := pi.AmountReceived - pi.AmountRefunded
+	if v, err := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64); err == nil && v > 0 {
+		amount = v
+	}
+	if amount > pi.AmountReceived-pi.AmountRefunded {
+		fakeError(w, http.StatusBadRequest, "refund exceeds captured amount")
+		return
+	}
+	pi.AmountRefunded += amount
+	fakeJSON(w, map[string]interface{}{
+		"id":             f.nextID("re"),
+		"object":         "refund",
+		"amount":         amount,
+		"payment_intent": pi.ID,
+		"status":         "succeeded",
+	})
+}
+
+// pay simulates the customer completing (or failing) payment.
+func (f *FakeServer) pay(w http.ResponseWriter, r *http.Request) {
+	outcome := r.URL.Query().Get("outcome")
+	if outcome == "" {
+		outcome = "succeeded"
+	}
+	if err := f.Pay(r.PathValue("id"), outcome == "succeeded"); err != nil {
+		fakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f.getIntent(w, r)
+}
+
+// Pay completes an intent as the customer would and delivers the webhook.
+// Intents created with capture_method=manual move to requires_capture
+// without a webhook, as with Stripe.
+func (f *FakeServer) Pay(id string, succeed bool) error {
+	f.mu.Lock()
+	pi, ok := f.intents[id]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("no such payment_intent %s", id)
+	}
+	eventType := "payment_intent.payment_failed"
+	switch {
+	case !succeed:
+		pi.Status = "requires_payment_method"
+	case pi.CaptureMethod == "manual":
+		pi.Status = "requires_capture"
+		f.mu.Unlock()
+		return nil
+	default:
+		pi.Status = "succeeded"
+		pi.AmountReceived = pi.Amount
+		eventType = "payment_intent.succeeded"
+	}
+	snapshot := *pi
+	f.mu.Unlock()
+
+	return f.SendWebhook(eventType, snapshot)
+}
+
+// SendWebhook posts a signed Stripe-style event to WebhookURL.
+func (f *FakeServer) SendWebhook(eventType string, object interface{}) error {
+	if f.WebhookURL == "" {
+		return nil
+	}
+	f.mu.Lock()
+	eventID := f.nextID("evt")
+	f.mu.Unlock()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":   eventID,
+		"type": eventType,
+		"data": map[string]interface{}{"object": object},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, f.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", SignStripePayload(f.WebhookSecret, body, time.Now()))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook delivery: %d", resp.StatusCode)
+	}
+	return nil
+}
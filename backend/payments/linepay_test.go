@@ -0,0 +1,85 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// linePayStub answers the LINE Pay API, rejecting badly signed requests.
+func linePayStub(t *testing.T, secret string, replies map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		nonce := r.Header.Get("X-LINE-Authorization-Nonce")
+		if r.Header.Get("X-LINE-Authorization") != LinePaySignature(secret, r.URL.Path, string(body), nonce) {
+			w.Write([]byte(`{"returnCode":"1106","returnMessage":"Header information error"}`))
+			return
+		}
+		reply, ok := replies[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(reply))
+	}))
+}
+
+func TestLinePayPaymentFlow(t *testing.T) {
+	replies := map[string]string{
+		"POST /v3/payments/request":                           `{"returnCode":"0000","returnMessage":"Success.","info":{"transactionId":2026030200000012345,"paymentUrl":{"web":"https://sandbox-web-pay.line.me/web/payment/wait?transactionReserveId=abc"}}}`,
+		"GET /v3/payments/requests/2026030200000012345/check": `{"returnCode":"0110","returnMessage":"Authorization completed."}`,
+		"POST /v3/payments/2026030200000012345/confirm":       `{"returnCode":"0000","returnMessage":"Success."}`,
+		"POST /v3/payments/2026030200000012345/refund":        `{"returnCode":"0000","returnMessage":"Success.","info":{"refundTransactionId":2026030200000099999}}`,
+	}
+	stub := linePayStub(t, "secret", replies)
+	defer stub.Close()
+	l := NewLinePay(stub.URL, "1650000000", "secret", "")
+	ctx := context.Background()
+
+	intent, err := l.CreateIntent(ctx, CreateIntentRequest{OrderRef: "order-7", Amount: 120.4, Description: "Bento", ReturnURL: "https://api.example/return"})
+	require.NoError(t, err)
+	// Large transaction IDs must not lose precision
+	assert.Equal(t, "2026030200000012345", intent.ID)
+	assert.Equal(t, 120.0, intent.Amount)
+	assert.Equal(t, "TWD", intent.Currency)
+	assert.Contains(t, intent.RedirectURL, "transactionReserveId=abc")
+
+	got, err := l.GetIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusAuthorized, got.Status)
+
+	got, err = l.Capture(ctx, intent.ID, 120)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, got.Status)
+
+	refund, err := l.Refund(ctx, intent.ID, 120)
+	require.NoError(t, err)
+	assert.Equal(t, "2026030200000099999", refund.ID)
+}
+
+func TestLinePayRejectedSignature(t *testing.T) {
+	stub := linePayStub(t, "secret", nil)
+	defer stub.Close()
+	l := NewLinePay(stub.URL, "1650000000", "wrong", "TWD")
+
+	_, err := l.CreateIntent(context.Background(), CreateIntentRequest{OrderRef: "order-7", Amount: 100})
+	assert.ErrorContains(t, err, "1106")
+}
+
+func TestLinePaySignature(t *testing.T) {
+	body, _ := json.Marshal(map[string]int{"amount": 100})
+	a := LinePaySignature("secret", "/v3/payments/request", string(body), "nonce-1")
+	assert.Equal(t, a, LinePaySignature("secret", "/v3/payments/request", string(body), "nonce-1"))
+	assert.NotEqual(t, a, LinePaySignature("secret", "/v3/payments/request", string(body), "nonce-2"))
+	assert.NotEqual(t, a, LinePaySignature("other", "/v3/payments/request", string(body), "nonce-1"))
+
+	_, err := NewLinePay("http://unused", "id", "secret", "").VerifyWebhook(nil, nil)
+	assert.ErrorIs(t, err, ErrWebhookUnsupported)
+}
@@ -0,0 +1,231 @@
+// Package payments abstracts the payment providers the platform can charge
+// through. Each provider adapter talks to its provider's HTTP API; base URLs
+// are configurable so tests and local development can point them at the
+// fake provider server in this package.
+package payments
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownProvider    = errors.New("unknown payment provider")
+	ErrInvalidSignature   = errors.New("invalid webhook signature")
+	ErrWebhookUnsupported = errors.New("provider does not send webhooks")
+	ErrPayerRequired      = errors.New("payment method needs the payer's wallet address")
+)
+
+// IntentStatus is the provider-independent state of a payment.
+type IntentStatus string
+
+const (
+	StatusPending    IntentStatus = "pending"    // waiting for the customer
+	StatusAuthorized IntentStatus = "authorized" // approved, needs capture
+	StatusCaptured   IntentStatus = "captured"   // money taken
+	StatusFailed     IntentStatus = "failed"
+	StatusCancelled  IntentStatus = "cancelled"
+	StatusRefunded   IntentStatus = "refunded"
+)
+
+// IsFinal reports whether the payment can no longer change on its own.
+func (s IntentStatus) IsFinal() bool {
+	return s == StatusCaptured || s == StatusFailed || s == StatusCancelled || s == StatusRefunded
+}
+
+// CreateIntentRequest describes what to charge. Amount is in major units
+// (e.g. 120.5 TWD); adapters convert to what their API expects.
+type CreateIntentRequest struct {
+	OrderRef    string
+	Amount      float64
+	Currency    string
+	Description string
+	ReturnURL   string // where the customer comes back to after paying
+	CancelURL   string
+	NotifyURL   string // where the provider posts server-to-server results
+	Payer       string // customer's wallet address, for on-chain methods
+}
+
+// Intent is a payment as seen by a provider.
+type Intent struct {
+	ID          string            `json:"id"`
+	Provider    string            `json:"provider"`
+	OrderRef    string            `json:"order_ref,omitempty"`
+	Amount      float64           `json:"amount"`
+	Currency    string            `json:"currency"`
+	Status      IntentStatus      `json:"status"`
+	RedirectURL string            `json:"redirect_url,omitempty"`
+	FormFields  map[string]string `json:"form_fields,omitempty"` // POST these to RedirectURL
+	ClientToken string            `json:"client_token,omitempty"`
+	// TransactionID is the public transaction that settled the payment,
+	// for methods confirmed from transfers anyone can see.
+	TransactionID string `json:"transaction_id,omitempty"`
+}
+
+// Refund is the result of refunding all or part of a payment.
+type Refund struct {
+	ID       string  `json:"id"`
+	IntentID string  `json:"intent_id"`
+	Amount   float64 `json:"amount"`
+	Status   string  `json:"status"` // succeeded, pending, failed
+}
+
+// EventType classifies a verified webhook.
+type EventType string
+
+const (
+	EventCaptured EventType = "payment.captured"
+	EventFailed   EventType = "payment.failed"
+	EventRefunded EventType = "payment.refunded"
+	EventIgnored  EventType = "ignored"
+)
+
+// Event is a verified, provider-independent webhook.
+type Event struct {
+	Type          EventType
+	IntentID      string
+	OrderRef      string
+	Amount        float64
+	TransactionID string
+}
+
+// PaymentProvider is implemented by every payment adapter.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(ctx context.Context, req CreateIntentRequest) (Intent, error)
+	// Capture takes an authorized payment. amount is in major units.
+	Capture(ctx context.Context, intentID string, amount float64) (Intent, error)
+	Refund(ctx context.Context, intentID string, amount float64) (Refund, error)
+	// GetIntent fetches the provider's current view, for reconciliation.
+	GetIntent(ctx context.Context, intentID string) (Intent, error)
+	// VerifyWebhook authenticates a webhook and decodes it.
+	VerifyWebhook(header http.Header, body []byte) (Event, error)
+}
+
+// WebhookAcknowledger is implemented by providers that expect a specific
+// response body to a webhook (ECPay wants "1|OK").
+type WebhookAcknowledger interface {
+	WebhookAck() (contentType string, body []byte)
+}
+
+// ClaimCheck reports whether txHash has already settled a payment other
+// than intentID.
+type ClaimCheck func(ctx context.Context, intentID, txHash string) (bool, error)
+
+// TransferClaimer is implemented by providers that confirm payments from
+// public transfers. A transfer matching several open payments must settle
+// only one, so they skip transfers the check reports as claimed.
+type TransferClaimer interface {
+	SetClaimCheck(ClaimCheck)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]PaymentProvider{}
+)
+
+// Register makes a provider available under its name, replacing any
+// provider registered under the same name.
+func Register(p PaymentProvider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[p.Name()] = p
+}
+
+// Get returns the provider registered under name.
+func Get(name string) (PaymentProvider, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names lists the registered providers.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// zeroDecimalCurrencies have no minor unit.
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true, "VND": true}
+
+// ToMinorUnits converts a major-unit amount to the smallest currency unit.
+func ToMinorUnits(amount float64, currency string) int64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinorUnits is the inverse of ToMinorUnits.
+func FromMinorUnits(amount int64, currency string) float64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
+
+// RegisterFromEnv registers every provider whose credentials are set and
+// returns their names. A provider missing the secret its webhooks or
+// callbacks are signed with is not registered.
+//
+//	STRIPE_SECRET_KEY, STRIPE_WEBHOOK_SECRET, STRIPE_API_BASE
+//	LINEPAY_CHANNEL_ID, LINEPAY_CHANNEL_SECRET, LINEPAY_API_BASE, LINEPAY_CURRENCY
+//	ECPAY_MERCHANT_ID, ECPAY_HASH_KEY, ECPAY_HASH_IV, ECPAY_API_BASE
+//	FAKEPAY_URL, FAKEPAY_WEBHOOK_SECRET
+//	USDC_RPC_URL, USDC_TOKEN_ADDRESS, USDC_DEPOSIT_ADDRESS, USDC_CHAIN_ID,
+//	USDC_DECIMALS, USDC_CONFIRMATIONS, USDC_FIAT_RATE
+func RegisterFromEnv() []string {
+	env := func(key, fallback string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return fallback
+	}
+	if key, secret := os.Getenv("STRIPE_SECRET_KEY"), os.Getenv("STRIPE_WEBHOOK_SECRET"); key != "" && secret != "" {
+		Register(NewStripe(env("STRIPE_API_BASE", "https://api.stripe.com"), key, secret))
+	}
+	if id, secret := os.Getenv("LINEPAY_CHANNEL_ID"), os.Getenv("LINEPAY_CHANNEL_SECRET"); id != "" && secret != "" {
+		Register(NewLinePay(env("LINEPAY_API_BASE", "https://api-pay.line.me"), id, secret, os.Getenv("LINEPAY_CURRENCY")))
+	}
+	if id, key, iv := os.Getenv("ECPAY_MERCHANT_ID"), os.Getenv("ECPAY_HASH_KEY"), os.Getenv("ECPAY_HASH_IV"); id != "" && key != "" && iv != "" {
+		Register(NewECPay(env("ECPAY_API_BASE", "https://payment.ecpay.com.tw"), id, key, iv))
+	}
+	if base := os.Getenv("FAKEPAY_URL"); base != "" {
+		Register(NewFakeProvider(base, env("FAKEPAY_WEBHOOK_SECRET", "whsec_fake")))
+	}
+	if rpcURL := os.Getenv("USDC_RPC_URL"); rpcURL != "" && IsEVMAddress(os.Getenv("USDC_DEPOSIT_ADDRESS")) {
+		chainID, _ := strconv.ParseInt(env("USDC_CHAIN_ID", "1"), 10, 64)
+		decimals, _ := strconv.Atoi(env("USDC_DECIMALS", "6"))
+		confirmations, _ := strconv.Atoi(env("USDC_CONFIRMATIONS", "12"))
+		rate, _ := strconv.ParseFloat(env("USDC_FIAT_RATE", "1"), 64)
+		Register(NewEVMStablecoin(EVMStablecoinConfig{
+			Name:           "usdc",
+			Symbol:         "USDC",
+			RPCURL:         rpcURL,
+			ChainID:        chainID,
+			TokenAddress:   env("USDC_TOKEN_ADDRESS", "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+			Decimals:       decimals,
+			DepositAddress: os.Getenv("USDC_DEPOSIT_ADDRESS"),
+			Confirmations:  confirmations,
+			FiatRate:       rate,
+		}))
+	}
+	return Names()
+}
@@ -0,0 +1,243 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stripeWebhookTolerance bounds the age of a signed webhook.
+const stripeWebhookTolerance = 5 * time.Minute
+
+// Stripe charges through the Stripe PaymentIntents API.
+type Stripe struct {
+	name          string
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	client        *http.Client
+	now           func() time.Time
+}
+
+// NewStripe returns a Stripe adapter. baseURL is normally
+// https://api.stripe.com; the fake server speaks the same subset.
+func NewStripe(baseURL, secretKey, webhookSecret string) *Stripe {
+	return &Stripe{
+		name:          "stripe",
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: 15 * time.Second},
+		now:           time.Now,
+	}
+}
+
+func (s *Stripe) Name() string { return s.name }
+
+type stripeIntent struct {
+	ID             string            `json:"id"`
+	Amount         int64             `json:"amount"`
+	AmountReceived int64             `json:"amount_received"`
+	Currency       string            `json:"currency"`
+	Status         string            `json:"status"`
+	ClientSecret   string            `json:"client_secret"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+type stripeError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s *Stripe) do(ctx context.Context, method, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.secretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e stripeError
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("stripe %s %s: %d %s", method, path, resp.StatusCode, e.Error.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (s *Stripe) toIntent(pi stripeIntent) Intent {
+	status := StatusPending
+	switch pi.Status {
+	case "requires_capture":
+		status = StatusAuthorized
+	case "succeeded":
+		status = StatusCaptured
+	case "canceled":
+		status = StatusCancelled
+	}
+	return Intent{
+		ID:          pi.ID,
+		Provider:    s.name,
+		OrderRef:    pi.Metadata["order_ref"],
+		Amount:      FromMinorUnits(pi.Amount, pi.Currency),
+		Currency:    strings.ToUpper(pi.Currency),
+		Status:      status,
+		ClientToken: pi.ClientSecret,
+	}
+}
+
+// CreateIntent creates a PaymentIntent captured automatically once the
+// customer confirms it with the returned client token.
+func (s *Stripe) CreateIntent(ctx context.Context, req CreateIntentRequest) (Intent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(ToMinorUnits(req.Amount, req.Currency), 10))
+	form.Set("currency", strings.ToLower(req.Currency))
+	form.Set("description", req.Description)
+	form.Set("metadata[order_ref]", req.OrderRef)
+	var pi stripeIntent
+	if err := s.do(ctx, http.MethodPost, "/v1/payment_intents", form, &pi); err != nil {
+		return Intent{}, err
+	}
+	return s.toIntent(pi), nil
+}
+
+func (s *Stripe) Capture(ctx context.Context, intentID string, amount float64) (Intent, error) {
+	current, err := s.GetIntent(ctx, intentID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if current.Status == StatusCaptured {
+		return current, nil
+	}
+	form := url.Values{}
+	form.Set("amount_to_capture", strconv.FormatInt(ToMinorUnits(amount, current.Currency), 10))
+	var pi stripeIntent
+	if err := s.do(ctx, http.MethodPost, "/v1/payment_intents/"+url.PathEscape(intentID)+"/capture", form, &pi); err != nil {
+		return Intent{}, err
+	}
+	return s.toIntent(pi), nil
+}
+
+func (s *Stripe) Refund(ctx context.Context, intentID string, amount float64) (Refund, error) {
+	current, err := s.GetIntent(ctx, intentID)
+	if err != nil {
+		return Refund{}, err
+	}
+	form := url.Values{}
+	form.Set("payment_intent", intentID)
+	form.Set("amount", strconv.FormatInt(ToMinorUnits(amount, current.Currency), 10))
+	var r struct {
+		ID     string `json:"id"`
+		Amount int64  `json:"amount"`
+		Status string `json:"status"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/v1/refunds", form, &r); err != nil {
+		return Refund{}, err
+	}
+	return Refund{ID: r.ID, IntentID: intentID, Amount: FromMinorUnits(r.Amount, current.Currency), Status: r.Status}, nil
+}
+
+func (s *Stripe) GetIntent(ctx context.Context, intentID string) (Intent, error) {
+	var pi stripeIntent
+	if err := s.do(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(intentID), url.Values{}, &pi); err != nil {
+		return Intent{}, err
+	}
+	return s.toIntent(pi), nil
+}
+
+// SignStripePayload returns a Stripe-Signature header value for body.
+func SignStripePayload(secret string, body []byte, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the Stripe-Signature header (HMAC-SHA256 over
+// "timestamp.body") and decodes payment_intent and refund events.
+func (s *Stripe) VerifyWebhook(header http.Header, body []byte) (Event, error) {
+	// Anyone can compute a signature with an empty secret
+	if s.webhookSecret == "" {
+		return Event{}, ErrInvalidSignature
+	}
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return Event{}, ErrInvalidSignature
+	}
+	if age := s.now().Sub(time.Unix(unix, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return Event{}, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	valid := false
+	for _, sig := range sigs {
+		if got, err := hex.DecodeString(sig); err == nil && hmac.Equal(got, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var evt struct {
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return Event{}, err
+	}
+
+	switch evt.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed", "payment_intent.canceled":
+		var pi stripeIntent
+		if err := json.Unmarshal(evt.Data.Object, &pi); err != nil {
+			return Event{}, err
+		}
+		e := Event{Type: EventCaptured, IntentID: pi.ID, OrderRef: pi.Metadata["order_ref"], Amount: FromMinorUnits(pi.Amount, pi.Currency)}
+		if evt.Type != "payment_intent.succeeded" {
+			e.Type = EventFailed
+		}
+		return e, nil
+	case "charge.refunded":
+		var ch struct {
+			PaymentIntent  string `json:"payment_intent"`
+			AmountRefunded int64  `json:"amount_refunded"`
+			Currency       string `json:"currency"`
+		}
+		if err := json.Unmarshal(evt.Data.Object, &ch); err != nil {
+			return Event{}, err
+		}
+		return Event{Type: EventRefunded, IntentID: ch.PaymentIntent, Amount: FromMinorUnits(ch.AmountRefunded, ch.Currency)}, nil
+	}
+	return Event{Type: EventIgnored}, nil
+}
@@ -0,0 +1,190 @@
+package payments
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookRecorder verifies deliveries with the provider, as the webhook
+// handler does.
+type webhookRecorder struct {
+	provider PaymentProvider
+	events   []Event
+	errs     []error
+}
+
+func (w *webhookRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	evt, err := w.provider.VerifyWebhook(r.Header, body)
+	if err != nil {
+		w.errs = append(w.errs, err)
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.events = append(w.events, evt)
+}
+
+func TestFakeProviderLifecycle(t *testing.T) {
+	recorder := &webhookRecorder{}
+	hook := httptest.NewServer(recorder)
+	defer hook.Close()
+	fake := NewFakeServer(hook.URL, "whsec_test")
+	api := httptest.NewServer(fake)
+	defer api.Close()
+	provider := NewFakeProvider(api.URL, "whsec_test")
+	recorder.provider = provider
+	ctx := context.Background()
+
+	intent, err := provider.CreateIntent(ctx, CreateIntentRequest{OrderRef: "order-7", Amount: 120.5, Currency: "TWD", Description: "Bento"})
+	require.NoError(t, err)
+	assert.Equal(t, "fake", intent.Provider)
+	assert.Equal(t, StatusPending, intent.Status)
+	assert.Equal(t, 120.5, intent.Amount)
+	assert.NotEmpty(t, intent.ClientToken)
+
+	require.NoError(t, fake.Pay(intent.ID, true))
+	require.Empty(t, recorder.errs)
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, Event{Type: EventCaptured, IntentID: intent.ID, OrderRef: "order-7", Amount: 120.5}, recorder.events[0])
+
+	got, err := provider.GetIntent(ctx, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, got.Status)
+
+	// Capturing again is a no-op
+	got, err = provider.Capture(ctx, intent.ID, 120.5)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCaptured, got.Status)
+
+	refund, err := provider.Refund(ctx, intent.ID, 60)
+	require.NoError(t, err)
+	assert.Equal(t, 60.0, refund.Amount)
+	assert.Equal(t, "succeeded", refund.Status)
+
+	_, err = provider.Refund(ctx, intent.ID, 100)
+	assert.Error(t, err, "refunds cannot exceed what is left")
+}
+
+func TestFakeProviderFailedPayment(t *testing.T) {
+	recorder := &webhookRecorder{}
+	hook := httptest.NewServer(recorder)
+	defer hook.Close()
+	fake := NewFakeServer(hook.URL, "whsec_test")
+	api := httptest.NewServer(fake)
+	defer api.Close()
+	provider := NewFakeProvider(api.URL, "whsec_test")
+	recorder.provider = provider
+
+	intent, err := provider.CreateIntent(context.Background(), CreateIntentRequest{OrderRef: "order-8", Amount: 80, Currency: "TWD"})
+	require.NoError(t, err)
+	require.NoError(t, fake.Pay(intent.ID, false))
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, EventFailed, recorder.events[0].Type)
+
+	_, err = provider.Refund(context.Background(), intent.ID, 80)
+	assert.Error(t, err, "uncaptured payments cannot be refunded")
+}
+
+func TestFakeServerRequiresAPIKey(t *testing.T) {
+	api := httptest.NewServer(NewFakeServer("", "whsec_test"))
+	defer api.Close()
+	provider := NewStripe(api.URL, "", "whsec_test")
+
+	_, err := provider.CreateIntent(context.Background(), CreateIntentRequest{Amount: 10, Currency: "TWD"})
+	assert.Error(t, err)
+}
+
+func TestStripeVerifyWebhook(t *testing.T) {
+	now := time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)
+	s := NewStripe("http://unused", "sk_test", "whsec_test")
+	s.now = func() time.Time { return now }
+	body := []byte(`{"id":"evt_1","type":"charge.refunded","data":{"object":{"payment_intent":"pi_1","amount_refunded":5000,"currency":"twd"}}}`)
+
+	header := http.Header{}
+	header.Set("Stripe-Signature", SignStripePayload("whsec_test", body, now))
+	evt, err := s.VerifyWebhook(header, body)
+	require.NoError(t, err)
+	assert.Equal(t, Event{Type: EventRefunded, IntentID: "pi_1", Amount: 50}, evt)
+
+	cases := map[string]string{
+		"wrong secret":  SignStripePayload("whsec_other", body, now),
+		"too old":       SignStripePayload("whsec_test", body, now.Add(-10*time.Minute)),
+		"missing":       "",
+		"no signatures": "t=1772474400",
+	}
+	for name, sig := range cases {
+		header.Set("Stripe-Signature", sig)
+		_, err := s.VerifyWebhook(header, body)
+		assert.ErrorIs(t, err, ErrInvalidSignature, name)
+	}
+
+	header.Set("Stripe-Signature", SignStripePayload("whsec_test", body, now))
+	tampered := []byte(string(body[:len(body)-3]) + "9}}}")
+	_, err = s.VerifyWebhook(header, tampered)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	unsigned := NewStripe("http://unused", "sk_test", "")
+	unsigned.now = s.now
+	header.Set("Stripe-Signature", SignStripePayload("", body, now))
+	_, err = unsigned.VerifyWebhook(header, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature, "no webhook secret configured")
+
+	other := []byte(`{"id":"evt_2","type":"customer.created","data":{"object":{}}}`)
+	header.Set("Stripe-Signature", SignStripePayload("whsec_test", other, now))
+	evt, err = s.VerifyWebhook(header, other)
+	require.NoError(t, err)
+	assert.Equal(t, EventIgnored, evt.Type)
+}
+
+func TestMinorUnits(t *testing.T) {
+	assert.Equal(t, int64(12050), ToMinorUnits(120.5, "twd"))
+	assert.Equal(t, int64(121), ToMinorUnits(120.5, "JPY"))
+	assert.Equal(t, 120.5, FromMinorUnits(12050, "TWD"))
+	assert.Equal(t, 50000.0, FromMinorUnits(50000, "VND"))
+}
+
+func TestRegistry(t *testing.T) {
+	Register(NewFakeProvider("http://unused", "whsec_test"))
+	p, err := Get("fake")
+	require.NoError(t, err)
+	assert.Equal(t, "fake", p.Name())
+	assert.Contains(t, Names(), "fake")
+
+	_, err = Get("bitcoin")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+func TestRegisterFromEnvRequiresWebhookSecrets(t *testing.T) {
+	registryMu.Lock()
+	saved := registry
+	registry = map[string]PaymentProvider{}
+	registryMu.Unlock()
+	defer func() {
+		registryMu.Lock()
+		registry = saved
+		registryMu.Unlock()
+	}()
+
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test")
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "")
+	t.Setenv("LINEPAY_CHANNEL_ID", "1234")
+	t.Setenv("LINEPAY_CHANNEL_SECRET", "")
+	t.Setenv("ECPAY_MERCHANT_ID", testECPayMerchantID)
+	t.Setenv("ECPAY_HASH_KEY", testECPayHashKey)
+	t.Setenv("ECPAY_HASH_IV", "")
+	t.Setenv("FAKEPAY_URL", "")
+	t.Setenv("USDC_RPC_URL", "")
+	assert.Empty(t, RegisterFromEnv())
+
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_test")
+	t.Setenv("LINEPAY_CHANNEL_SECRET", "secret")
+	t.Setenv("ECPAY_HASH_IV", testECPayHashIV)
+	assert.Equal(t, []string{"ecpay", "linepay", "stripe"}, RegisterFromEnv())
+}
@@ -56,8 +56,8 @@ export default function HomeScreen({ route, navigation }) {
         try {
             const res = await fetch(`${API_URL}/purchase/${productID}`, {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ consumer_id: user.user_id })
+                headers: { 'Content-Type': 'application/json', Authorization: `Bearer ${user.token}` },
+                body: JSON.stringify({})
             });
             const data = await res.json();
             if (res.ok) {